@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/auth"
+	"github.com/cazier/wc/db/models"
+	"github.com/gin-gonic/gin"
+)
+
+// The context key the api key of the request is stored under
+const apiKeyContext = "api_key"
+
+// requestKey reads the api key from either an "Authorization: Bearer <key>" or an "X-API-Key: <key>" header
+func requestKey(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, key, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(key)
+		}
+	}
+
+	return strings.TrimSpace(c.GetHeader("X-API-Key"))
+}
+
+// authenticate looks up the api key sent with the request, if there was one. A key that doesn't exist or was revoked
+// is always refused, even on the routes that don't need one, so that a client finds out about it straight away.
+//
+// The route groups are only rate limited once the key is known, so an ip address that keeps sending keys that aren't
+// valid is held back by the key limit before they are looked up.
+func (s *Server) authenticate() gin.HandlerFunc {
+	var failures *limiter
+	if s.options.KeyLimit.Requests > 0 {
+		failures = newLimiter("keys", s.options.KeyLimit)
+	}
+
+	return func(c *gin.Context) {
+		key := requestKey(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		if failures != nil {
+			if allowed, wait := failures.check(client); !allowed {
+				s.metrics.RateLimited("keys")
+				c.Header("Retry-After", strconv.Itoa(seconds(wait)))
+				exceptions.JsonResponse(c, &exceptions.TooManyRequestsError{})
+				return
+			}
+		}
+
+		row, err := auth.Authenticate(s.store.DB, key)
+		if errors.Is(err, auth.ErrInvalidKey) {
+			if failures != nil {
+				failures.take(client)
+			}
+			err = &exceptions.UnauthorizedError{}
+		}
+
+		if exceptions.JsonResponse(c, err) {
+			return
+		}
+
+		c.Set(apiKeyContext, row)
+		c.Next()
+	}
+}
+
+// require only lets the request through when its api key has at least the given role
+func require(role models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, found := c.Get(apiKeyContext)
+		if !found {
+			exceptions.JsonResponse(c, &exceptions.UnauthorizedError{})
+			return
+		}
+
+		if !value.(models.ApiKey).Role.Allows(role) {
+			exceptions.JsonResponse(c, &exceptions.ForbiddenError{})
+			return
+		}
+
+		c.Next()
+	}
+}
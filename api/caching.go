@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// How long a response can be cached when it has a match that is being played, or is just about to be
+	liveMaxAge = 10 * time.Second
+	// How long any other response can be cached, which only change when the squads or fixtures are updated
+	fixtureMaxAge = 5 * time.Minute
+)
+
+// modified is a row that knows when it (or anything shown along with it) last changed
+type modified interface {
+	Modified() time.Time
+}
+
+// lastModified is the latest time any of the rows changed
+func lastModified[M modified](rows []M) time.Time {
+	var last time.Time
+	for _, row := range rows {
+		if row.Modified().After(last) {
+			last = row.Modified()
+		}
+	}
+	return last
+}
+
+// live checks if any of the matches are being played, or are about to kick off before a cached response would expire
+func live(matches []models.Match) bool {
+	now := time.Now()
+
+	for _, match := range matches {
+		if !match.Played && now.After(match.When.Add(-fixtureMaxAge)) && now.Before(match.When.Add(models.LiveWindow)) {
+			return true
+		}
+	}
+	return false
+}
+
+// respond writes the data as json with the caching headers. The ETag is a hash of the body, so it changes with any
+// change to the response, while the Last-Modified header comes from the UpdatedAt timestamps of the rows. When the
+// client's copy is still current, only a 304 is sent. If-None-Match is used over If-Modified-Since when both are
+// given, since a row removed from a list changes the body without changing any of the timestamps.
+func respond(c *gin.Context, data any, modified time.Time, live bool) {
+	body, err := json.Marshal(gin.H{"data": data})
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl(c, live))
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// cacheControl lets shared caches (like a CDN) keep the public responses, but only the client itself can keep the
+// ones for requests made with an api key
+func cacheControl(c *gin.Context, live bool) string {
+	scope := "public"
+	if _, found := c.Get(apiKeyContext); found {
+		scope = "private"
+	}
+
+	age := fixtureMaxAge
+	if live {
+		age = liveMaxAge
+	}
+
+	return fmt.Sprintf("%s, max-age=%d", scope, int(age.Seconds()))
+}
+
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !modified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !modified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
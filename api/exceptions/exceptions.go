@@ -1,8 +1,10 @@
 package exceptions
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,6 +27,75 @@ func (e *InvalidValueError) Error() string {
 	return Message(e)
 }
 
+// UnauthorizedError is used when a request needs an api key, and none (or an invalid one) was given
+type UnauthorizedError struct{}
+
+func (e *UnauthorizedError) Error() string {
+	return Message(e)
+}
+
+// ForbiddenError is used when the api key doesn't have a role that allows the request
+type ForbiddenError struct{}
+
+func (e *ForbiddenError) Error() string {
+	return Message(e)
+}
+
+// TooManyRequestsError is used when a client has used up its rate limit
+type TooManyRequestsError struct{}
+
+func (e *TooManyRequestsError) Error() string {
+	return Message(e)
+}
+
+// RequestError is used when the body of a request can't be used, with a message describing why
+type RequestError struct {
+	Message string
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// ValidationError describes a single problem found in an input file, located by its line and column. A problem with
+// the file as a whole, rather than any one line of it, has no line.
+type ValidationError struct {
+	Line    int
+	Col     int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Message)
+}
+
+// ValidationErrors collects every ValidationError found while reading a single file, so that they can all be
+// reported at once instead of stopping at the first one
+type ValidationErrors struct {
+	Path   string
+	Errors []*ValidationError
+}
+
+func (e *ValidationErrors) Error() string {
+	lines := make([]string, len(e.Errors))
+
+	for index, err := range e.Errors {
+		switch {
+		case e.Path != "" && err.Line == 0:
+			lines[index] = fmt.Sprintf("%s: %s", e.Path, err.Message)
+		case e.Path != "":
+			lines[index] = fmt.Sprintf("%s:%d:%d: %s", e.Path, err.Line, err.Col, err.Message)
+		default:
+			lines[index] = err.Error()
+		}
+	}
+
+	return fmt.Sprintf("found %d problem(s)\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
 func JsonResponse(c *gin.Context, err error) bool {
 	if err != nil {
 		c.Error(err)
@@ -45,6 +116,26 @@ func JsonResponse(c *gin.Context, err error) bool {
 				http.StatusUnprocessableEntity,
 				gin.H{"error": Message(err)},
 			)
+		case *RequestError:
+			c.AbortWithStatusJSON(
+				http.StatusBadRequest,
+				gin.H{"error": Message(err)},
+			)
+		case *UnauthorizedError:
+			c.AbortWithStatusJSON(
+				http.StatusUnauthorized,
+				gin.H{"error": Message(err)},
+			)
+		case *ForbiddenError:
+			c.AbortWithStatusJSON(
+				http.StatusForbidden,
+				gin.H{"error": Message(err)},
+			)
+		case *TooManyRequestsError:
+			c.AbortWithStatusJSON(
+				http.StatusTooManyRequests,
+				gin.H{"error": Message(err)},
+			)
 		default:
 			c.AbortWithStatusJSON(
 				http.StatusInternalServerError,
@@ -61,6 +152,14 @@ func Message(err error) string {
 		return "the URI parameter was invalid, and could not be parsed"
 	case *NoResultsFoundError:
 		return "no matching items could be found"
+	case *RequestError:
+		return err.Error()
+	case *UnauthorizedError:
+		return "a valid api key is needed for this request"
+	case *ForbiddenError:
+		return "the api key does not have permission for this request"
+	case *TooManyRequestsError:
+		return "too many requests; please wait before trying again"
 	default:
 		return "an unknown error occurred; please try again"
 	}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/pool"
+	"github.com/gin-gonic/gin"
+)
+
+type predictionRequest struct {
+	MatchID *int   `json:"match_id"`
+	AScore  *int   `json:"score_a"`
+	BScore  *int   `json:"score_b"`
+	Winner  string `json:"winner"`
+
+	// Only an admin key can send a prediction for someone else
+	User string `json:"user"`
+}
+
+// findPool reads the pool in the route
+func (s *Server) findPool(c *gin.Context) (models.Pool, bool) {
+	var search models.Pool
+	if !bindUri(c, &search) {
+		return search, false
+	}
+
+	found, err := pool.Find(c.Request.Context(), s.store.DB, c.Param("id"))
+	return found, !exceptions.JsonResponse(c, err)
+}
+
+func (s *Server) queryPool(c *gin.Context) ([]models.Pool, bool) {
+	return cached(s, c, false, []string{"pools", "users"}, func() ([]models.Pool, bool) {
+		found, ok := s.findPool(c)
+		return []models.Pool{found}, ok
+	})
+}
+
+func (s *Server) getPool(c *gin.Context) {
+	if resp, ok := s.queryPool(c); ok {
+		respond(c, resp[0], resp[0].Modified(), false)
+	}
+}
+
+func (s *Server) getLeaderboard(c *gin.Context) {
+	tables := []string{"predictions", "matches", "pools", "users"}
+
+	resp, ok := cached(s, c, true, tables, func() ([]models.Entry, bool) {
+		found, ok := s.findPool(c)
+		if !ok {
+			return nil, false
+		}
+
+		entries, err := pool.Leaderboard(c.Request.Context(), s.store.DB, found)
+		return entries, !exceptions.JsonResponse(c, err)
+	})
+
+	if ok {
+		respond(c, resp, lastModified(resp), false)
+	}
+}
+
+// getPredictions isn't cached, since the picks for each match are shown once it kicks off, without any of the tables
+// changing
+func (s *Server) getPredictions(c *gin.Context) {
+	found, ok := s.findPool(c)
+	if !ok {
+		return
+	}
+
+	predictions, err := pool.Predictions(c.Request.Context(), s.store.DB, found)
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	respond(c, predictions, lastModified(predictions), false)
+}
+
+// putPrediction saves a pick for the user the api key belongs to, or for any user of the pool with an admin key
+func (s *Server) putPrediction(c *gin.Context) {
+	var body predictionRequest
+
+	found, ok := s.findPool(c)
+	if !ok || !bindJson(c, &body) {
+		return
+	}
+
+	if body.MatchID == nil || body.AScore == nil || body.BScore == nil {
+		exceptions.JsonResponse(c, &exceptions.RequestError{Message: "match_id, score_a and score_b are all needed"})
+		return
+	}
+
+	key := c.MustGet(apiKeyContext).(models.ApiKey)
+
+	var user models.User
+	var err error
+
+	switch {
+	case body.User != "" && !key.Role.Allows(models.ADMIN):
+		err = &exceptions.ForbiddenError{}
+	case body.User != "":
+		user, err = pool.FindUser(s.store.DB, found, body.User)
+	default:
+		user, err = pool.KeyUser(s.store.DB, found, key)
+
+		var missing *exceptions.NoResultsFoundError
+		if errors.As(err, &missing) {
+			err = &exceptions.ForbiddenError{}
+		}
+	}
+
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	pick := pool.Pick{MatchID: *body.MatchID, Score: utils.Score{A: *body.AScore, B: *body.BScore}, Winner: body.Winner}
+
+	prediction, err := pool.Predict(s.store.DB, user, pick)
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	c.JSON(200, gin.H{"data": prediction})
+}
@@ -1,116 +1,249 @@
 package api
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/cazier/wc/api/exceptions"
-	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/cache"
 	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
+	"github.com/cazier/wc/db/simulate"
+	"github.com/cazier/wc/db/standings"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-type QueryOptions struct {
-	query    *gorm.DB
-	multiple bool
-	callback func(tx *gorm.DB) *gorm.DB
+// cached returns the result of the query from the server's cache when it is there, or runs it otherwise. The entries
+// are found by the path of the request, which holds all of the parameters the queries use, and are invalidated by any
+// change to the tables.
+func cached[M any](s *Server, c *gin.Context, multiple bool, tables []string, load func() ([]M, bool)) ([]M, bool) {
+	key := fmt.Sprintf("%s|%t|%s", tables[0], multiple, c.Request.URL.Path)
+	return cache.Fetch(s.cache, key, tables, load)
 }
 
-func query[M any](search M, dest []M, c *gin.Context, options *QueryOptions) ([]M, bool) {
-	if options.callback != nil {
-		options.query = options.callback(options.query)
+// bindUri reads the parameters of the route into the model, which has a uri tag for each of them. An empty parameter
+// can't be searched for, and nothing ever has an id of 0.
+func bindUri(c *gin.Context, obj any) bool {
+	for _, param := range c.Params {
+		if param.Value == "" {
+			exceptions.JsonResponse(c, &exceptions.InvalidValueError{})
+			return false
+		}
+	}
+
+	if id, found := c.Params.Get("id"); found && id == "0" {
+		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
+		return false
 	}
 
-	bindings, err := bindUri(c, &search)
+	return !exceptions.JsonResponse(c, c.ShouldBindUri(obj))
+}
+
+// found responds with the error from the query, or that it found nothing, unless there are results to use
+func found[M any](c *gin.Context, items []M, err error) ([]M, bool) {
 	if exceptions.JsonResponse(c, err) {
 		return nil, false
 	}
 
-	if bindings {
-		options.query = options.query.Where(&search)
+	if len(items) == 0 {
+		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
+		return nil, false
 	}
 
-	if options.multiple {
-		options.query.Find(&dest)
-	} else {
-		options.query.First(&dest)
+	return items, true
+}
+
+// limit only reads the first result when a single item is needed
+func limit(multiple bool) int {
+	if multiple {
+		return 0
 	}
+	return 1
+}
 
-	if len(dest) == 0 {
-		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
+func (s *Server) queryPlayers(c *gin.Context, multiple bool) ([]models.Player, bool) {
+	return cached(s, c, multiple, []string{"players", "countries"}, func() ([]models.Player, bool) {
+		return s.loadPlayers(c, multiple)
+	})
+}
+
+func (s *Server) loadPlayers(c *gin.Context, multiple bool) ([]models.Player, bool) {
+	var search models.Player
+	if !bindUri(c, &search) {
 		return nil, false
 	}
 
-	return dest, true
+	filter := query.PlayerFilter{ID: search.ID, Name: search.Name, Limit: limit(multiple)}
+
+	players, err := query.FindPlayers(c.Request.Context(), s.store.DB, filter)
+	return found(c, players, err)
+}
+
+func (s *Server) queryCountries(c *gin.Context, multiple bool) ([]models.Country, bool) {
+	return cached(s, c, multiple, []string{"countries"}, func() ([]models.Country, bool) {
+		return s.loadCountries(c, multiple)
+	})
 }
 
-func bindUri(c *gin.Context, obj any) (bool, error) {
-	if c.Params == nil {
-		return false, nil
+func (s *Server) loadCountries(c *gin.Context, multiple bool) ([]models.Country, bool) {
+	var search models.Country
+	if !bindUri(c, &search) {
+		return nil, false
 	}
 
-	if id, found := c.Params.Get("id"); found && id == "0" {
-		return false, &exceptions.NoResultsFoundError{}
+	// The placeholder teams are only found when looking for one of them
+	filter := query.CountryFilter{
+		ID:           search.ID,
+		Name:         search.Name,
+		Code:         search.FifaCode,
+		Group:        search.Group,
+		Placeholders: !multiple,
+		Limit:        limit(multiple),
 	}
 
-	return true, c.ShouldBindUri(obj)
+	countries, err := query.FindCountries(c.Request.Context(), s.store.DB, filter)
+	return found(c, countries, err)
 }
 
-func adaptNameCase(c *gin.Context) (bool, string) {
-	for index, param := range c.Params {
-		if param.Key == "name" && param.Value != "" {
-			c.Params[index].Value = ""
-			return true, param.Value
-		}
-	}
-	return false, ""
+func (s *Server) queryMatches(c *gin.Context, multiple bool) ([]models.Match, bool) {
+	return cached(s, c, multiple, []string{"matches", "countries"}, func() ([]models.Match, bool) {
+		return s.loadMatches(c, multiple)
+	})
 }
 
-func queryPlayers(c *gin.Context, multiple bool) ([]models.Player, bool) {
-	// TODO special characters in player name?
-	var players []models.Player
-	var search models.Player
+func (s *Server) loadMatches(c *gin.Context, multiple bool) ([]models.Match, bool) {
+	var search models.Match
+	if !bindUri(c, &search) {
+		return nil, false
+	}
 
-	options := QueryOptions{query: db.Database.Joins("Country"), multiple: multiple}
+	filter := query.MatchFilter{ID: search.ID, Day: search.Day, Group: c.Param("group"), Limit: limit(multiple)}
 
-	if swap, name := adaptNameCase(c); swap {
-		options.callback = func(tx *gorm.DB) *gorm.DB {
-			return tx.Where("`players`.`name` LIKE @name", sql.Named("name", name))
+	if text, found := c.Params.Get("stage"); found {
+		stage, err := models.ParseStage(strings.ToUpper(text))
+		if err != nil {
+			exceptions.JsonResponse(c, &exceptions.InvalidValueError{})
+			return nil, false
 		}
+		filter.Stage = &stage
 	}
 
-	return query(search, players, c, &options)
+	matches, err := query.FindMatches(c.Request.Context(), s.store.DB, filter)
+	return found(c, matches, err)
 }
 
-func queryCountries(c *gin.Context, multiple bool) ([]models.Country, bool) {
-	var countries []models.Country
-	var search models.Country
-
-	options := QueryOptions{query: db.Database, multiple: multiple}
+func (s *Server) queryStandings(c *gin.Context, multiple bool) ([]models.GroupStandings, bool) {
+	return cached(s, c, multiple, []string{"matches", "countries"}, func() ([]models.GroupStandings, bool) {
+		return s.loadStandings(c)
+	})
+}
 
-	if multiple {
-		// Ignore the `Team A` and `Team B` placeholder teams
-		options.query = db.Database.Where("`countries`.`fifa_code` <> \"<A>\" AND `countries`.`fifa_code` <> \"<B>\"")
+func (s *Server) loadStandings(c *gin.Context) ([]models.GroupStandings, bool) {
+	groups, err := standings.Load(c.Request.Context(), s.store.DB)
+	if exceptions.JsonResponse(c, err) {
+		return nil, false
 	}
 
-	if swap, name := adaptNameCase(c); swap {
-		options.callback = func(tx *gorm.DB) *gorm.DB {
-			return tx.Where("`countries`.`name` LIKE @name", sql.Named("name", name))
+	if group, found := c.Params.Get("group"); found {
+		var matching []models.GroupStandings
+		for _, table := range groups {
+			if strings.EqualFold(table.Group, group) {
+				matching = append(matching, table)
+			}
 		}
+		groups = matching
+	}
+
+	if len(groups) == 0 {
+		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
+		return nil, false
 	}
 
-	return query(search, countries, c, &options)
+	return groups, true
 }
 
-func queryMatches(c *gin.Context, multiple bool) ([]models.Match, bool) {
-	var matches []models.Match
-	var search models.Match
+// queryRatings finds the history of the ratings, for every country or only the one in the route
+func (s *Server) queryRatings(c *gin.Context) ([]models.Rating, bool) {
+	return cached(s, c, true, []string{"ratings", "countries"}, func() ([]models.Rating, bool) {
+		var search models.Country
+		if !bindUri(c, &search) {
+			return nil, false
+		}
 
-	tx := db.Database.Joins("ACountry").Joins("BCountry").Order("`matches`.`when`")
+		filter := query.RatingFilter{CountryID: search.ID, Country: search.Name}
 
-	if group, found := c.Params.Get("group"); found {
-		tx = tx.Where("`ACountry`.`group` LIKE @group OR `BCountry`.`group` LIKE @group", sql.Named("group", group))
+		ratings, err := query.FindRatings(c.Request.Context(), s.store.DB, filter)
+		return found(c, ratings, err)
+	})
+}
+
+// The seed for the predictions, which is always the same so that the odds only change along with the results
+const predictionSeed = 1
+
+// The tables the odds are worked out from
+var predictionTables = []string{"matches", "countries"}
+
+// simulation keeps the odds from the latest simulation. Playing out the tournament takes a while, so it is only run
+// again (in the background, and only once at a time) after the results change. The odds from before the change are
+// served until then, and only the first request has to wait for them.
+type simulation struct {
+	mutex   sync.Mutex
+	odds    []simulate.Odds
+	err     error
+	version uint64
+	ready   bool
+
+	// Closed once the simulation that is running finishes, or nil when none is
+	running chan struct{}
+}
+
+func (s *Server) queryOdds(c *gin.Context) ([]simulate.Odds, bool) {
+	p := &s.simulation
+	version := s.store.Versions.Version(predictionTables...)
+
+	p.mutex.Lock()
+	if p.ready && p.version == version {
+		defer p.mutex.Unlock()
+		return found(c, p.odds, p.err)
+	}
+
+	if p.running == nil {
+		p.running = make(chan struct{})
+		go s.simulate(version, p.running)
+	}
+
+	if p.ready {
+		defer p.mutex.Unlock()
+		return found(c, p.odds, p.err)
 	}
 
-	return query(search, matches, c, &QueryOptions{query: tx, multiple: multiple})
+	running := p.running
+	p.mutex.Unlock()
+
+	select {
+	case <-running:
+	case <-c.Request.Context().Done():
+		return found[simulate.Odds](c, nil, c.Request.Context().Err())
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return found(c, p.odds, p.err)
+}
+
+// simulate plays out the rest of the tournament, from the tables at the version, and keeps the odds
+func (s *Server) simulate(version uint64, done chan struct{}) {
+	options := simulate.Options{Runs: s.options.PredictionRuns, Seed: predictionSeed}
+	odds, err := simulate.Load(context.Background(), s.store.DB, options)
+
+	p := &s.simulation
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// A failed simulation is tried again by the next request, rather than kept
+	p.odds, p.err, p.version, p.ready = odds, err, version, err == nil
+	p.running = nil
+	close(done)
 }
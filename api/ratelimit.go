@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Limit is the number of requests a client can make in each period. Clients can use all of them at once, and then
+// get them back at a steady rate over the period. A zero limit doesn't restrict the requests at all.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads a limit like "300/m" or "20/10s". The period is a duration, where a unit alone (like "m") means one
+// of it. An empty string or "0" is no limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	count, period, found := strings.Cut(s, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("could not parse the rate limit: `%s` (expected something like 300/m)", s)
+	}
+
+	requests, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("could not parse the number of requests in the rate limit: `%s`", s)
+	}
+
+	period = strings.TrimSpace(period)
+	if period != "" && strings.IndexAny(period[:1], "0123456789.") == -1 {
+		period = "1" + period
+	}
+
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return Limit{}, fmt.Errorf("could not parse the period of the rate limit: `%s`", s)
+	}
+
+	return Limit{Requests: requests, Period: duration}, nil
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// bucket holds the requests a single client has left
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// limiter keeps a token bucket for each client of a route group
+type limiter struct {
+	group string
+	limit Limit
+	now   func() time.Time
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func newLimiter(group string, limit Limit) *limiter {
+	return &limiter{group: group, limit: limit, now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// rate is the number of requests that are returned to a bucket each second
+func (l *limiter) rate() float64 {
+	return float64(l.limit.Requests) / l.limit.Period.Seconds()
+}
+
+// take uses up one request from the client's bucket. It returns whether the request is allowed, the number of requests
+// left, how long until the next one is available, and how long until the bucket is full again.
+func (l *limiter) take(client string) (bool, int, time.Duration, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b := l.refill(client)
+	if b.tokens < 1 {
+		return false, 0, l.wait(b), l.reset(b)
+	}
+
+	b.tokens--
+	return true, int(b.tokens), 0, l.reset(b)
+}
+
+// check is whether the client has a request left, and how long until it does when it hasn't, without using one up
+func (l *limiter) check(client string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, found := l.buckets[client]; !found {
+		return true, 0
+	}
+
+	b := l.refill(client)
+	if b.tokens < 1 {
+		return false, l.wait(b)
+	}
+	return true, 0
+}
+
+// refill tops up the client's bucket with the requests returned since it was last used, making a full one for a new
+// client. The mutex has to be held.
+func (l *limiter) refill(client string) *bucket {
+	now := l.now()
+	capacity := float64(l.limit.Requests)
+	l.sweep(now)
+
+	b, found := l.buckets[client]
+	if !found {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*l.rate())
+	b.updated = now
+
+	return b
+}
+
+// wait is how long until the bucket has a whole request in it again
+func (l *limiter) wait(b *bucket) time.Duration {
+	return time.Duration((1 - b.tokens) / l.rate() * float64(time.Second))
+}
+
+// reset is how long until the bucket is full again
+func (l *limiter) reset(b *bucket) time.Duration {
+	return time.Duration((float64(l.limit.Requests) - b.tokens) / l.rate() * float64(time.Second))
+}
+
+// sweep removes the buckets that would be full by now, since they are the same as a new bucket, so the clients that
+// stop making requests don't take up memory. It only runs once each period.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.limit.Period {
+		return
+	}
+
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate() >= float64(l.limit.Requests) {
+			delete(l.buckets, client)
+		}
+	}
+
+	l.swept = now
+}
+
+// rateLimit restricts how many requests each client can make to the route group. A client is its api key when the
+// request has one, or otherwise its ip address.
+func (s *Server) rateLimit(group string, limit Limit) gin.HandlerFunc {
+	if limit.Requests == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	l := newLimiter(group, limit)
+
+	return func(c *gin.Context) {
+		client := "ip:" + c.ClientIP()
+		if value, found := c.Get(apiKeyContext); found {
+			client = "key:" + strconv.Itoa(value.(models.ApiKey).ID)
+		}
+
+		allowed, remaining, wait, reset := l.take(client)
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+		if !allowed {
+			s.metrics.RateLimited(group)
+			c.Header("Retry-After", strconv.Itoa(seconds(wait)))
+			exceptions.JsonResponse(c, &exceptions.TooManyRequestsError{})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// seconds rounds a duration up to whole seconds, so that a client waiting that long is never too early
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -1,11 +1,11 @@
 package api
 
 import (
-	"database/sql"
+	"context"
+	"time"
 
-	"github.com/cazier/wc/api/exceptions"
-	"github.com/cazier/wc/db"
 	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
 	"github.com/cazier/wc/version"
 	"github.com/gin-gonic/gin"
 )
@@ -14,147 +14,158 @@ func getVersion(c *gin.Context) {
 	c.JSON(200, gin.H{"version": version.Version})
 }
 
-func getPlayer(c *gin.Context) {
-	if resp, ok := queryPlayers(c, false); ok {
-		c.JSON(200, gin.H{"data": resp[0]})
+// getHealth only shows that the process is up and serving requests
+func getHealth(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// getReady checks that the database can be reached, so that no requests are sent here until it can
+func (s *Server) getReady(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	connection, err := s.store.DB.DB()
+	if err == nil {
+		err = connection.PingContext(ctx)
+	}
+
+	if err != nil {
+		c.JSON(503, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (s *Server) getPlayer(c *gin.Context) {
+	if resp, ok := s.queryPlayers(c, false); ok {
+		respond(c, resp[0], resp[0].Modified(), false)
 	}
 }
 
-func getPlayers(c *gin.Context) {
-	if resp, ok := queryPlayers(c, true); ok {
-		c.JSON(200, gin.H{"data": resp})
+func (s *Server) getPlayers(c *gin.Context) {
+	if resp, ok := s.queryPlayers(c, true); ok {
+		respond(c, resp, lastModified(resp), false)
 	}
 }
 
-func getCountry(c *gin.Context) {
-	if resp, ok := queryCountries(c, false); ok {
-		c.JSON(200, gin.H{"data": resp[0]})
+func (s *Server) getCountry(c *gin.Context) {
+	if resp, ok := s.queryCountries(c, false); ok {
+		respond(c, resp[0], resp[0].Modified(), false)
 	}
 }
 
-func getCountries(c *gin.Context) {
-	if resp, ok := queryCountries(c, true); ok {
-		c.JSON(200, gin.H{"data": resp})
+func (s *Server) getCountries(c *gin.Context) {
+	if resp, ok := s.queryCountries(c, true); ok {
+		respond(c, resp, lastModified(resp), false)
 	}
 }
 
-func getMatch(c *gin.Context) {
-	if resp, ok := queryMatches(c, false); ok {
-		c.JSON(200, gin.H{"data": resp[0]})
+func (s *Server) getMatch(c *gin.Context) {
+	if resp, ok := s.queryMatches(c, false); ok {
+		respond(c, resp[0], resp[0].Modified(), live(resp[:1]))
 	}
 }
-func getMatches(c *gin.Context) {
-	if resp, ok := queryMatches(c, true); ok {
-		c.JSON(200, gin.H{"data": resp})
+func (s *Server) getMatches(c *gin.Context) {
+	if resp, ok := s.queryMatches(c, true); ok {
+		respond(c, resp, lastModified(resp), live(resp))
 	}
 }
 
-func getPlayerMatches(c *gin.Context) {
-	var matches []models.Match
-	var search models.Player
+func (s *Server) getGroupStandings(c *gin.Context) {
+	if resp, ok := s.queryStandings(c, false); ok {
+		respond(c, resp[0], resp[0].Modified(), false)
+	}
+}
 
-	err := c.ShouldBindUri(&search)
-	if exceptions.JsonResponse(c, err) {
-		return
+func (s *Server) getStandings(c *gin.Context) {
+	if resp, ok := s.queryStandings(c, true); ok {
+		respond(c, resp, lastModified(resp), false)
 	}
+}
 
-	for _, param := range c.Params {
-		if param.Value == "" {
-			exceptions.JsonResponse(c, &exceptions.InvalidValueError{})
-			return
-		}
+func (s *Server) getRatings(c *gin.Context) {
+	if resp, ok := s.queryRatings(c); ok {
+		respond(c, resp, lastModified(resp), false)
 	}
+}
 
-	db.Database.Joins("ACountry").Joins("BCountry").
-		Joins("JOIN players ON `players`.`country_id` = a_id OR `players`.`country_id` = b_id").
-		Where(
-			"`players`.`name` LIKE @name OR `players`.`id` = @id",
-			sql.Named("name", search.Name),
-			sql.Named("id", search.ID),
-		).
-		Order("`matches`.`when`").
-		Find(&matches)
+func (s *Server) getOdds(c *gin.Context) {
+	if resp, ok := s.queryOdds(c); ok {
+		respond(c, resp, time.Time{}, false)
+	}
+}
 
-	if len(matches) == 0 {
-		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
+func (s *Server) getPlayerMatches(c *gin.Context) {
+	var search models.Player
+	if !bindUri(c, &search) {
 		return
 	}
 
-	c.JSON(200, gin.H{"data": matches})
+	matches, err := query.FindMatches(c.Request.Context(), s.store.DB, query.MatchFilter{PlayerID: search.ID, Player: search.Name})
+	if matches, ok := found(c, matches, err); ok {
+		respond(c, matches, lastModified(matches), live(matches))
+	}
 }
 
-func getCountryMatches(c *gin.Context) {
-	var matches []models.Match
+func (s *Server) getCountryMatches(c *gin.Context) {
 	var search models.Country
-
-	err := c.ShouldBindUri(&search)
-	if exceptions.JsonResponse(c, err) {
+	if !bindUri(c, &search) {
 		return
 	}
 
-	for _, param := range c.Params {
-		if param.Value == "" {
-			exceptions.JsonResponse(c, &exceptions.InvalidValueError{})
-			return
-		}
+	matches, err := query.FindMatches(c.Request.Context(), s.store.DB, query.MatchFilter{CountryID: search.ID, Country: search.Name})
+	if matches, ok := found(c, matches, err); ok {
+		respond(c, matches, lastModified(matches), live(matches))
 	}
+}
 
-	// TODO clean this up
-	db.Database.Joins("ACountry").Joins("BCountry").
-		Where("`ACountry`.`Name` LIKE @name OR `BCountry`.`Name` LIKE @name", sql.Named("name", search.Name)).
-		Or("`ACountry`.`ID` = @id OR `BCountry`.`ID` = @id", sql.Named("id", search.ID)).
-		Order("`matches`.`when`").
-		Find(&matches)
-
-	if len(matches) == 0 {
-		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
-		return
-	}
+// squadPlayer is a player listed with their country, so the country itself is left out
+type squadPlayer struct {
+	ID uint `json:"id"`
 
-	c.JSON(200, gin.H{"data": matches})
-}
+	Name     string `json:"name"`
+	Position string `json:"position"`
+	Number   int    `json:"number"`
 
-func getCountryPlayers(c *gin.Context) {
-	type Player struct {
-		ID uint `json:"id"`
+	Goals  uint `json:"goals" `
+	Yellow uint `json:"yellows"`
+	Red    uint `json:"reds"`
+	Saves  int  `json:"saves"`
 
-		Name     string `json:"name"`
-		Position string `json:"position"`
-		Number   int    `json:"number"`
+	UpdatedAt time.Time `json:"-"`
+}
 
-		Goals  uint `json:"goals" `
-		Yellow uint `json:"yellows"`
-		Red    uint `json:"reds"`
-		Saves  int  `json:"saves"`
-	}
+func (p squadPlayer) Modified() time.Time {
+	return p.UpdatedAt
+}
 
-	var players []Player
+func (s *Server) getCountryPlayers(c *gin.Context) {
 	var search models.Country
-
-	err := c.ShouldBindUri(&search)
-	if exceptions.JsonResponse(c, err) {
+	if !bindUri(c, &search) {
 		return
 	}
 
-	for _, param := range c.Params {
-		if param.Value == "" {
-			exceptions.JsonResponse(c, &exceptions.InvalidValueError{})
-			return
-		}
+	players, err := query.FindPlayers(c.Request.Context(), s.store.DB, query.PlayerFilter{CountryID: search.ID, Country: search.Name})
+	players, ok := found(c, players, err)
+	if !ok {
+		return
 	}
 
-	// TODO clean this up
-	db.Database.Model(&models.Player{}).Joins("Country").
-		Where(
-			"Country.Name = @name OR Country.ID = @id",
-			sql.Named("name", search.Name),
-			sql.Named("id", search.ID),
-		).Find(&players)
-
-	if len(players) == 0 {
-		exceptions.JsonResponse(c, &exceptions.NoResultsFoundError{})
-		return
+	squad := make([]squadPlayer, len(players))
+	for index, player := range players {
+		squad[index] = squadPlayer{
+			ID:        uint(player.ID),
+			Name:      player.Name,
+			Position:  player.Position,
+			Number:    player.Number,
+			Goals:     player.Goals,
+			Yellow:    player.Yellow,
+			Red:       player.Red,
+			Saves:     player.Saves,
+			UpdatedAt: player.UpdatedAt,
+		}
 	}
 
-	c.JSON(200, gin.H{"data": players})
+	respond(c, squad, lastModified(squad), false)
 }
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/scoring"
+	"github.com/gin-gonic/gin"
+)
+
+type scoreRequest struct {
+	AScore     *int `json:"score_a"`
+	BScore     *int `json:"score_b"`
+	APenalties *int `json:"penalties_a"`
+	BPenalties *int `json:"penalties_b"`
+}
+
+// matchId reads the id of the match from the route
+func matchId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	return id, !exceptions.JsonResponse(c, err)
+}
+
+// bindJson reads the body of the request, describing the problem if it can't be used
+func bindJson(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		exceptions.JsonResponse(c, &exceptions.RequestError{Message: "the request body could not be read: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func (s *Server) putMatchScore(c *gin.Context) {
+	var body scoreRequest
+
+	id, ok := matchId(c)
+	if !ok || !bindJson(c, &body) {
+		return
+	}
+
+	if body.AScore == nil || body.BScore == nil {
+		exceptions.JsonResponse(c, &exceptions.RequestError{Message: "both score_a and score_b are needed"})
+		return
+	}
+
+	var penalties *utils.Score
+	if body.APenalties != nil || body.BPenalties != nil {
+		if body.APenalties == nil || body.BPenalties == nil {
+			exceptions.JsonResponse(c, &exceptions.RequestError{Message: "both penalties_a and penalties_b are needed"})
+			return
+		}
+		penalties = &utils.Score{A: *body.APenalties, B: *body.BPenalties}
+	}
+
+	_, err := scoring.SetScore(s.store.DB, id, utils.Score{A: *body.AScore, B: *body.BScore}, penalties)
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	s.getMatch(c)
+}
+
+func (s *Server) postMatchEvent(c *gin.Context) {
+	var body utils.Event
+
+	id, ok := matchId(c)
+	if !ok || !bindJson(c, &body) {
+		return
+	}
+
+	event, err := scoring.AddEvent(s.store.DB, id, body)
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	c.JSON(201, gin.H{"data": event})
+}
+
+func (s *Server) postMatchFinish(c *gin.Context) {
+	id, ok := matchId(c)
+	if !ok {
+		return
+	}
+
+	_, err := scoring.Finish(s.store.DB, id)
+	if exceptions.JsonResponse(c, err) {
+		return
+	}
+
+	s.getMatch(c)
+}
@@ -1,63 +1,318 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cazier/wc/cache"
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/metrics"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var Api *gin.Engine
+type Options struct {
+	// The address the server listens on, like "0.0.0.0:1213"
+	Listen string
+	// The gin mode, which is one of "debug", "release" or "test". The requests aren't logged in the test mode. Gin
+	// only has a single mode for the whole process, which the program sets itself with gin.SetMode.
+	Mode string
+
+	// The certificate and key files to serve https with. Both are needed, or neither for plain http.
+	TLSCert string
+	TLSKey  string
+
+	// The longest time allowed to read a whole request, and to write a response
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// How long to wait for the open requests to finish when the server is stopped
+	ShutdownTimeout time.Duration
+
+	// Require a reader api key for the read routes, which are otherwise public
+	PrivateReads bool
+
+	// The rate limits for each client of the read routes, and of the routes that record the results
+	ReadLimit  Limit
+	WriteLimit Limit
+	// The api keys that turn out not to be valid each ip address can send, which are checked before a key is looked
+	// up in the database
+	KeyLimit Limit
+	// How long the results of the read queries are kept in memory, or 0 to not keep them. They are also dropped
+	// whenever the tables they were read from are changed through the store, but a change made by another process
+	// is only seen once they expire.
+	CacheTTL time.Duration
+
+	// The addresses (or CIDR ranges) of the proxies whose X-Forwarded-For headers are used to find the client's ip
+	// address. No proxies are trusted by default, so clients can't get around the rate limits with the header.
+	TrustedProxies []string
+
+	// How many times /predictions/odds plays out the rest of the tournament, which is 10000 when it isn't set. It is
+	// only played out again in the background once the results change, with the earlier odds served until then.
+	PredictionRuns int
+}
+
+// ParseMode checks the gin mode is one of "debug", "release" or "test", where an empty mode is "debug"
+func ParseMode(s string) (string, error) {
+	switch s {
+	case "":
+		return gin.DebugMode, nil
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown api mode: `%s`", s)
+}
+
+func (o *Options) validate() error {
+	if o.Listen == "" {
+		o.Listen = "0.0.0.0:1213"
+	}
+
+	mode, err := ParseMode(o.Mode)
+	if err != nil {
+		return err
+	}
+	o.Mode = mode
+
+	if (o.TLSCert == "") != (o.TLSKey == "") {
+		return fmt.Errorf("both a tls certificate and key are needed to serve https")
+	}
+
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = 15 * time.Second
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = 30 * time.Second
+	}
+	if o.ShutdownTimeout == 0 {
+		o.ShutdownTimeout = 30 * time.Second
+	}
+
+	if o.PredictionRuns < 0 {
+		return fmt.Errorf("the prediction runs can't be negative: %d", o.PredictionRuns)
+	} else if o.PredictionRuns == 0 {
+		o.PredictionRuns = 10000
+	}
 
-func Init() {
-	gin.ForceConsoleColor()
+	return nil
+}
+
+// Server is the api for a single store. Each server has its own routes, caches, rate limits and metrics, so several
+// can run in the same process.
+type Server struct {
+	store   *db.Store
+	options Options
 
-	Api = gin.Default()
-	setupRoutes(Api)
-	Api.Run("0.0.0.0:1213")
+	engine   *gin.Engine
+	cache    *cache.Cache
+	metrics  *metrics.HTTP
+	registry *prometheus.Registry
+
+	simulation simulation
 }
 
-func setupRoutes(g *gin.Engine) {
-	utilities(g)
-	matches(g)
-	players(g)
-	countries(g)
+// New sets up the api for the store. The server can be run with Run or Serve, or used as the http.Handler of another
+// program.
+func New(store *db.Store, options Options) (*Server, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{store: store, options: options, engine: gin.New(), metrics: metrics.NewHTTP()}
+	s.cache = cache.New("reads", options.CacheTTL, store.Versions, s.metrics)
+
+	// The requests aren't logged in the test mode
+	if options.Mode != gin.TestMode {
+		s.engine.Use(gin.Logger())
+	}
+	s.engine.Use(gin.Recovery())
+
+	if err := s.engine.SetTrustedProxies(options.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("could not use the trusted proxies: %w", err)
+	}
+
+	registry, err := metrics.NewRegistry(s.metrics, store.Metrics)
+	if err != nil {
+		return nil, fmt.Errorf("could not register the metrics: %w", err)
+	}
+	s.registry = registry
+
+	s.setupRoutes()
+	return s, nil
 }
 
-func utilities(g *gin.Engine) {
+// ServeHTTP lets the server be used as the handler of another http server
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.engine.ServeHTTP(w, r)
+}
+
+// Run starts the api, and keeps serving it until the process receives a SIGINT or SIGTERM
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.Serve(ctx)
+}
+
+// Serve runs the api until the context is cancelled, and then stops accepting new connections while waiting for the
+// open requests to finish
+func (s *Server) Serve(ctx context.Context) error {
+	options := s.options
+
+	server := &http.Server{
+		Addr:              options.Listen,
+		Handler:           s,
+		ReadTimeout:       options.ReadTimeout,
+		ReadHeaderTimeout: options.ReadTimeout,
+		WriteTimeout:      options.WriteTimeout,
+	}
+
+	listener, err := net.Listen("tcp", options.Listen)
+	if err != nil {
+		return err
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		if options.TLSCert != "" {
+			log.Printf("Listening for https on %s", listener.Addr())
+			failed <- server.ServeTLS(listener, options.TLSCert, options.TLSKey)
+		} else {
+			log.Printf("Listening for http on %s", listener.Addr())
+			failed <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for the open requests to finish", options.ShutdownTimeout)
+
+	shutdown, cancel := context.WithTimeout(context.Background(), options.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdown); err != nil {
+		return fmt.Errorf("could not finish the open requests: %w", err)
+	}
+
+	if err := <-failed; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) setupRoutes() {
+	s.engine.Use(s.metrics.Middleware(), s.authenticate())
+
+	s.utilities(s.engine)
+
+	// The utilities are always public, so that the health checks and metrics don't need a key
+	reads := s.engine.Group("/", s.rateLimit("reads", s.options.ReadLimit))
+	if s.options.PrivateReads {
+		reads.Use(require(models.READER))
+	}
+
+	s.matches(reads)
+	s.players(reads)
+	s.countries(reads)
+	s.standings(reads)
+	s.ratings(reads)
+	s.predictions(reads)
+	s.pools(reads)
+
+	writes := s.rateLimit("writes", s.options.WriteLimit)
+	s.results(s.engine.Group("/", writes, require(models.SCORER)))
+	s.picks(s.engine.Group("/", writes, require(models.READER)))
+}
+
+func (s *Server) utilities(g gin.IRouter) {
 	g.GET("/version", getVersion)
+
+	g.GET("/healthz", getHealth)
+	g.GET("/readyz", s.getReady)
+	g.GET("/metrics", gin.WrapH(promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})))
 }
 
-func players(g *gin.Engine) {
-	g.GET("/player", getPlayers)
+func (s *Server) players(g gin.IRouter) {
+	g.GET("/player", s.getPlayers)
 
-	g.GET("/player/id/:id", getPlayer)
-	g.GET("/player/name/:name", getPlayer)
+	g.GET("/player/id/:id", s.getPlayer)
+	g.GET("/player/name/:name", s.getPlayer)
 	// TODO: Positions? Numbers? Goals? Cards?
 
-	g.GET("/country/id/:id/players", getCountryPlayers)
-	g.GET("/country/name/:name/players", getCountryPlayers)
+	g.GET("/country/id/:id/players", s.getCountryPlayers)
+	g.GET("/country/name/:name/players", s.getCountryPlayers)
+}
+
+func (s *Server) countries(g gin.IRouter) {
+	g.GET("/country", s.getCountries)
+
+	g.GET("/country/id/:id", s.getCountry)
+	g.GET("/country/code/:code", s.getCountry)
+	g.GET("/country/name/:name", s.getCountry)
+
+	g.GET("/country/group/:group", s.getCountries)
+}
+
+func (s *Server) matches(g gin.IRouter) {
+	g.GET("/player/id/:id/matches", s.getPlayerMatches)
+	g.GET("/player/name/:name/matches", s.getPlayerMatches)
+
+	g.GET("/country/id/:id/matches", s.getCountryMatches)
+	g.GET("/country/name/:name/matches", s.getCountryMatches)
+
+	g.GET("/match", s.getMatches)
+	g.GET("/match/id/:id", s.getMatch)
+	// g.GET("/match/between/:country_a/:country_b", s.getMatch)
+
+	g.GET("/match/day/:day", s.getMatches)
+	g.GET("/match/group/:group", s.getMatches)
+	g.GET("/match/stage/:stage", s.getMatches)
 }
 
-func countries(g *gin.Engine) {
-	g.GET("/country", getCountries)
+func (s *Server) standings(g gin.IRouter) {
+	g.GET("/standings", s.getStandings)
+	g.GET("/standings/group/:group", s.getGroupStandings)
+}
 
-	g.GET("/country/id/:id", getCountry)
-	g.GET("/country/code/:code", getCountry)
-	g.GET("/country/name/:name", getCountry)
+func (s *Server) ratings(g gin.IRouter) {
+	g.GET("/ratings", s.getRatings)
 
-	g.GET("/country/group/:group", getCountries)
+	g.GET("/country/id/:id/ratings", s.getRatings)
+	g.GET("/country/name/:name/ratings", s.getRatings)
 }
 
-func matches(g *gin.Engine) {
-	g.GET("/player/id/:id/matches", getPlayerMatches)
-	g.GET("/player/name/:name/matches", getPlayerMatches)
+func (s *Server) predictions(g gin.IRouter) {
+	g.GET("/predictions/odds", s.getOdds)
+}
 
-	g.GET("/country/id/:id/matches", getCountryMatches)
-	g.GET("/country/name/:name/matches", getCountryMatches)
+func (s *Server) pools(g gin.IRouter) {
+	g.GET("/pool/:id", s.getPool)
+	g.GET("/pool/:id/leaderboard", s.getLeaderboard)
+	g.GET("/pool/:id/predictions", s.getPredictions)
+}
 
-	g.GET("/match", getMatches)
-	g.GET("/match/id/:id", getMatch)
-	// g.GET("/match/between/:country_a/:country_b", getMatch)
+// picks records the predictions of the users of a pool, which each need their own api key (or an admin one)
+func (s *Server) picks(g gin.IRouter) {
+	g.PUT("/pool/:id/predictions", s.putPrediction)
+}
 
-	g.GET("/match/day/:day", getMatches)
-	g.GET("/match/group/:group", getMatches)
-	g.GET("/match/stage/:stage", getMatches)
+// results records the results of the matches, and needs a scorer (or admin) api key
+func (s *Server) results(g gin.IRouter) {
+	g.PUT("/match/id/:id/score", s.putMatchScore)
+	g.POST("/match/id/:id/events", s.postMatchEvent)
+	g.POST("/match/id/:id/finish", s.postMatchFinish)
 }
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -13,10 +17,12 @@ import (
 	"time"
 
 	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/auth"
 	"github.com/cazier/wc/db"
 	"github.com/cazier/wc/db/load"
 	"github.com/cazier/wc/db/load/utils"
 	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/pool"
 	"github.com/cazier/wc/version"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -25,23 +31,79 @@ import (
 var m Mock
 
 func init() {
-	gin.SetMode(gin.ReleaseMode)
-	db.InitSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 3})
-	db.LinkTables(false)
+	var store *db.Store
+	var err error
 
-	load.Teams("../test/teams.yaml")
-	load.Matches("../test/matches.yaml")
-	load.Players("../test/players.yaml")
+	gin.SetMode(gin.TestMode)
 
-	m = Mock{
-		engine:   gin.New(),
-		response: *httptest.NewRecorder(),
+	// The tests run against an in memory sqlite database, unless a postgres database is given to check the queries
+	// work there too. The tables in it are dropped and created again.
+	if dsn := os.Getenv("WC_TEST_POSTGRES_DSN"); dsn != "" {
+		store, err = db.OpenPostgres(&db.PostgresOptions{DSN: dsn, LogLevel: 2})
+		if err == nil {
+			err = store.LinkTables(true)
+		}
+		if err == nil {
+			err = importTestData(store)
+		}
+	} else {
+		store, err = newStore()
 	}
-	setupRoutes(m.engine)
+
+	if err != nil {
+		panic(err)
+	}
+
+	m = newMock(store, Options{})
+}
+
+// importTestData loads the teams, matches and players the tests use into the store
+func importTestData(store *db.Store) error {
+	files := load.Files{Teams: "../test/teams.yaml", Matches: "../test/matches.yaml", Players: "../test/players.yaml"}
+	_, err := load.Import(store, files, load.Options{})
+	return err
+}
+
+// newStore makes a separate in memory database with the test data, for the tests that change it, so they don't affect
+// each other (or the next run, with -count)
+func newStore() (*db.Store, error) {
+	store, err := db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 3})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.LinkTables(false); err != nil {
+		return nil, err
+	}
+
+	return store, importTestData(store)
+}
+
+// isolated is a server with its own database and metrics
+func isolated(t *testing.T, options Options) Mock {
+	store, err := newStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return newMock(store, options)
+}
+
+func newMock(store *db.Store, options Options) Mock {
+	options.Mode = gin.TestMode
+
+	server, err := New(store, options)
+	if err != nil {
+		panic(err)
+	}
+
+	return Mock{server: server, store: store, response: *httptest.NewRecorder()}
 }
 
 type Mock struct {
-	engine   *gin.Engine
+	server   *Server
+	store    *db.Store
 	response httptest.ResponseRecorder
 }
 
@@ -52,11 +114,25 @@ type Response struct {
 }
 
 func (m *Mock) request(method, endpoint string) Response {
+	return m.send(method, endpoint, "", nil)
+}
+
+// send makes a request with an api key (when it isn't empty), and a body encoded as json (when it isn't nil)
+func (m *Mock) send(method, endpoint, key string, body any) Response {
 	var response map[string]any
+	var reader io.Reader
 	m.response = *httptest.NewRecorder()
 
-	req, _ := http.NewRequest(method, endpoint, nil)
-	m.engine.ServeHTTP(&m.response, req)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+
+	req, _ := http.NewRequest(method, endpoint, reader)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	m.server.ServeHTTP(&m.response, req)
 
 	json.Unmarshal(m.response.Body.Bytes(), &response)
 
@@ -76,6 +152,13 @@ func assertException(t *testing.T, response Response, status int, exception erro
 	assert.Equal(t, map[string]any{"error": exceptions.Message(exception)}, response.json, messages)
 }
 
+func must[T any](items []T, err error) []T {
+	if err != nil {
+		panic(err)
+	}
+	return items
+}
+
 func nilMap(m any) map[string]any {
 	var output map[string]any
 	data, _ := json.Marshal(m)
@@ -85,7 +168,7 @@ func nilMap(m any) map[string]any {
 
 func loadPlayer() models.Player {
 	var test models.Player
-	players := utils.LoadPlayers("../test/players.yaml")
+	players := must(utils.LoadPlayers("../test/players.yaml"))
 	player := players[rand.Intn(len(players))]
 
 	storage, _ := json.Marshal(player)
@@ -96,7 +179,7 @@ func loadPlayer() models.Player {
 
 func loadCountry() models.Country {
 	var test models.Country
-	countries := utils.LoadTeams("../test/teams.yaml")
+	countries := must(utils.LoadTeams("../test/teams.yaml"))
 	country := countries[rand.Intn(len(countries))]
 
 	storage, _ := json.Marshal(country)
@@ -114,6 +197,26 @@ func TestVersion(t *testing.T) {
 	assert.Equal(t, map[string]any{"version": version.Version}, response.json)
 }
 
+func TestHealth(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+
+	response := m.GET("/healthz")
+	assert.Equal(200, response.status)
+	assert.Equal(map[string]any{"status": "ok"}, response.json)
+
+	response = m.GET("/readyz")
+	assert.Equal(200, response.status)
+
+	m.GET("/country/id/1")
+	response = m.GET("/metrics")
+	assert.Equal(200, response.status)
+	assert.Contains(response.body, `wc_http_requests_total{method="GET",route="/healthz",status="200"} 1`)
+	assert.Contains(response.body, `wc_http_request_duration_seconds_count{method="GET",route="/country/id/:id"}`)
+	assert.Contains(response.body, `wc_db_query_duration_seconds_count{operation="query",table="countries"}`)
+	assert.Contains(response.body, `wc_imports_total{result="saved"} 1`)
+}
+
 func testPlayer(t *testing.T, player models.Player, response Response) {
 	assert := assert.New(t)
 	data := response.json["data"].(map[string]any)
@@ -187,7 +290,7 @@ func testMatch(t *testing.T, response Response) {
 func TestPlayers(t *testing.T) {
 	response := m.GET("/player")
 
-	data := utils.LoadPlayers("../test/players.yaml")
+	data := must(utils.LoadPlayers("../test/players.yaml"))
 
 	assert.Equal(t, 200, response.status)
 	assert.Len(t, response.json["data"], len(data))
@@ -220,7 +323,7 @@ func TestPlayerId(t *testing.T) {
 func TestCountries(t *testing.T) {
 	response := m.GET("/country")
 
-	data := utils.LoadTeams("../test/teams.yaml")
+	data := must(utils.LoadTeams("../test/teams.yaml"))
 
 	assert.Equal(t, 200, response.status)
 	assert.Len(t, response.json["data"], len(data))
@@ -264,7 +367,7 @@ func TestCountryPlayers(t *testing.T) {
 	country := loadCountry()
 	response := m.GET(fmt.Sprintf("/country/name/%s/players", country.Name))
 
-	for _, player := range utils.LoadPlayers("../test/players.yaml") {
+	for _, player := range must(utils.LoadPlayers("../test/players.yaml")) {
 		if player.Country == country.FifaCode {
 			count++
 		}
@@ -295,7 +398,7 @@ func TestCountryMatches(t *testing.T) {
 		response.json["data"].([]any),
 	)
 
-	for _, match := range utils.LoadMatches("../test/matches.yaml") {
+	for _, match := range must(utils.LoadMatches("../test/matches.yaml")) {
 		if match.A == country.Name || match.B == country.Name {
 			count++
 		}
@@ -329,7 +432,7 @@ func TestPlayerMatches(t *testing.T) {
 		response.json["data"].([]any),
 	)
 
-	for _, match := range utils.LoadMatches("../test/matches.yaml") {
+	for _, match := range must(utils.LoadMatches("../test/matches.yaml")) {
 		if match.A == player.Country.Name || match.B == player.Country.Name {
 			count++
 		}
@@ -351,7 +454,7 @@ func TestPlayerMatches(t *testing.T) {
 func TestMatches(t *testing.T) {
 	response := m.GET("/match")
 
-	data := utils.LoadMatches("../test/matches.yaml")
+	data := must(utils.LoadMatches("../test/matches.yaml"))
 
 	assert.Equal(t, 200, response.status)
 	assert.Len(t, response.json["data"], len(data))
@@ -371,10 +474,11 @@ func TestMatches(t *testing.T) {
 }
 
 func TestMatchId(t *testing.T) {
-	id := rand.Intn(len(utils.LoadMatches("../test/matches.yaml")))
+	id := rand.Intn(len(must(utils.LoadMatches("../test/matches.yaml")))) + 1
 	response := m.GET(fmt.Sprintf("/match/id/%d", id))
 
 	testMatch(t, response)
+	assert.EqualValues(t, id, response.json["data"].(map[string]any)["id"])
 }
 
 func TestMatchDay(t *testing.T) {
@@ -398,6 +502,16 @@ func TestMatchGroup(t *testing.T) {
 	assert.EqualValues(t, response.json, upper.json)
 }
 
+func TestMatchStage(t *testing.T) {
+	for stage, count := range map[string]int{"group": 48, "ROUND_OF_SIXTEEN": 8, "quarterfinals": 4, "final": 1} {
+		response := m.GET(fmt.Sprintf("/match/stage/%s", stage))
+		assert.Len(t, response.json["data"].([]any), count, stage)
+		testMatch(t, response)
+	}
+
+	assertException(t, m.GET("/match/stage/playoffs"), http.StatusUnprocessableEntity, &exceptions.InvalidValueError{})
+}
+
 func TestNameBad(t *testing.T) {
 	tests := map[string][]string{"player": {"matches"}, "country": {"players", "matches"}}
 
@@ -432,3 +546,531 @@ func TestIdBad(t *testing.T) {
 		}
 	}
 }
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	server, err := New(m.store, Options{Listen: "127.0.0.1:0", Mode: gin.ReleaseMode})
+	assert.NoError(t, err)
+
+	go func() {
+		done <- server.Serve(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not shut down")
+	}
+
+	_, err = New(m.store, Options{Listen: "127.0.0.1:0", TLSCert: "cert.pem"})
+	assert.EqualError(t, err, "both a tls certificate and key are needed to serve https")
+}
+
+func createKey(store *db.Store, role models.Role) string {
+	_, key, err := auth.Create(store.DB, string(role), role)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func TestAuth(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+	reader, scorer, admin := createKey(m.store, models.READER), createKey(m.store, models.SCORER), createKey(m.store, models.ADMIN)
+
+	// The reads are public, unless they are made private
+	assert.Equal(200, m.GET("/country").status)
+	assert.Equal(200, m.send("GET", "/country", reader, nil).status)
+	assertException(t, m.send("GET", "/country", "wc_invalid", nil), http.StatusUnauthorized, &exceptions.UnauthorizedError{})
+
+	private := newMock(m.store, Options{PrivateReads: true})
+
+	assertException(t, private.GET("/country"), http.StatusUnauthorized, &exceptions.UnauthorizedError{})
+	assert.Equal(200, private.send("GET", "/country", reader, nil).status)
+	assert.Equal(200, private.GET("/healthz").status)
+
+	// Recording the results needs a scorer key
+	endpoint := "/match/id/999999/finish"
+	assertException(t, m.POST(endpoint), http.StatusUnauthorized, &exceptions.UnauthorizedError{})
+	assertException(t, m.send("POST", endpoint, reader, nil), http.StatusForbidden, &exceptions.ForbiddenError{})
+	assertException(t, m.send("POST", endpoint, scorer, nil), http.StatusBadRequest, &exceptions.NoResultsFoundError{})
+	assertException(t, m.send("POST", endpoint, admin, nil), http.StatusBadRequest, &exceptions.NoResultsFoundError{})
+
+	// A revoked key is refused
+	keys := must(auth.List(m.store.DB))
+	assert.NoError(auth.Revoke(m.store.DB, keys[len(keys)-1].ID))
+	assertException(t, m.send("POST", endpoint, admin, nil), http.StatusUnauthorized, &exceptions.UnauthorizedError{})
+
+	// The time a key was last used is only written once a minute
+	used := must(auth.List(m.store.DB))[0].LastUsed
+	assert.NotNil(used)
+	assert.Equal(200, m.send("GET", "/country", reader, nil).status)
+	assert.Equal(used.Unix(), must(auth.List(m.store.DB))[0].LastUsed.Unix())
+
+	// An ip address that keeps sending keys that aren't valid has its keys refused before they are looked up
+	limited := newMock(m.store, Options{KeyLimit: Limit{Requests: 2, Period: time.Minute}})
+	for range []int{1, 2} {
+		assertException(t, limited.send("GET", "/country", "wc_invalid", nil), http.StatusUnauthorized, &exceptions.UnauthorizedError{})
+	}
+	assertException(t, limited.send("GET", "/country", "wc_invalid", nil), http.StatusTooManyRequests, &exceptions.TooManyRequestsError{})
+	assertException(t, limited.send("GET", "/country", reader, nil), http.StatusTooManyRequests, &exceptions.TooManyRequestsError{})
+	assert.Equal("30", limited.response.Header().Get("Retry-After"))
+	assert.Equal(200, limited.GET("/country").status)
+}
+
+func TestScoring(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+	key := createKey(m.store, models.SCORER)
+
+	matches := m.GET("/match").json["data"].([]any)
+	group := matches[0].(map[string]any)
+	final := matches[len(matches)-1].(map[string]any)
+
+	id := int(group["id"].(float64))
+	a := group["country_a"].(map[string]any)
+	b := group["country_b"].(map[string]any)
+
+	score := func(id int, body any) Response {
+		return m.send("PUT", fmt.Sprintf("/match/id/%d/score", id), key, body)
+	}
+	event := func(body utils.Event) Response {
+		return m.send("POST", fmt.Sprintf("/match/id/%d/events", id), key, body)
+	}
+	finish := func(id int) Response {
+		return m.send("POST", fmt.Sprintf("/match/id/%d/finish", id), key, nil)
+	}
+	failed := func(response Response, message string) {
+		assertException(t, response, http.StatusBadRequest, &exceptions.RequestError{Message: message})
+	}
+
+	failed(score(id, gin.H{"score_a": 1}), "both score_a and score_b are needed")
+	failed(score(id, gin.H{"score_a": -1, "score_b": 0}), "the score can't be negative: `-1-0`")
+	failed(score(id, gin.H{"score_a": 1, "score_b": 1, "penalties_a": 4, "penalties_b": 3}), "penalties can only be given for a knockout match")
+
+	response := score(id, gin.H{"score_a": 2, "score_b": 1})
+	assert.Equal(200, response.status)
+	assert.EqualValues(2, response.json["data"].(map[string]any)["score_a"])
+	assert.EqualValues(1, response.json["data"].(map[string]any)["score_b"])
+
+	failed(event(utils.Event{Kind: "corner", Country: a["name"].(string), Player: "Someone", Minute: 3}), "unknown event kind: `corner`")
+	failed(event(utils.Event{Kind: models.GOAL, Country: "Nowhere", Player: "Someone", Minute: 3}), "`Nowhere` is not one of the teams in the match")
+	failed(event(utils.Event{Kind: models.GOAL, Country: a["name"].(string), Player: "Someone", Minute: 121}), "the minute of the event is out of range: 121+0")
+	failed(event(utils.Event{Kind: models.GOAL, Country: a["name"].(string), Minute: 3}), "the event needs the name of the player")
+
+	for _, goal := range []utils.Event{
+		{Kind: models.GOAL, Country: a["fifa_code"].(string), Player: "Someone", Minute: 12},
+		{Kind: models.GOAL, Country: b["name"].(string), Player: "Another", Minute: 45, Offset: 2},
+	} {
+		response = event(goal)
+		assert.Equal(201, response.status)
+		assert.Equal(goal.Player, response.json["data"].(map[string]any)["player"])
+	}
+
+	failed(finish(id), "the goals recorded (1-1) don't match the score (2-1)")
+
+	response = event(utils.Event{Kind: models.GOAL, Country: strings.ToLower(a["name"].(string)), Player: "Someone", Minute: 80, Penalty: true})
+	assert.Equal(201, response.status)
+
+	response = finish(id)
+	assert.Equal(200, response.status)
+	assert.Equal(true, response.json["data"].(map[string]any)["played"])
+
+	failed(finish(id), "the match has already finished")
+	failed(score(id, gin.H{"score_a": 3, "score_b": 1}), "the match has already finished")
+
+	// A knockout match needs a winner
+	knockout := int(final["id"].(float64))
+	assert.Equal(200, score(knockout, gin.H{"score_a": 1, "score_b": 1}).status)
+	failed(finish(knockout), "a knockout match needs a winner, from either the score or the penalties")
+	failed(score(knockout, gin.H{"score_a": 2, "score_b": 1, "penalties_a": 4, "penalties_b": 3}), "penalties can only be given when the score is level")
+	assert.Equal(200, score(knockout, gin.H{"score_a": 1, "score_b": 1, "penalties_a": 4, "penalties_b": 3}).status)
+	assert.Equal(200, finish(knockout).status)
+}
+
+func TestParseMode(t *testing.T) {
+	assert := assert.New(t)
+
+	mode, err := ParseMode("")
+	assert.NoError(err)
+	assert.Equal(gin.DebugMode, mode)
+
+	mode, err = ParseMode("release")
+	assert.NoError(err)
+	assert.Equal(gin.ReleaseMode, mode)
+
+	_, err = ParseMode("production")
+	assert.EqualError(err, "unknown api mode: `production`")
+}
+
+func TestParseLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	for text, limit := range map[string]Limit{
+		"":       {},
+		"0":      {},
+		"300/m":  {Requests: 300, Period: time.Minute},
+		"20/10s": {Requests: 20, Period: 10 * time.Second},
+		"5 / h":  {Requests: 5, Period: time.Hour},
+	} {
+		parsed, err := ParseLimit(text)
+		assert.NoError(err, text)
+		assert.Equal(limit, parsed, text)
+	}
+
+	for _, text := range []string{"300", "many/m", "-1/m", "300/fortnight", "300/0s"} {
+		_, err := ParseLimit(text)
+		assert.Error(err, text)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	l := newLimiter("test", Limit{Requests: 2, Period: 10 * time.Second})
+	l.now = func() time.Time { return now }
+
+	allowed, remaining, _, reset := l.take("a")
+	assert.True(allowed)
+	assert.Equal(1, remaining)
+	assert.Equal(5*time.Second, reset)
+	allowed, remaining, _, _ = l.take("a")
+	assert.True(allowed)
+	assert.Equal(0, remaining)
+
+	allowed, _, wait, reset := l.take("a")
+	assert.False(allowed)
+	assert.Equal(5*time.Second, wait)
+	assert.Equal(10*time.Second, reset)
+
+	// Each client has its own bucket, which fills up again over the period
+	allowed, _, _, _ = l.take("b")
+	assert.True(allowed)
+
+	now = now.Add(5 * time.Second)
+	allowed, remaining, _, _ = l.take("a")
+	assert.True(allowed)
+	assert.Equal(0, remaining)
+
+	now = now.Add(time.Minute)
+	l.take("a")
+	assert.Len(l.buckets, 1, "the idle bucket should have been removed")
+
+	// The middleware adds the headers, and refuses the requests over the limit
+	limited := newMock(m.store, Options{ReadLimit: Limit{Requests: 2, Period: time.Minute}})
+
+	response := limited.GET("/country/id/1")
+	assert.Equal(200, response.status)
+	assert.Equal("2", limited.response.Header().Get("X-RateLimit-Limit"))
+	assert.Equal("1", limited.response.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal("30", limited.response.Header().Get("X-RateLimit-Reset"))
+
+	// An api key is limited separately to the ip address
+	key := createKey(m.store, models.READER)
+	assert.Equal(200, limited.send("GET", "/country/id/1", key, nil).status)
+	assert.Equal(200, limited.send("GET", "/country/id/1", key, nil).status)
+	assertException(t, limited.send("GET", "/country/id/1", key, nil), http.StatusTooManyRequests, &exceptions.TooManyRequestsError{})
+
+	assert.Equal(200, limited.GET("/country/id/1").status)
+	response = limited.GET("/country/id/1")
+	assertException(t, response, http.StatusTooManyRequests, &exceptions.TooManyRequestsError{})
+	assert.Equal("0", limited.response.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal("30", limited.response.Header().Get("Retry-After"))
+
+	// The utilities are never limited
+	assert.Equal(200, limited.GET("/healthz").status)
+	assert.Equal(200, limited.GET("/healthz").status)
+	assert.Equal(200, limited.GET("/healthz").status)
+}
+
+func TestCaching(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+
+	get := func(endpoint string, headers map[string]string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", endpoint, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		m.server.ServeHTTP(response, req)
+		return response
+	}
+
+	country := loadCountry()
+	id := int(m.GET(fmt.Sprintf("/country/name/%s", country.Name)).json["data"].(map[string]any)["id"].(float64))
+
+	for _, endpoint := range []string{
+		fmt.Sprintf("/country/id/%d", id), "/country", "/player", "/match",
+		fmt.Sprintf("/country/id/%d/players", id), fmt.Sprintf("/country/id/%d/matches", id),
+	} {
+		response := get(endpoint, nil)
+		etag := response.Header().Get("ETag")
+		modified := response.Header().Get("Last-Modified")
+
+		assert.Equal(200, response.Code, endpoint)
+		assert.NotEmpty(etag, endpoint)
+		assert.NotEmpty(modified, endpoint)
+		assert.Equal("public, max-age=300", response.Header().Get("Cache-Control"), endpoint)
+
+		response = get(endpoint, map[string]string{"If-None-Match": etag})
+		assert.Equal(http.StatusNotModified, response.Code, endpoint)
+		assert.Empty(response.Body.String(), endpoint)
+		assert.Equal(etag, response.Header().Get("ETag"), endpoint)
+
+		assert.Equal(http.StatusNotModified, get(endpoint, map[string]string{"If-None-Match": `"other", W/` + etag}).Code, endpoint)
+		assert.Equal(http.StatusNotModified, get(endpoint, map[string]string{"If-Modified-Since": modified}).Code, endpoint)
+
+		assert.Equal(200, get(endpoint, map[string]string{"If-None-Match": `"other"`}).Code, endpoint)
+		assert.Equal(200, get(endpoint, map[string]string{"If-Modified-Since": "Sat, 01 Jan 2000 00:00:00 GMT"}).Code, endpoint)
+	}
+
+	// A response for a request with an api key is only cached by the client
+	response := get("/country/id/1", map[string]string{"X-API-Key": createKey(m.store, models.READER)})
+	assert.Equal("private, max-age=300", response.Header().Get("Cache-Control"))
+
+	// A change to the row changes the tags
+	before := get("/player/id/1", nil)
+	time.Sleep(time.Second)
+	m.store.DB.Model(&models.Player{ID: 1}).Update("number", 99)
+	after := get("/player/id/1", nil)
+
+	assert.NotEqual(before.Header().Get("ETag"), after.Header().Get("ETag"))
+	assert.NotEqual(before.Header().Get("Last-Modified"), after.Header().Get("Last-Modified"))
+	assert.Equal(200, get("/player/id/1", map[string]string{"If-Modified-Since": before.Header().Get("Last-Modified")}).Code)
+
+	now := time.Now()
+	assert.True(live([]models.Match{{When: now.Add(-time.Hour)}}))
+	assert.True(live([]models.Match{{When: now.Add(time.Minute)}}))
+	assert.False(live([]models.Match{{When: now.Add(-time.Hour), Played: true}}))
+	assert.False(live([]models.Match{{When: now.Add(time.Hour)}, {When: now.Add(-4 * time.Hour)}}))
+}
+
+func TestReadCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cached := isolated(t, Options{CacheTTL: time.Minute})
+
+	country := loadCountry()
+	endpoint := fmt.Sprintf("/country/code/%s", country.FifaCode)
+
+	first := cached.GET(endpoint)
+	second := cached.GET(endpoint)
+	assert.Equal(200, second.status)
+	assert.Equal(first.json, second.json)
+	assert.Contains(cached.GET("/metrics").body, `wc_cache_lookups_total{cache="reads",result="hit"} 1`)
+
+	// A change to the table is seen straight away
+	id := int(first.json["data"].(map[string]any)["id"].(float64))
+	cached.store.DB.Model(&models.Country{ID: id}).Update("group", "Z")
+
+	response := cached.GET(endpoint)
+	assert.Equal("Z", response.json["data"].(map[string]any)["group"])
+
+	// As is a change made through the api
+	var path string
+	for _, match := range cached.GET(fmt.Sprintf("/country/id/%d/matches", id)).json["data"].([]any) {
+		if !match.(map[string]any)["played"].(bool) {
+			path = fmt.Sprintf("/match/id/%d", int(match.(map[string]any)["id"].(float64)))
+		}
+	}
+	assert.Equal(200, cached.GET(path).status)
+
+	assert.Equal(200, cached.send("PUT", path+"/score", createKey(cached.store, models.ADMIN), gin.H{"score_a": 4, "score_b": 4}).status)
+	assert.EqualValues(4, cached.GET(path).json["data"].(map[string]any)["score_a"])
+}
+
+func TestStandings(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+	key := createKey(m.store, models.SCORER)
+
+	response := m.GET("/standings")
+	assert.Equal(200, response.status)
+
+	groups := response.json["data"].([]any)
+	assert.Len(groups, 8)
+	assert.Equal("A", groups[0].(map[string]any)["group"])
+
+	for _, group := range groups {
+		standings := group.(map[string]any)["standings"].([]any)
+		assert.Len(standings, 4)
+		assert.EqualValues(0, standings[0].(map[string]any)["played"])
+	}
+
+	// The first group match is won by the second team, which then leads the group
+	match := m.GET("/match/group/A").json["data"].([]any)[0].(map[string]any)
+	path := fmt.Sprintf("/match/id/%d", int(match["id"].(float64)))
+	a := match["country_a"].(map[string]any)["name"].(string)
+	b := match["country_b"].(map[string]any)["name"].(string)
+
+	assert.Equal(200, m.send("PUT", path+"/score", key, gin.H{"score_a": 0, "score_b": 2}).status)
+	for _, minute := range []int{10, 20} {
+		assert.Equal(201, m.send("POST", path+"/events", key, utils.Event{Kind: models.GOAL, Country: b, Player: "Someone", Minute: minute}).status)
+	}
+
+	// Only the finished matches count
+	assert.EqualValues(0, m.GET("/standings/group/A").json["data"].(map[string]any)["standings"].([]any)[0].(map[string]any)["played"])
+	assert.Equal(200, m.send("POST", path+"/finish", key, nil).status)
+
+	response = m.GET("/standings/group/a")
+	assert.Equal(200, response.status)
+
+	standings := response.json["data"].(map[string]any)["standings"].([]any)
+	first, last := standings[0].(map[string]any), standings[3].(map[string]any)
+
+	assert.Equal(b, first["country"].(map[string]any)["name"])
+	assert.EqualValues(1, first["won"])
+	assert.EqualValues(3, first["points"])
+	assert.EqualValues(2, first["goal_difference"])
+
+	assert.Equal(a, last["country"].(map[string]any)["name"])
+	assert.EqualValues(1, last["lost"])
+	assert.EqualValues(0, last["points"])
+	assert.EqualValues(-2, last["goal_difference"])
+
+	assertException(t, m.GET("/standings/group/Z"), http.StatusBadRequest, &exceptions.NoResultsFoundError{})
+}
+
+func TestPredictions(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{PredictionRuns: 200})
+	key := createKey(m.store, models.SCORER)
+
+	chances := func() map[string]map[string]any {
+		response := m.GET("/predictions/odds")
+		assert.Equal(200, response.status)
+
+		odds := make(map[string]map[string]any)
+		total := 0.0
+		for _, row := range response.json["data"].([]any) {
+			row := row.(map[string]any)
+			odds[row["country"].(map[string]any)["name"].(string)] = row
+			total += row["champion"].(float64)
+		}
+
+		assert.Len(odds, 32)
+		assert.InDelta(1, total, 1e-9)
+		return odds
+	}
+
+	before := chances()
+
+	// A country that has won a match is more likely to get out of its group
+	match := m.GET("/match/group/A").json["data"].([]any)[0].(map[string]any)
+	path := fmt.Sprintf("/match/id/%d", int(match["id"].(float64)))
+	winner := match["country_a"].(map[string]any)["name"].(string)
+
+	assert.Equal(200, m.send("PUT", path+"/score", key, gin.H{"score_a": 3, "score_b": 0}).status)
+	assert.Equal(200, m.send("POST", path+"/finish", key, nil).status)
+
+	// The odds from before the result are served until they have been worked out again in the background
+	assert.Equal(before, chances())
+
+	var after map[string]map[string]any
+	assert.Eventually(func() bool {
+		after = chances()
+		return after[winner]["advance"].(float64) > before[winner]["advance"].(float64)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Greater(after[winner]["rating"], before[winner]["rating"])
+}
+
+func TestRatings(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+	key := createKey(m.store, models.SCORER)
+
+	// Nothing has been played, so every country still has the initial rating
+	assert.Equal(400, m.GET("/ratings").status)
+	assert.Equal(float64(models.INITIAL_RATING), m.GET("/country/id/1").json["data"].(map[string]any)["rating"])
+
+	match := m.GET("/match/group/A").json["data"].([]any)[0].(map[string]any)
+	path := fmt.Sprintf("/match/id/%d", int(match["id"].(float64)))
+	winner := match["country_a"].(map[string]any)
+	loser := match["country_b"].(map[string]any)
+
+	assert.Equal(200, m.send("PUT", path+"/score", key, gin.H{"score_a": 3, "score_b": 0}).status)
+	assert.Equal(200, m.send("POST", path+"/finish", key, nil).status)
+
+	response := m.GET("/ratings")
+	assert.Equal(200, response.status)
+
+	history := response.json["data"].([]any)
+	assert.Len(history, 2)
+
+	gain := history[0].(map[string]any)
+	assert.Equal(winner["name"], gain["country"].(map[string]any)["name"])
+	assert.Equal(match["id"], gain["match_id"])
+	assert.Greater(gain["change"], 0.0)
+	assert.Equal(-gain["change"].(float64), history[1].(map[string]any)["change"])
+
+	country := m.GET(fmt.Sprintf("/country/id/%d", int(winner["id"].(float64)))).json["data"].(map[string]any)
+	assert.Equal(gain["rating"], country["rating"])
+
+	response = m.GET(fmt.Sprintf("/country/name/%s/ratings", loser["name"]))
+	assert.Equal(200, response.status)
+	assert.Len(response.json["data"], 1)
+	assert.Less(response.json["data"].([]any)[0].(map[string]any)["rating"], float64(models.INITIAL_RATING))
+}
+
+func TestPools(t *testing.T) {
+	assert := assert.New(t)
+	m := isolated(t, Options{})
+
+	office, err := pool.Create(m.store.DB, "Office", models.DEFAULT_RULES)
+	assert.NoError(err)
+	_, alex, _ := pool.Join(m.store.DB, office, "Alex")
+	pool.Join(m.store.DB, office, "Sam")
+
+	response := m.GET(fmt.Sprintf("/pool/%d", office.ID))
+	assert.Equal(200, response.status)
+	assert.Len(response.json["data"].(map[string]any)["users"], 2)
+	assert.Equal(float64(models.DEFAULT_RULES.Exact), response.json["data"].(map[string]any)["rules"].(map[string]any)["exact"])
+	assertException(t, m.GET("/pool/99"), 400, &exceptions.NoResultsFoundError{})
+
+	// The test matches have all kicked off already, so one of them is moved into the future
+	m.store.DB.Model(&models.Match{ID: 1}).Update("when", time.Now().Add(time.Hour))
+
+	path := fmt.Sprintf("/pool/%d/predictions", office.ID)
+	pick := gin.H{"match_id": 1, "score_a": 2, "score_b": 1}
+
+	assertException(t, m.send("PUT", path, "", pick), 401, &exceptions.UnauthorizedError{})
+	assertException(t, m.send("PUT", path, createKey(m.store, models.READER), pick), 403, &exceptions.ForbiddenError{})
+
+	response = m.send("PUT", path, alex, pick)
+	assert.Equal(200, response.status)
+	assert.Equal("Alex", response.json["data"].(map[string]any)["user"].(map[string]any)["name"])
+
+	// Only an admin can send a prediction for someone else
+	assertException(t, m.send("PUT", path, alex, gin.H{"match_id": 1, "score_a": 0, "score_b": 0, "user": "Sam"}), 403, &exceptions.ForbiddenError{})
+	response = m.send("PUT", path, createKey(m.store, models.ADMIN), gin.H{"match_id": 1, "score_a": 0, "score_b": 0, "user": "Sam"})
+	assert.Equal(200, response.status)
+
+	response = m.send("PUT", path, alex, gin.H{"match_id": 2, "score_a": 0, "score_b": 0})
+	assert.Equal(400, response.status)
+	assert.Contains(response.json["error"], "closed when it kicked off")
+
+	// The picks stay hidden until the match kicks off
+	assert.Empty(m.GET(path).json["data"])
+
+	m.store.DB.Model(&models.Match{ID: 1}).Updates(map[string]any{"when": time.Now().Add(-time.Hour), "a_score": 2, "b_score": 1, "played": true})
+	assert.Len(m.GET(path).json["data"], 2)
+
+	response = m.GET(fmt.Sprintf("/pool/%d/leaderboard", office.ID))
+	assert.Equal(200, response.status)
+
+	leaderboard := response.json["data"].([]any)
+	assert.Len(leaderboard, 2)
+	first := leaderboard[0].(map[string]any)
+	assert.Equal("Alex", first["user"].(map[string]any)["name"])
+	assert.Equal(float64(models.DEFAULT_RULES.Exact), first["points"])
+	assert.Equal(0.0, leaderboard[1].(map[string]any)["points"])
+}
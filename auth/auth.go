@@ -0,0 +1,101 @@
+// Package auth manages the api keys. The keys are random, so a plain sha256 hash of each is enough to store them
+// safely, and lets a key be found from its hash directly.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cazier/wc/db/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidKey is returned for a key that doesn't exist, or has been revoked
+var ErrInvalidKey = errors.New("the api key is not valid")
+
+const keyPrefix = "wc_"
+
+// How out of date the time a key was last used can be
+const lastUsedPrecision = time.Minute
+
+// Create makes a new api key with the role. The key itself is only returned here, and can't be found again later.
+func Create(database *gorm.DB, name string, role models.Role) (models.ApiKey, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return models.ApiKey{}, "", fmt.Errorf("could not generate a key: %w", err)
+	}
+
+	key := keyPrefix + hex.EncodeToString(secret)
+	row := models.ApiKey{Name: name, Prefix: key[:len(keyPrefix)+8], Hash: hash(key), Role: role}
+
+	if err := database.Create(&row).Error; err != nil {
+		return models.ApiKey{}, "", fmt.Errorf("could not save the api key: %w", err)
+	}
+
+	return row, key, nil
+}
+
+// Authenticate finds the api key, recording when it was last used (to the minute)
+func Authenticate(database *gorm.DB, key string) (models.ApiKey, error) {
+	var row models.ApiKey
+
+	if !strings.HasPrefix(key, keyPrefix) {
+		return row, ErrInvalidKey
+	}
+
+	result := database.Where(&models.ApiKey{Hash: hash(key)}).Limit(1).Find(&row)
+	if result.Error != nil {
+		return row, result.Error
+	}
+
+	if result.RowsAffected == 0 || row.RevokedAt != nil {
+		return models.ApiKey{}, ErrInvalidKey
+	}
+
+	// Only a write every so often is needed to see whether a key is still in use, rather than one for every request
+	now := time.Now().UTC()
+	if row.LastUsed != nil && now.Sub(*row.LastUsed) < lastUsedPrecision {
+		return row, nil
+	}
+
+	row.LastUsed = &now
+	return row, database.Model(&row).UpdateColumn("last_used", now).Error
+}
+
+// List returns every api key, including the revoked ones
+func List(database *gorm.DB) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+
+	err := database.Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke stops the api key from being used. The row is kept so that the key still shows up in the list.
+func Revoke(database *gorm.DB, id int) error {
+	var row models.ApiKey
+
+	result := database.Limit(1).Find(&row, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("there is no api key with the id %d", id)
+	}
+
+	if row.RevokedAt != nil {
+		return nil
+	}
+
+	return database.Model(&row).Update("revoked_at", time.Now().UTC()).Error
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,251 @@
+// Package cache keeps the results of the api queries in memory for a while. Each entry records the tables it was read
+// from, and any write to one of those tables (through the callbacks added by Watch) makes the entry stale, so an
+// import or a new score is seen straight away rather than once the entry expires.
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cazier/wc/metrics"
+	"gorm.io/gorm"
+)
+
+// The most entries a cache holds, so that requests for many different names can't use up the memory
+const maxEntries = 10000
+
+// Versions holds the version of each table of a database, which is increased on every write to it. The writes that
+// don't name a table (like raw sql) increase the version of everything instead. Each database has its own versions,
+// so a write to one doesn't touch the caches of any other.
+type Versions struct {
+	mutex      sync.RWMutex
+	tables     map[string]uint64
+	everything uint64
+}
+
+// Invalidate marks every entry read from any of the tables as stale, or every entry at all when no tables are given
+func (v *Versions) Invalidate(tables ...string) {
+	if v == nil {
+		return
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if len(tables) == 0 {
+		v.everything++
+	}
+
+	for _, table := range tables {
+		v.tables[table]++
+	}
+}
+
+// Invalidate marks the tables of the database as changed, when it is being watched
+func Invalidate(database *gorm.DB, tables ...string) {
+	Of(database).Invalidate(tables...)
+}
+
+// Of finds the versions of the tables of a database (or of any session or transaction from it), or nil when the
+// database isn't being watched
+func Of(database *gorm.DB) *Versions {
+	if plugin, found := database.Config.Plugins[pluginName]; found {
+		return plugin.(*Versions)
+	}
+	return nil
+}
+
+// snapshot is the version of each table (and of everything) when an entry was read
+type snapshot struct {
+	everything uint64
+	tables     []uint64
+}
+
+func (v *Versions) current(tables []string) snapshot {
+	s := snapshot{tables: make([]uint64, len(tables))}
+	if v == nil {
+		return s
+	}
+
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	s.everything = v.everything
+	for index, table := range tables {
+		s.tables[index] = v.tables[table]
+	}
+	return s
+}
+
+// Version is a number that changes whenever any of the tables do, since each of their versions only goes up
+func (v *Versions) Version(tables ...string) uint64 {
+	s := v.current(tables)
+
+	version := s.everything
+	for _, table := range s.tables {
+		version += table
+	}
+	return version
+}
+
+func (s snapshot) equal(other snapshot) bool {
+	if s.everything != other.everything || len(s.tables) != len(other.tables) {
+		return false
+	}
+
+	for index := range s.tables {
+		if s.tables[index] != other.tables[index] {
+			return false
+		}
+	}
+	return true
+}
+
+const pluginName = "cache"
+
+// Watch adds callbacks to the database that invalidate the tables each write changes, returning the versions of its
+// tables
+func Watch(database *gorm.DB) (*Versions, error) {
+	v := &Versions{tables: make(map[string]uint64)}
+	return v, database.Use(v)
+}
+
+// Name is the name of the versions as a gorm plugin
+func (v *Versions) Name() string {
+	return pluginName
+}
+
+// Initialize registers the callbacks, when the versions are added to the database as a gorm plugin
+func (v *Versions) Initialize(database *gorm.DB) error {
+	invalidate := func(tx *gorm.DB) {
+		if tx.Statement.Table == "" {
+			v.Invalidate()
+		} else {
+			v.Invalidate(tx.Statement.Table)
+		}
+	}
+
+	callbacks := database.Callback()
+
+	if err := callbacks.Create().After("gorm:create").Register("cache:invalidate_create", invalidate); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("cache:invalidate_update", invalidate); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("cache:invalidate_delete", invalidate); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("cache:invalidate_raw", invalidate)
+}
+
+type entry struct {
+	value    any
+	tables   []string
+	versions snapshot
+	expires  time.Time
+}
+
+// Cache holds the entries for a while, or until one of the tables they were read from changes
+type Cache struct {
+	name     string
+	ttl      time.Duration
+	now      func() time.Time
+	versions *Versions
+	metrics  *metrics.HTTP
+
+	mutex   sync.Mutex
+	entries map[string]entry
+	swept   time.Time
+}
+
+// New makes a cache that keeps each entry for the ttl, or until the versions of the tables it was read from change.
+// The name labels the hit and miss metrics, which are only counted when the metrics are given.
+//
+// Only the writes made through the watched database change the versions, so a write from another process (like the
+// command line next to a running api) isn't seen until the entries read before it expire.
+func New(name string, ttl time.Duration, versions *Versions, metrics *metrics.HTTP) *Cache {
+	c := &Cache{name: name, ttl: ttl, now: time.Now, versions: versions, metrics: metrics}
+	c.entries = make(map[string]entry)
+	return c
+}
+
+func (c *Cache) get(key string) (any, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	if c.now().After(e.expires) || !e.versions.equal(c.versions.current(e.tables)) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+func (c *Cache) set(key string, value any, tables []string, versions snapshot) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	if len(c.entries) >= maxEntries {
+		return
+	}
+
+	c.entries[key] = entry{value: value, tables: tables, versions: versions, expires: now.Add(c.ttl)}
+}
+
+// sweep removes the expired entries, at most once each ttl
+func (c *Cache) sweep(now time.Time) {
+	if now.Sub(c.swept) < c.ttl && len(c.entries) < maxEntries {
+		return
+	}
+
+	for key, e := range c.entries {
+		if now.After(e.expires) || !e.versions.equal(c.versions.current(e.tables)) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.swept = now
+}
+
+// Len is the number of entries in the cache, including any that are stale but haven't been removed yet
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.entries)
+}
+
+// Fetch returns the entry for the key, or calls load to read it from the tables. The result of load is only kept when
+// it succeeds. The versions of the tables are taken before load runs, so a write that happens while it is reading
+// still makes the entry stale.
+func Fetch[V any](c *Cache, key string, tables []string, load func() (V, bool)) (V, bool) {
+	if c == nil || c.ttl <= 0 {
+		return load()
+	}
+
+	value, found := c.get(key)
+	if c.metrics != nil {
+		c.metrics.CacheLookup(c.name, found)
+	}
+
+	if found {
+		return value.(V), true
+	}
+
+	versions := c.versions.current(tables)
+	result, ok := load()
+	if ok {
+		c.set(key, result, tables, versions)
+	}
+
+	return result, ok
+}
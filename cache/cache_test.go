@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestFetch(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	versions := &Versions{tables: make(map[string]uint64)}
+	c := New("test", time.Minute, versions, nil)
+	c.now = func() time.Time { return now }
+
+	loads := 0
+	load := func() ([]string, bool) {
+		loads++
+		return []string{"value"}, true
+	}
+	fetch := func(key string, tables ...string) []string {
+		value, ok := Fetch(c, key, tables, load)
+		assert.True(ok)
+		return value
+	}
+
+	assert.Equal([]string{"value"}, fetch("a", "countries"))
+	assert.Equal([]string{"value"}, fetch("a", "countries"))
+	assert.Equal(1, loads)
+
+	// A change to another table doesn't touch the entry, but a change to its own table does
+	versions.Invalidate("players")
+	fetch("a", "countries")
+	assert.Equal(1, loads)
+
+	versions.Invalidate("countries")
+	fetch("a", "countries")
+	assert.Equal(2, loads)
+
+	versions.Invalidate()
+	fetch("a", "countries")
+	assert.Equal(3, loads)
+
+	// The entries expire after the ttl
+	now = now.Add(2 * time.Minute)
+	fetch("a", "countries")
+	assert.Equal(4, loads)
+	assert.Equal(1, c.Len())
+
+	// A failed load isn't kept
+	_, ok := Fetch(c, "b", []string{"countries"}, func() ([]string, bool) { return nil, false })
+	assert.False(ok)
+	assert.Equal(1, c.Len())
+
+	// A write during the load makes the entry stale straight away
+	Fetch(c, "c", []string{"matches"}, func() ([]string, bool) {
+		versions.Invalidate("matches")
+		return load()
+	})
+	fetch("c", "matches")
+	assert.Equal(6, loads)
+
+	// Without a ttl nothing is kept
+	c = New("test", 0, versions, nil)
+	fetch("a", "countries")
+	fetch("a", "countries")
+	assert.Equal(8, loads)
+	assert.Zero(c.Len())
+}
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	type row struct {
+		ID   int
+		Name string
+	}
+
+	open := func() (*gorm.DB, *Versions) {
+		database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+		assert.NoError(err)
+
+		versions, err := Watch(database)
+		assert.NoError(err)
+		assert.NoError(database.AutoMigrate(&row{}))
+		return database, versions
+	}
+
+	database, versions := open()
+	assert.Same(versions, Of(database.Session(&gorm.Session{})))
+
+	before := versions.current([]string{"rows"})
+
+	database.Create(&row{Name: "a"})
+	assert.False(before.equal(versions.current([]string{"rows"})))
+
+	before = versions.current([]string{"rows"})
+	database.Model(&row{ID: 1}).Update("name", "b")
+	assert.False(before.equal(versions.current([]string{"rows"})))
+
+	before = versions.current([]string{"rows"})
+	database.Find(&[]row{})
+	assert.True(before.equal(versions.current([]string{"rows"})))
+
+	database.Exec("DELETE FROM rows")
+	assert.False(before.equal(versions.current([]string{"rows"})))
+
+	// The writes to one database don't touch the versions of another
+	other, otherVersions := open()
+	before = versions.current([]string{"rows"})
+	otherBefore := otherVersions.current([]string{"rows"})
+
+	other.Create(&row{Name: "c"})
+	Invalidate(other, "rows")
+	assert.True(before.equal(versions.current([]string{"rows"})))
+	assert.False(otherBefore.equal(otherVersions.current([]string{"rows"})))
+}
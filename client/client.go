@@ -0,0 +1,226 @@
+// Package client calls the api from another Go program. The methods return the same models the api is built from, and
+// the errors it responds with are turned back into the types from the exceptions package, so a missing country can be
+// found with errors.As(err, new(*exceptions.NoResultsFoundError)).
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// The api key sent with each request, which is needed for the private reads and to record the results
+	Key string
+
+	// The http client the requests are made with, or http.DefaultClient
+	HTTPClient *http.Client
+
+	// How many times a request is tried again after a connection error, a server error (502, 503 or 504) or being rate
+	// limited, with an exponential backoff starting at Backoff. A negative number never tries again.
+	Retries int
+	Backoff time.Duration
+	// The longest time to wait before trying a request again, even if the api asks for longer with Retry-After
+	MaxBackoff time.Duration
+}
+
+func (o *Options) validate() {
+	if o.HTTPClient == nil {
+		o.HTTPClient = http.DefaultClient
+	}
+
+	if o.Retries == 0 {
+		o.Retries = 3
+	} else if o.Retries < 0 {
+		o.Retries = 0
+	}
+
+	if o.Backoff == 0 {
+		o.Backoff = 250 * time.Millisecond
+	}
+	if o.MaxBackoff == 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+}
+
+// Client makes the requests to a single api. It is safe to use from several goroutines.
+type Client struct {
+	base    *url.URL
+	options Options
+}
+
+// New makes a client for the api at the base url, like "https://wc.example.com" or "http://localhost:1213/api"
+func New(base string, options Options) (*Client, error) {
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the api url: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("the api url must start with http:// or https://: `%s`", base)
+	}
+
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	options.validate()
+
+	return &Client{base: parsed, options: options}, nil
+}
+
+// endpoint joins the parts of a route onto the base url, escaping each of them
+func (c *Client) endpoint(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for index, part := range parts {
+		escaped[index] = url.PathEscape(part)
+	}
+
+	endpoint := *c.base
+	endpoint.Path = c.base.Path + "/" + strings.Join(parts, "/")
+	endpoint.RawPath = c.base.EscapedPath() + "/" + strings.Join(escaped, "/")
+	return endpoint.String()
+}
+
+// get reads the data from a route into a value of the type it holds
+func get[T any](ctx context.Context, c *Client, parts ...string) (T, error) {
+	var data T
+	err := c.do(ctx, http.MethodGet, c.endpoint(parts...), nil, &data)
+	return data, err
+}
+
+// send makes a request with a body encoded as json (when it isn't nil), and reads the data from the response
+func send[T any](ctx context.Context, c *Client, method string, body any, parts ...string) (T, error) {
+	var data T
+	var encoded []byte
+
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return data, err
+		}
+	}
+
+	err := c.do(ctx, method, c.endpoint(parts...), encoded, &data)
+	return data, err
+}
+
+// do makes the request, trying it again when that might work, and decodes the "data" of the response into the value
+func (c *Client) do(ctx context.Context, method, endpoint string, body []byte, data any) error {
+	for attempt := 0; ; attempt++ {
+		response, err := c.attempt(ctx, method, endpoint, body)
+
+		var wait time.Duration
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			// A request that might have been received is only sent again when doing it twice changes nothing
+			if !idempotent(method) {
+				return err
+			}
+		case response.StatusCode < 300:
+			defer response.Body.Close()
+			return decode(response, data)
+		default:
+			err = responseError(response)
+			response.Body.Close()
+
+			if !retryable(method, response.StatusCode) {
+				return err
+			}
+			wait = retryAfter(response)
+		}
+
+		if attempt >= c.options.Retries {
+			return err
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		if wait > c.options.MaxBackoff {
+			wait = c.options.MaxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, endpoint string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.options.Key != "" {
+		request.Header.Set("Authorization", "Bearer "+c.options.Key)
+	}
+
+	return c.options.HTTPClient.Do(request)
+}
+
+// backoff doubles the wait after each attempt, with some jitter so that many clients don't all try again together
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.options.Backoff << attempt
+	if wait <= 0 || wait > c.options.MaxBackoff {
+		wait = c.options.MaxBackoff
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodPut
+}
+
+// retryable checks if a request might work when it is tried again. A request that was rate limited was never handled,
+// so it can always be tried again.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// retryAfter reads how long the api asked the client to wait, from the number of seconds in the Retry-After header
+func retryAfter(response *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func decode(response *http.Response, data any) error {
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: data}
+
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("could not read the response from the api: %w", err)
+	}
+	return nil
+}
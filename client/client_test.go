@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cazier/wc/api"
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/auth"
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/dbtest"
+	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/pool"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newApi runs the api with its own in memory database, loaded with the test data, and returns a scorer api key for it
+// along with the database
+func newApi(t *testing.T) (*httptest.Server, string, *db.Store) {
+	files := load.Files{Teams: "../test/teams.yaml", Matches: "../test/matches.yaml", Players: "../test/players.yaml"}
+	store := dbtest.Imported(t, files)
+
+	_, key, err := auth.Create(store.DB, "test", models.SCORER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := api.New(store, api.Options{Mode: gin.TestMode, PredictionRuns: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	return ts, key, store
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := New("http://localhost:1213/api/", Options{})
+	assert.NoError(err)
+	assert.Equal("http://localhost:1213/api/player/name/Son%20Heung-min", c.endpoint("player", "name", "Son Heung-min"))
+	assert.Equal("http://localhost:1213/api/player/name/100%25%2Fsure", c.endpoint("player", "name", "100%/sure"))
+
+	_, err = New("localhost:1213", Options{})
+	assert.Error(err)
+}
+
+func TestQueries(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	ts, _, _ := newApi(t)
+	c, _ := New(ts.URL, Options{})
+
+	countries, err := c.Countries(ctx)
+	assert.NoError(err)
+	assert.Len(countries, 32)
+
+	group, err := c.Group(ctx, "A")
+	assert.NoError(err)
+	assert.Len(group, 4)
+
+	country, err := c.Country(ctx, "NZL")
+	assert.NoError(err)
+	assert.Equal("New Zealand", country.Name)
+
+	_, err = c.Country(ctx, "XXX")
+	var missing *exceptions.NoResultsFoundError
+	assert.True(errors.As(err, &missing), err)
+
+	players, err := c.Players(ctx, PlayerFilter{Country: "nzl"})
+	assert.NoError(err)
+	assert.NotEmpty(players)
+	for _, player := range players {
+		assert.Equal("New Zealand", player.Country.Name)
+	}
+
+	player, err := c.Player(ctx, players[0].ID)
+	assert.NoError(err)
+	assert.Equal(players[0].Name, player.Name)
+
+	players, err = c.Players(ctx, PlayerFilter{Name: player.Name})
+	assert.NoError(err)
+	assert.Len(players, 1)
+	assert.Equal(player.ID, players[0].ID)
+
+	players, err = c.Players(ctx, PlayerFilter{Name: player.Name, Country: "BRA"})
+	assert.NoError(err)
+	assert.Empty(players)
+
+	players, err = c.Players(ctx, PlayerFilter{Name: "nobody at all"})
+	assert.NoError(err)
+	assert.Empty(players)
+
+	matches, err := c.Matches(ctx, MatchFilter{Country: "NZL"})
+	assert.NoError(err)
+	assert.Len(matches, 3)
+	for _, match := range matches {
+		assert.True(match.ACountry.Is("NZL") || match.BCountry.Is("NZL"))
+	}
+
+	matches, err = c.Matches(ctx, MatchFilter{Group: "a", Played: new(bool)})
+	assert.NoError(err)
+	assert.Len(matches, 6)
+
+	match, err := c.Match(ctx, matches[1].ID)
+	assert.NoError(err)
+	assert.Equal(matches[1].ID, match.ID)
+	assert.Equal(models.GROUP, match.Stage)
+
+	// The knockout matches keep their stage
+	final, err := c.Match(ctx, 64)
+	assert.NoError(err)
+	assert.Equal(models.FINAL, final.Stage)
+
+	matches, err = c.Matches(ctx, MatchFilter{})
+	assert.NoError(err)
+	stages := make(map[models.Stage]int)
+	for _, match := range matches {
+		stages[match.Stage]++
+	}
+	assert.Equal(map[models.Stage]int{
+		models.GROUP: 48, models.ROUND_OF_SIXTEEN: 8, models.QUARTERFINALS: 4, models.SEMIFINALS: 2,
+		models.THIRD_PLACE: 1, models.FINAL: 1,
+	}, stages)
+
+	_, err = c.Player(ctx, 0)
+	assert.True(errors.As(err, &missing), err)
+
+	_, err = c.GroupStandings(ctx, "Z")
+	assert.True(errors.As(err, &missing), err)
+
+	odds, err := c.Odds(ctx)
+	assert.NoError(err)
+	assert.Len(odds, 32)
+}
+
+func TestResults(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	ts, key, _ := newApi(t)
+	c, _ := New(ts.URL, Options{Key: key})
+
+	matches, err := c.Matches(ctx, MatchFilter{Group: "B"})
+	assert.NoError(err)
+	match := matches[0]
+
+	// Recording the results needs a key
+	anonymous, _ := New(ts.URL, Options{})
+	_, err = anonymous.Finish(ctx, match.ID)
+	var unauthorized *exceptions.UnauthorizedError
+	assert.True(errors.As(err, &unauthorized), err)
+
+	_, err = c.SetScore(ctx, match.ID, utils.Score{A: 1, B: 1}, &utils.Score{A: 4, B: 3})
+	var invalid *exceptions.RequestError
+	assert.True(errors.As(err, &invalid), err)
+	assert.EqualError(err, "penalties can only be given for a knockout match")
+
+	match, err = c.SetScore(ctx, match.ID, utils.Score{A: 1, B: 0}, nil)
+	assert.NoError(err)
+	assert.Equal(1, match.AScore)
+
+	event, err := c.AddEvent(ctx, match.ID, utils.Event{Kind: models.GOAL, Country: match.ACountry.FifaCode, Player: "Someone", Minute: 50})
+	assert.NoError(err)
+	assert.Equal(match.ACountry.Name, event.Country.Name)
+
+	match, err = c.Finish(ctx, match.ID)
+	assert.NoError(err)
+	assert.True(match.Played)
+
+	standings, err := c.Standings(ctx)
+	assert.NoError(err)
+	assert.Len(standings, 8)
+
+	table, err := c.GroupStandings(ctx, "B")
+	assert.NoError(err)
+	assert.Equal(match.ACountry.Name, table.Standings[0].Country.Name)
+	assert.Equal(models.WIN_POINTS, table.Standings[0].Points)
+	assert.Equal(standings[1], table)
+
+	ratings, err := c.Ratings(ctx, match.ACountry.FifaCode)
+	assert.NoError(err)
+	assert.Len(ratings, 1)
+	assert.Greater(ratings[0].Change, 0.0)
+}
+
+func TestPools(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	ts, _, store := newApi(t)
+
+	office, _ := pool.Create(store.DB, "Office", models.DEFAULT_RULES)
+	_, key, _ := pool.Join(store.DB, office, "Alex")
+	store.DB.Model(&models.Match{ID: 1}).Update("when", time.Now().Add(time.Hour))
+
+	c, _ := New(ts.URL, Options{Key: key})
+
+	found, err := c.Pool(ctx, office.ID)
+	assert.NoError(err)
+	assert.Equal("Office", found.Name)
+
+	prediction, err := c.Predict(ctx, office.ID, 1, utils.Score{A: 1, B: 0}, "")
+	assert.NoError(err)
+	assert.Equal("Alex", prediction.User.Name)
+
+	_, err = c.Predict(ctx, office.ID, 2, utils.Score{A: 1, B: 0}, "")
+	var invalid *exceptions.RequestError
+	assert.True(errors.As(err, &invalid), err)
+
+	leaderboard, err := c.Leaderboard(ctx, office.ID)
+	assert.NoError(err)
+	assert.Len(leaderboard, 1)
+	assert.Equal(1, leaderboard[0].Rank)
+}
+
+func TestRetries(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": "too many requests; please wait before trying again"}`))
+		default:
+			w.Write([]byte(`{"data": {"id": 1, "name": "New Zealand"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := New(ts.URL, Options{Backoff: time.Millisecond})
+
+	country, err := c.Country(ctx, "NZL")
+	assert.NoError(err)
+	assert.Equal("New Zealand", country.Name)
+	assert.EqualValues(3, requests.Load())
+
+	// A request that isn't idempotent is only tried again when it was rate limited
+	requests.Store(0)
+	_, err = c.Finish(ctx, 1)
+	var status *StatusError
+	assert.True(errors.As(err, &status), err)
+	assert.Equal(http.StatusServiceUnavailable, status.Status)
+	assert.EqualValues(1, requests.Load())
+
+	// The last error is returned once the retries are used up
+	requests.Store(1)
+	c, _ = New(ts.URL, Options{Retries: -1})
+	_, err = c.Country(ctx, "NZL")
+	var limited *exceptions.TooManyRequestsError
+	assert.True(errors.As(err, &limited), err)
+
+	// And the context stops the waiting
+	requests.Store(0)
+	c, _ = New(ts.URL, Options{Backoff: time.Hour})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, err = c.Country(ctx, "NZL")
+	assert.ErrorIs(err, context.DeadlineExceeded)
+}
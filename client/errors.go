@@ -0,0 +1,59 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cazier/wc/api/exceptions"
+)
+
+// StatusError is an error response that doesn't match any of the exceptions, like one from a proxy in front of the api
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("the api responded with %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("the api responded with %d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// responseError turns an error response back into the exception the api responded with
+func responseError(response *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		return &StatusError{Status: response.StatusCode, Message: string(data)}
+	}
+
+	return exception(response.StatusCode, body.Error)
+}
+
+// exception finds the exception with the status and message, which is the reverse of exceptions.JsonResponse
+func exception(status int, message string) error {
+	known := map[int]error{
+		http.StatusBadRequest:          &exceptions.NoResultsFoundError{},
+		http.StatusUnprocessableEntity: &exceptions.InvalidValueError{},
+		http.StatusUnauthorized:        &exceptions.UnauthorizedError{},
+		http.StatusForbidden:           &exceptions.ForbiddenError{},
+		http.StatusTooManyRequests:     &exceptions.TooManyRequestsError{},
+	}
+
+	if err, found := known[status]; found && exceptions.Message(err) == message {
+		return err
+	}
+
+	// Any other bad request describes what was wrong with it
+	if status == http.StatusBadRequest {
+		return &exceptions.RequestError{Message: message}
+	}
+
+	return &StatusError{Status: status, Message: message}
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/simulate"
+)
+
+// PlayerFilter narrows down the players. The name finds the first player it matches, and can use the % and _
+// wildcards of a sql LIKE. The country is either its name or FIFA code.
+type PlayerFilter struct {
+	Name    string
+	Country string
+}
+
+// MatchFilter narrows down the matches. The player and country are the same as in a PlayerFilter, and a match is in a
+// group when either of its countries are.
+type MatchFilter struct {
+	Player  string
+	Country string
+	Group   string
+	Day     int
+	Played  *bool
+}
+
+// list reads the items from a route, where the api finding nothing is an empty list rather than an error
+func list[T any](ctx context.Context, c *Client, parts ...string) ([]T, error) {
+	items, err := get[[]T](ctx, c, parts...)
+
+	var missing *exceptions.NoResultsFoundError
+	if errors.As(err, &missing) {
+		return []T{}, nil
+	}
+
+	return items, err
+}
+
+// Countries lists all of the countries in the tournament
+func (c *Client) Countries(ctx context.Context) ([]models.Country, error) {
+	return list[models.Country](ctx, c, "country")
+}
+
+// Group lists the countries in a group
+func (c *Client) Group(ctx context.Context, group string) ([]models.Country, error) {
+	return list[models.Country](ctx, c, "country", "group", group)
+}
+
+// Country finds a country by its FIFA code
+func (c *Client) Country(ctx context.Context, code string) (models.Country, error) {
+	return get[models.Country](ctx, c, "country", "code", code)
+}
+
+// Players lists the players that match the filter
+func (c *Client) Players(ctx context.Context, filter PlayerFilter) ([]models.Player, error) {
+	var players []models.Player
+	var err error
+
+	// The name route only returns a single player, rather than a list of them
+	if filter.Name != "" {
+		var player models.Player
+		player, err = get[models.Player](ctx, c, "player", "name", filter.Name)
+
+		var missing *exceptions.NoResultsFoundError
+		switch {
+		case errors.As(err, &missing):
+			return []models.Player{}, nil
+		case err == nil:
+			players = []models.Player{player}
+		}
+	} else {
+		players, err = list[models.Player](ctx, c, "player")
+	}
+
+	if err != nil || filter.Country == "" {
+		return players, err
+	}
+
+	matching := []models.Player{}
+	for _, player := range players {
+		if player.Country.Is(filter.Country) {
+			matching = append(matching, player)
+		}
+	}
+	return matching, nil
+}
+
+// Player finds a player by their id
+func (c *Client) Player(ctx context.Context, id int) (models.Player, error) {
+	return get[models.Player](ctx, c, "player", "id", strconv.Itoa(id))
+}
+
+// Matches lists the matches that match the filter, in the order they kick off. The most specific route is used for
+// the request, and the rest of the filter (including the country, which the routes only find by name) is applied to
+// what it returns.
+func (c *Client) Matches(ctx context.Context, filter MatchFilter) ([]models.Match, error) {
+	var parts []string
+
+	switch {
+	case filter.Player != "":
+		parts = []string{"player", "name", filter.Player, "matches"}
+	case filter.Group != "":
+		parts = []string{"match", "group", filter.Group}
+	case filter.Day != 0:
+		parts = []string{"match", "day", strconv.Itoa(filter.Day)}
+	default:
+		parts = []string{"match"}
+	}
+
+	matches, err := list[models.Match](ctx, c, parts...)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []models.Match{}
+	for _, match := range matches {
+		switch {
+		case filter.Country != "" && !match.ACountry.Is(filter.Country) && !match.BCountry.Is(filter.Country):
+		case filter.Group != "" && !strings.EqualFold(match.ACountry.Group, filter.Group) && !strings.EqualFold(match.BCountry.Group, filter.Group):
+		case filter.Day != 0 && match.Day != filter.Day:
+		case filter.Played != nil && match.Played != *filter.Played:
+		default:
+			matching = append(matching, match)
+		}
+	}
+	return matching, nil
+}
+
+// Match finds a match by its id
+func (c *Client) Match(ctx context.Context, id int) (models.Match, error) {
+	return get[models.Match](ctx, c, "match", "id", strconv.Itoa(id))
+}
+
+// Standings ranks the countries in each group, by the group matches that have finished
+func (c *Client) Standings(ctx context.Context) ([]models.GroupStandings, error) {
+	return list[models.GroupStandings](ctx, c, "standings")
+}
+
+// GroupStandings ranks the countries in a single group
+func (c *Client) GroupStandings(ctx context.Context, group string) (models.GroupStandings, error) {
+	return get[models.GroupStandings](ctx, c, "standings", "group", group)
+}
+
+// Ratings lists the changes to the ratings of the countries, in the order the matches were played. With a country
+// (by its name or FIFA code), only the changes to its rating are listed.
+func (c *Client) Ratings(ctx context.Context, country string) ([]models.Rating, error) {
+	if country != "" {
+		return list[models.Rating](ctx, c, "country", "name", country, "ratings")
+	}
+	return list[models.Rating](ctx, c, "ratings")
+}
+
+// Odds are the chances of each country reaching each stage of the tournament, from playing out the rest of it many
+// times over
+func (c *Client) Odds(ctx context.Context) ([]simulate.Odds, error) {
+	return list[simulate.Odds](ctx, c, "predictions", "odds")
+}
+
+// Pool finds a prediction pool, along with its users and rules
+func (c *Client) Pool(ctx context.Context, id int) (models.Pool, error) {
+	return get[models.Pool](ctx, c, "pool", strconv.Itoa(id))
+}
+
+// Leaderboard ranks the users of a pool by their points from the matches that have finished
+func (c *Client) Leaderboard(ctx context.Context, id int) ([]models.Entry, error) {
+	return list[models.Entry](ctx, c, "pool", strconv.Itoa(id), "leaderboard")
+}
+
+// Predict saves a pick for a match in a pool, before the match kicks off. The key has to belong to one of the users
+// of the pool.
+func (c *Client) Predict(ctx context.Context, id int, match int, score utils.Score, winner string) (models.Prediction, error) {
+	body := map[string]any{"match_id": match, "score_a": score.A, "score_b": score.B, "winner": winner}
+	return send[models.Prediction](ctx, c, http.MethodPut, body, "pool", strconv.Itoa(id), "predictions")
+}
+
+// SetScore changes the score of a match that hasn't finished, which needs a scorer api key
+func (c *Client) SetScore(ctx context.Context, id int, score utils.Score, penalties *utils.Score) (models.Match, error) {
+	body := map[string]int{"score_a": score.A, "score_b": score.B}
+	if penalties != nil {
+		body["penalties_a"], body["penalties_b"] = penalties.A, penalties.B
+	}
+
+	return send[models.Match](ctx, c, http.MethodPut, body, "match", "id", strconv.Itoa(id), "score")
+}
+
+// AddEvent records a goal or card in a match that hasn't finished, which needs a scorer api key
+func (c *Client) AddEvent(ctx context.Context, id int, event utils.Event) (models.Event, error) {
+	return send[models.Event](ctx, c, http.MethodPost, event, "match", "id", strconv.Itoa(id), "events")
+}
+
+// Finish marks a match as played, once the goals recorded agree with its score, which needs a scorer api key
+func (c *Client) Finish(ctx context.Context, id int) (models.Match, error) {
+	return send[models.Match](ctx, c, http.MethodPost, nil, "match", "id", strconv.Itoa(id), "finish")
+}
@@ -1,23 +1,108 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/cazier/wc/api"
+	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // apiCmd represents the api command
 var apiCmd = &cobra.Command{
 	Use:   "api",
 	Short: "Start the Rest API",
-	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
-		databaseInit(false)
-		api.Init()
+	Long: `Start the Rest API. The listen address, tls files, timeouts and the gin mode can
+also be set in the [api] section of the configuration file.
+
+The api serves https when both --tls-cert and --tls-key are given. On a SIGINT or
+SIGTERM it stops accepting connections, and waits for the open requests to finish
+before exiting.
+
+The read routes are public, unless --private-reads is set, when they need an api
+key with at least the reader role. Recording the results of the matches always
+needs a scorer or admin key. The keys are managed with the apikey command, and are
+sent as either an "Authorization: Bearer <key>" or "X-API-Key: <key>" header.
+
+Each client (an api key, or an ip address without one) is rate limited separately
+on the read routes and the routes that record results. The X-RateLimit-Limit,
+X-RateLimit-Remaining and X-RateLimit-Reset headers show how many requests are
+left, and a refused request has a Retry-After header. Each ip address can also
+only send so many api keys that aren't valid (api.key_limit) before its keys stop
+being looked up. When the api is behind a proxy, list it in api.trusted_proxies so
+that the client addresses are used.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		readLimit, err := api.ParseLimit(viper.GetString("api.read_limit"))
+		if err != nil {
+			return err
+		}
+
+		writeLimit, err := api.ParseLimit(viper.GetString("api.write_limit"))
+		if err != nil {
+			return err
+		}
+
+		keyLimit, err := api.ParseLimit(viper.GetString("api.key_limit"))
+		if err != nil {
+			return err
+		}
+
+		mode, err := api.ParseMode(viper.GetString("api.mode"))
+		if err != nil {
+			return err
+		}
+
+		// The mode and colours are for every gin engine in the process, so they are set before the server is made
+		gin.SetMode(mode)
+		if viper.GetBool("api.color") {
+			gin.ForceConsoleColor()
+		}
+
+		store, err := databaseInit(false)
+		if err != nil {
+			return err
+		}
+
+		server, err := api.New(store, api.Options{
+			Listen:          viper.GetString("api.listen"),
+			Mode:            mode,
+			TLSCert:         viper.GetString("api.tls_cert"),
+			TLSKey:          viper.GetString("api.tls_key"),
+			ReadTimeout:     viper.GetDuration("api.read_timeout"),
+			WriteTimeout:    viper.GetDuration("api.write_timeout"),
+			ShutdownTimeout: viper.GetDuration("api.shutdown_timeout"),
+			PrivateReads:    viper.GetBool("api.private_reads"),
+			ReadLimit:       readLimit,
+			WriteLimit:      writeLimit,
+			KeyLimit:        keyLimit,
+			CacheTTL:        viper.GetDuration("api.cache_ttl"),
+			TrustedProxies:  viper.GetStringSlice("api.trusted_proxies"),
+			PredictionRuns:  viper.GetInt("api.prediction_runs"),
+		})
+		if err != nil {
+			return err
+		}
+
+		return server.Run()
 	},
 }
 
 func init() {
 	databaseCommand(apiCmd)
+	apiCmd.Flags().String("listen", "0.0.0.0:1213", "address for the api to listen on")
+	apiCmd.Flags().String("tls-cert", "", "certificate file for serving https")
+	apiCmd.Flags().String("tls-key", "", "private key file for serving https")
+	apiCmd.Flags().Duration("read-timeout", 15*time.Second, "longest time to read a request")
+	apiCmd.Flags().Duration("write-timeout", 30*time.Second, "longest time to write a response")
+	apiCmd.Flags().Duration("shutdown-timeout", 30*time.Second, "how long to wait for open requests when stopping")
+	apiCmd.Flags().Bool("private-reads", false, "require a reader api key for the read routes")
+	apiCmd.Flags().String("read-limit", "300/m", "requests each client can make to the read routes (0 for no limit)")
+	apiCmd.Flags().String("write-limit", "60/m", "requests each client can make to record results (0 for no limit)")
+	apiCmd.Flags().String("key-limit", "20/m", "invalid api keys each ip address can send (0 for no limit)")
+	apiCmd.Flags().Duration("cache-ttl", time.Minute, "how long to keep the results of the read queries (0 to not keep them)")
+	apiCmd.Flags().Int("prediction-runs", 10000, "how many times to play out the tournament for /predictions/odds")
 	rootCmd.AddCommand(apiCmd)
 
 	// Here you will define your flags and configuration settings.
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cazier/wc/auth"
+	"github.com/cazier/wc/db/models"
+)
+
+var apikeyName string
+var apikeyRole string
+
+// apikeyCmd represents the apikey command
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage the keys used to access the api",
+	Long: `Manage the api keys. Each key has a role:
+
+  reader  can use the read routes, when they are private (api.private_reads)
+  scorer  can also record the scores, events and results of the matches
+  admin   can do everything
+
+Only a hash of each key is stored, so a key is only shown once, when it is created.`,
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:          "create",
+	Short:        "Create a new api key",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		role, err := models.ParseRole(apikeyRole)
+		if err != nil {
+			return err
+		}
+
+		store, err := databaseInit(false)
+		if err != nil {
+			return err
+		}
+
+		row, key, err := auth.Create(store.DB, apikeyName, role)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("created api key %d (%s) with the %s role\n", row.ID, row.Name, row.Role)
+		fmt.Println("this is the only time the key is shown, so keep it somewhere safe:")
+		fmt.Println(key)
+
+		return nil
+	},
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the api keys",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseRead()
+		if err != nil {
+			return err
+		}
+
+		keys, err := auth.List(store.DB)
+		if err != nil {
+			return err
+		}
+
+		const timestamp = "2006-01-02 15:04:05"
+		for _, key := range keys {
+			used := "never used"
+			if key.LastUsed != nil {
+				used = key.LastUsed.Local().Format(timestamp)
+			}
+
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked " + key.RevokedAt.Local().Format(timestamp)
+			}
+
+			fmt.Printf("%3d  %-11s  %-6s  %-19s  %-27s  %s\n", key.ID, key.Prefix, key.Role, used, status, key.Name)
+		}
+
+		return nil
+	},
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:          "revoke <id>",
+	Short:        "Stop an api key from being used",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("the id must be a number: `%s`", args[0])
+		}
+
+		store, err := databaseInit(false)
+		if err != nil {
+			return err
+		}
+
+		if err := auth.Revoke(store.DB, id); err != nil {
+			return err
+		}
+
+		fmt.Printf("revoked api key %d\n", id)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(apikeyCmd)
+	apikeyCmd.AddCommand(apikeyCreateCmd)
+	apikeyCmd.AddCommand(apikeyListCmd)
+	apikeyCmd.AddCommand(apikeyRevokeCmd)
+
+	databaseCommand(apikeyCmd)
+
+	apikeyCreateCmd.Flags().StringVar(&apikeyName, "name", "", "a name to tell the key apart from the others")
+	apikeyCreateCmd.Flags().StringVar(&apikeyRole, "role", string(models.READER), "the role of the key (reader, scorer or admin)")
+	apikeyCreateCmd.MarkFlagRequired("name")
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configFile string
+
+// The settings in the configuration file, with their defaults. Each of them can also be set with an environment
+// variable, named from the key with a "WC_" prefix, like WC_DATABASE_DRIVER or WC_API_LISTEN.
+var defaults = map[string]any{
+	// sqlite, mysql (which is also used for mariadb) or postgres
+	"database.driver": "sqlite",
+	// The sqlite database file, or a directory to create a wc.db file in. Use "memory" for a temporary database.
+	"database.path": ".",
+	// A complete connection string, used instead of the path (for sqlite) or connection settings (for mysql)
+	"database.dsn": "",
+
+	// The mysql/mariadb or postgres connection settings, where a port of 0 uses the default for the driver.
+	// The password can't be given as a flag, so it is read from the WC_DATABASE_PASSWORD environment variable, this
+	// file, or a file named by password_file.
+	"database.host":          "127.0.0.1",
+	"database.port":          0,
+	"database.user":          "",
+	"database.password":      "",
+	"database.password_file": "",
+	"database.name":          "wc",
+
+	// silent, error, warn or info
+	"log.level": "info",
+	// stdout, or the path to a file that the database logs are added to
+	"log.path": "stdout",
+
+	"api.listen": "0.0.0.0:1213",
+	// debug, release or test
+	"api.mode":  "debug",
+	"api.color": false,
+	// Serve https when both of these are set
+	"api.tls_cert": "",
+	"api.tls_key":  "",
+	// Durations, like "15s" or "1m"
+	"api.read_timeout":     "15s",
+	"api.write_timeout":    "30s",
+	"api.shutdown_timeout": "30s",
+	// Require a reader api key for the read routes, which are otherwise public
+	"api.private_reads": false,
+	// The requests each client (an api key, or an ip address without one) can make, like "300/m" or "20/10s". Use
+	// "0" for no limit.
+	"api.read_limit":  "300/m",
+	"api.write_limit": "60/m",
+	// The api keys that aren't valid each ip address can send, before its keys stop being looked up for a while
+	"api.key_limit": "20/m",
+	// How long the results of the read queries are kept in memory, as a duration. Any change the api makes to the
+	// tables they were read from drops them straight away, but the changes made by another process (like "wc match
+	// score" or "wc db import" on the same database) are only seen once they expire. Use "0" to not keep them.
+	"api.cache_ttl": "1m",
+	// The proxies in front of the api, whose X-Forwarded-For headers are used to find the client's ip address
+	"api.trusted_proxies": []string{},
+	// How many times /predictions/odds plays out the rest of the tournament
+	"api.prediction_runs": 10000,
+
+	// The api the watch command reads the results from, instead of the database, and a reader key for it when its
+	// read routes are private
+	"watch.url": "",
+	"watch.key": "",
+	// How often the watch command refreshes, as a duration
+	"watch.interval": "30s",
+}
+
+// The command line flags that can override a setting, which are bound when the command is run, since many of the
+// commands share the same flag names
+var flags = map[string]string{
+	"database.driver": "driver",
+	"database.path":   "db",
+	"database.dsn":    "dsn",
+
+	"database.host":          "db-host",
+	"database.port":          "db-port",
+	"database.user":          "db-user",
+	"database.password_file": "db-password-file",
+	"database.name":          "db-name",
+
+	"log.level": "log-level",
+	"log.path":  "log-path",
+
+	"api.listen":           "listen",
+	"api.tls_cert":         "tls-cert",
+	"api.tls_key":          "tls-key",
+	"api.read_timeout":     "read-timeout",
+	"api.write_timeout":    "write-timeout",
+	"api.shutdown_timeout": "shutdown-timeout",
+	"api.private_reads":    "private-reads",
+	"api.read_limit":       "read-limit",
+	"api.write_limit":      "write-limit",
+	"api.key_limit":        "key-limit",
+	"api.cache_ttl":        "cache-ttl",
+	"api.prediction_runs":  "prediction-runs",
+
+	"watch.url":      "url",
+	"watch.interval": "interval",
+}
+
+// loadConfig reads the configuration from (in order of precedence) the command line flags, the WC_* environment
+// variables, the config.toml file and the defaults
+func loadConfig(cmd *cobra.Command) error {
+	for key, value := range defaults {
+		viper.SetDefault(key, value)
+	}
+
+	viper.SetEnvPrefix("wc")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("toml")
+		viper.AddConfigPath("$HOME/.config/wc")
+		viper.AddConfigPath(".")
+	}
+
+	// The configuration file is optional, unless one was given with --config
+	var notFound viper.ConfigFileNotFoundError
+	if err := viper.ReadInConfig(); err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("could not read the configuration file: %w", err)
+	}
+
+	for key, name := range flags {
+		if flag := cmd.Flags().Lookup(name); flag != nil {
+			if err := viper.BindPFlag(key, flag); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The older --sqlite flag picks between the two drivers
+	if flag := cmd.Flags().Lookup("sqlite"); flag != nil && flag.Changed {
+		if flag.Value.String() == "true" {
+			viper.Set("database.driver", "sqlite")
+		} else {
+			viper.Set("database.driver", "mysql")
+		}
+	}
+
+	return nil
+}
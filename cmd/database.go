@@ -3,19 +3,36 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/cazier/wc/db"
 	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/seed"
 )
 
-var sqlite bool
-var databasePath string
-
 var importTeamPath string
 var importMatchPath string
 var importPlayerPath string
+var importRatingPath string
+var importPrune bool
+var importDryRun bool
+var importFormat string
+var importHeaders map[string]string
+var importSource string
+
+var seedSeed int64
+var seedStart string
+var seedPlayed int
+var seedPurge bool
+
+var exportFormat string
+var exportDirectory string
 
 // databaseCmd represents the database command
 var databaseCmd = &cobra.Command{
@@ -29,29 +46,265 @@ var initializeCmd = &cobra.Command{
 	Short:   "Initialize an empty database for use",
 	Long: `Create a database with all of its tables. Optionally, you can supply
 a set of import flags to fill the database with values`,
-	Run: func(cmd *cobra.Command, args []string) {
-		databaseInit(true)
-		importCmd.Run(cmd, args)
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseInit(true)
+		if err != nil {
+			return err
+		}
+		return runImport(store, args)
 	},
 }
 
 var importCmd = &cobra.Command{
-	Use:   "import",
-	Short: "Import details from a yaml file into the database",
-	Run: func(cmd *cobra.Command, args []string) {
-		databaseInit(true)
+	Use:   "import [openfootball files...]",
+	Short: "Import details from a yaml, json or csv file into the database",
+	Long: `Import teams, matches, players and ratings from yaml, json or csv files. The
+format is picked from each file extension, unless it is set with --format. Each file
+is checked completely before anything from it is added, and every problem found is
+reported together.
+
+The first row of a csv file holds the field names. Common spreadsheet headers (like
+"FIFA Code" or "Shirt Number") are recognized, and any others can be mapped onto a
+field with --map "Team Name=name".
+
+The whole import runs in a single transaction. Rows that already exist are updated
+in place, matched on their FIFA code (teams), name and country (players), or the
+two countries and the match date (matches). Use --dry-run to see the changes
+without saving them.
+
+A ratings file gives the Elo rating each country had before the tournament, as a
+country (name or FIFA code) and a rating. The ratings are worked out again from
+them, and every result, after each import.
+
+With --source openfootball, the arguments are instead read as openfootball
+worldcup.json or football.txt datasets, adding the teams, matches, results and
+goals from them.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseInit(false)
+		if err != nil {
+			return err
+		}
+		return runImport(store, args)
+	},
+}
+
+// runImport imports the files from the flags (or the openfootball files in the arguments) into the store
+func runImport(store *db.Store, args []string) error {
+	options := load.Options{Prune: importPrune, DryRun: importDryRun, Headers: importHeaders}
+
+	if importFormat != "" {
+		format, err := utils.ParseFormat(importFormat)
+		if err != nil {
+			return err
+		}
+		options.Format = format
+	}
+
+	files := load.Files{Teams: importTeamPath, Matches: importMatchPath, Players: importPlayerPath, Ratings: importRatingPath}
+
+	switch importSource {
+	case "files":
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %v; use --teams, --matches, --players and --ratings", args)
+		}
+	case "openfootball":
+		if len(args) == 0 {
+			return fmt.Errorf("at least one openfootball file is needed")
+		}
+		files.OpenFootball = args
+	default:
+		return fmt.Errorf("unknown import source: `%s`", importSource)
+	}
+
+	changes, err := load.Import(store, files, options)
+	if err != nil {
+		return err
+	}
+
+	if importDryRun {
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		fmt.Println(load.ErrDryRun)
+	}
+
+	for _, line := range changes.Summary() {
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Fill the database with a made up tournament, for demos and load tests",
+	Long: `Make up a whole 32 team tournament: the countries and their groups, a squad of
+23 players for each of them, and the 64 matches. Use --played to also make up the
+results, goals and cards of the first matches (in the order they kick off), where
+the knockout matches are filled in as the rounds before them finish.
+
+The same --seed and --start always make the same tournament. Without a seed, a
+random one is picked and printed, so the tournament can be made again.
+
+The tournament is added in the same way as an import, so use --purge to start from
+an empty database instead.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		if seedStart != "" {
+			var err error
+			if start, err = time.Parse("2006-01-02", seedStart); err != nil {
+				return fmt.Errorf("the start must be a date like 2026-06-11: `%s`", seedStart)
+			}
+		}
+
+		if !cmd.Flags().Changed("seed") {
+			seedSeed = time.Now().UnixNano()
+		}
+
+		store, err := databaseInit(seedPurge)
+		if err != nil {
+			return err
+		}
+
+		tables := seed.Generate(seed.Options{Seed: seedSeed, Start: start, Played: seedPlayed})
+
+		changes, err := load.ImportTables(store, "seed", tables, load.Options{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("seeded a tournament starting on %s with --seed %d\n", start.Format("2006-01-02"), seedSeed)
+		for _, line := range changes.Summary() {
+			fmt.Println(line)
+		}
+
+		return nil
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the database into yaml, json or csv files",
+	Long: `Write the teams, matches (with their results and events), players and the
+ratings from before the tournament from the database into teams, matches, players
+and ratings files in the output directory. The files use the same layout that the
+import command reads, so they can be edited and imported again, or used to copy a
+database.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := utils.ParseFormat(exportFormat)
+		if err != nil {
+			return err
+		}
+
+		store, err := databaseRead()
+		if err != nil {
+			return err
+		}
+
+		tables, err := load.Export(store.DB)
+		if err != nil {
+			return err
+		}
+
+		paths, err := tables.Write(exportDirectory, format)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Exported %d teams, %d matches, %d players and %d ratings\n",
+			len(tables.Teams), len(tables.Matches), len(tables.Players), len(tables.Ratings))
+		for _, path := range paths {
+			fmt.Println(path)
+		}
 
-		if importTeamPath != "" {
-			load.Teams(importTeamPath)
+		return nil
+	},
+}
+
+var migrateTarget int
+var migrateSteps int
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply or revert the numbered schema migrations",
+	Long: `Manage the database schema with numbered migrations, which are recorded in the
+schema_version table. The commands that write to the database apply any pending
+migrations when they connect. The ones that only read from it, like the list and
+show commands, stop instead until "wc db migrate up" has applied them.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:          "up",
+	Short:        "Apply the pending migrations",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseConnect()
+		if err != nil {
+			return err
+		}
+
+		applied, err := db.MigrateUp(store.DB, migrateTarget)
+		for _, migration := range applied {
+			fmt.Printf("applied %d: %s\n", migration.Version, migration.Name)
+		}
+
+		if err == nil && applied == nil {
+			fmt.Println("the database is already up to date")
+		}
+
+		return err
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:          "down",
+	Short:        "Revert the most recent migrations",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseConnect()
+		if err != nil {
+			return err
+		}
+
+		reverted, err := db.MigrateDown(store.DB, migrateSteps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d: %s\n", migration.Version, migration.Name)
+		}
+
+		return err
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:          "status",
+	Short:        "List the migrations, and which have been applied",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseConnect()
+		if err != nil {
+			return err
 		}
 
-		if importMatchPath != "" {
-			load.Matches(importMatchPath)
+		status, err := db.Status(store.DB)
+		if err != nil {
+			return err
 		}
 
-		if importPlayerPath != "" {
-			load.Players(importPlayerPath)
+		for _, migration := range status {
+			applied := "pending"
+			if migration.Applied {
+				applied = migration.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Printf("%3d  %-19s  %s\n", migration.Version, applied, migration.Name)
 		}
+
+		return nil
 	},
 }
 
@@ -59,13 +312,37 @@ func init() {
 	rootCmd.AddCommand(databaseCmd)
 	databaseCmd.AddCommand(initializeCmd)
 	databaseCmd.AddCommand(importCmd)
+	databaseCmd.AddCommand(exportCmd)
+	databaseCmd.AddCommand(seedCmd)
+	databaseCmd.AddCommand(migrateCmd)
+
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+
+	migrateUpCmd.Flags().IntVar(&migrateTarget, "to", 0, "the version to migrate up to (defaults to the latest)")
+	migrateDownCmd.Flags().IntVar(&migrateSteps, "steps", 1, "the number of migrations to revert (-1 for all of them)")
+
+	seedCmd.Flags().Int64Var(&seedSeed, "seed", 0, "the seed to make the tournament from (random by default)")
+	seedCmd.Flags().StringVar(&seedStart, "start", "", "the day of the first match, like 2026-06-11 (defaults to today)")
+	seedCmd.Flags().IntVar(&seedPlayed, "played", 0, "how many of the matches to make up results for (-1 for all of them)")
+	seedCmd.Flags().BoolVar(&seedPurge, "purge", false, "empty the database before adding the tournament")
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "yaml", "format of the exported files (yaml, json or csv)")
+	exportCmd.Flags().StringVarP(&exportDirectory, "output", "o", ".", "directory to write the files into")
 
 	databaseCommand(databaseCmd)
 
 	for _, cmd := range []*cobra.Command{initializeCmd, importCmd} {
-		cmd.Flags().StringVar(&importTeamPath, "teams", "", "team yaml file for importing")
-		cmd.Flags().StringVar(&importMatchPath, "matches", "", "match yaml file for importing")
-		cmd.Flags().StringVar(&importPlayerPath, "players", "", "player yaml file for importing")
+		cmd.Flags().StringVar(&importTeamPath, "teams", "", "team file for importing")
+		cmd.Flags().StringVar(&importMatchPath, "matches", "", "match file for importing")
+		cmd.Flags().StringVar(&importPlayerPath, "players", "", "player file for importing")
+		cmd.Flags().StringVar(&importRatingPath, "ratings", "", "file of the ratings before the tournament for importing")
+		cmd.Flags().StringVar(&importFormat, "format", "", "format of the import files (yaml, json or csv)")
+		cmd.Flags().StringVar(&importSource, "source", "files", "where the data comes from (files or openfootball)")
+		cmd.Flags().StringToStringVar(&importHeaders, "map", nil, "map a csv header onto a field name, e.g. \"Team Name=name\"")
+		cmd.Flags().BoolVar(&importPrune, "prune", false, "remove rows that are missing from the imported files")
+		cmd.Flags().BoolVar(&importDryRun, "dry-run", false, "print the changes without saving them")
 
 		// if cmd == importCmd {
 		// 	// cmd.MarkFlagRequired("teams")
@@ -85,22 +362,119 @@ func init() {
 }
 
 func databaseCommand(cmd *cobra.Command) {
-	cmd.PersistentFlags().StringVar(&databasePath, "db", ".", "path to a database")
-	cmd.Flags().BoolVar(&sqlite, "sqlite", true, "use a sqlite database instead of mariadb")
+	cmd.PersistentFlags().String("db", ".", "path to a sqlite database, or a directory for a wc.db file")
+	cmd.PersistentFlags().String("driver", "sqlite", "database driver (sqlite, mysql or postgres)")
+	cmd.PersistentFlags().String("dsn", "", "database connection string, used instead of the other connection flags")
+	cmd.PersistentFlags().String("db-host", "127.0.0.1", "mariadb or postgres host")
+	cmd.PersistentFlags().Int("db-port", 0, "mariadb or postgres port (defaults to 3306 or 5432)")
+	cmd.PersistentFlags().String("db-user", "", "mariadb or postgres user")
+	cmd.PersistentFlags().String("db-password-file", "", "file holding the database password (or set WC_DATABASE_PASSWORD)")
+	cmd.PersistentFlags().String("db-name", "wc", "mariadb or postgres database name")
+	cmd.PersistentFlags().Bool("sqlite", true, "use a sqlite database instead of mariadb")
+	cmd.PersistentFlags().MarkDeprecated("sqlite", "use --driver instead")
 }
 
-func databaseInit(purge bool) {
-	if sqlite && databasePath == "memory" {
-		db.InitSqlite(&db.SqliteDBOptions{Memory: true})
-	} else if sqlite {
-		info, _ := os.Stat(databasePath)
-		if info.IsDir() {
-			databasePath = fmt.Sprintf("%s/%s", databasePath, "wc.db")
+// databaseInit connects to the database from the configuration, applying any pending migrations
+func databaseInit(purge bool) (*db.Store, error) {
+	store, err := databaseConnect()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, store.LinkTables(purge)
+}
+
+// databaseRead connects to the database from the configuration for a command that only reads from it, so its schema
+// isn't changed, which fails when the schema is behind
+func databaseRead() (*db.Store, error) {
+	store, err := databaseConnect()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.CheckVersion(store.DB); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// databaseConnect connects to the database from the configuration, without changing its schema
+func databaseConnect() (*db.Store, error) {
+	level, err := db.ParseLogLevel(viper.GetString("log.level"))
+	if err != nil {
+		return nil, err
+	}
+	logPath := viper.GetString("log.path")
+	dsn := viper.GetString("database.dsn")
+
+	switch driver := viper.GetString("database.driver"); driver {
+	case "sqlite":
+		path := viper.GetString("database.path")
+		if dsn != "" {
+			path = dsn
 		}
-		db.InitSqlite(&db.SqliteDBOptions{Path: databasePath})
 
-	} else {
-		db.InitMariaDB(&db.MariaDBOptions{})
+		if path == "memory" {
+			return db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: level, LogPath: logPath})
+		}
+
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, "wc.db")
+		}
+		return db.OpenSqlite(&db.SqliteDBOptions{Path: path, LogLevel: level, LogPath: logPath})
+
+	case "mysql", "mariadb":
+		password, err := databasePassword()
+		if err != nil {
+			return nil, err
+		}
+
+		return db.OpenMariaDB(&db.MariaDBOptions{
+			DSN:      dsn,
+			Username: viper.GetString("database.user"),
+			Password: password,
+			Database: viper.GetString("database.name"),
+			Host:     viper.GetString("database.host"),
+			Port:     viper.GetInt("database.port"),
+			LogLevel: level,
+			LogPath:  logPath,
+		})
+
+	case "postgres", "postgresql":
+		password, err := databasePassword()
+		if err != nil {
+			return nil, err
+		}
+
+		return db.OpenPostgres(&db.PostgresOptions{
+			DSN:      dsn,
+			Username: viper.GetString("database.user"),
+			Password: password,
+			Database: viper.GetString("database.name"),
+			Host:     viper.GetString("database.host"),
+			Port:     viper.GetInt("database.port"),
+			LogLevel: level,
+			LogPath:  logPath,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown database driver: `%s`", driver)
 	}
-	db.LinkTables(purge)
+}
+
+// databasePassword reads the mariadb password from the password file when one is set, or otherwise from the
+// configuration (which includes the WC_DATABASE_PASSWORD environment variable)
+func databasePassword() (string, error) {
+	path := viper.GetString("database.password_file")
+	if path == "" {
+		return viper.GetString("database.password"), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read the password file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats the query commands can print their results in
+var outputFormats = []string{"table", "json", "yaml", "csv"}
+
+func parseOutput(s string) (string, error) {
+	for _, format := range outputFormats {
+		if strings.EqualFold(s, format) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format: `%s` (expected %s)", s, strings.Join(outputFormats, ", "))
+}
+
+// cell is a single value in a table, which is coloured when it is printed to a terminal
+type cell struct {
+	text  string
+	color *color.Color
+}
+
+func plain(text string) cell {
+	return cell{text: text}
+}
+
+func colored(text string, attributes ...color.Attribute) cell {
+	return cell{text: text, color: color.New(attributes...)}
+}
+
+type table struct {
+	headers []string
+	rows    [][]cell
+}
+
+func (t *table) add(row ...cell) {
+	t.rows = append(t.rows, row)
+}
+
+// print lines up the columns of the table. The widths are found from the text alone, since the colour codes take up
+// no space on the screen.
+func (t table) print(w io.Writer) {
+	widths := make([]int, len(t.headers))
+	for index, header := range t.headers {
+		widths[index] = utf8.RuneCountInString(header)
+	}
+	for _, row := range t.rows {
+		for index, cell := range row {
+			if length := utf8.RuneCountInString(cell.text); length > widths[index] {
+				widths[index] = length
+			}
+		}
+	}
+
+	line := func(cells []cell) {
+		parts := make([]string, len(cells))
+		for index, cell := range cells {
+			text := cell.text
+			if index < len(cells)-1 {
+				text += strings.Repeat(" ", widths[index]-utf8.RuneCountInString(cell.text))
+			}
+			if cell.color != nil {
+				text = cell.color.Sprint(text)
+			}
+			parts[index] = text
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(parts, "  "), " "))
+	}
+
+	headers := make([]cell, len(t.headers))
+	for index, header := range t.headers {
+		headers[index] = colored(strings.ToUpper(header), color.Bold)
+	}
+
+	line(headers)
+	for _, row := range t.rows {
+		line(row)
+	}
+}
+
+// field is a single labelled value, for showing the details of one item
+type field struct {
+	label string
+	value cell
+}
+
+func printDetails(w io.Writer, fields []field) {
+	width := 0
+	for _, field := range fields {
+		if len(field.label) > width {
+			width = len(field.label)
+		}
+	}
+
+	bold := color.New(color.Bold)
+	for _, field := range fields {
+		text := field.value.text
+		if field.value.color != nil {
+			text = field.value.color.Sprint(text)
+		}
+		fmt.Fprintf(w, "%s  %s\n", bold.Sprintf("%-*s", width, field.label), text)
+	}
+}
+
+func (t table) csv(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(t.headers)
+
+	for _, row := range t.rows {
+		record := make([]string, len(row))
+		for index, cell := range row {
+			record[index] = cell.text
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// output is the result of a query command. The data is written as json or yaml (using the same names as the api), and
+// the table as a csv file or to the terminal, unless there is a more detailed view to show there instead.
+type output struct {
+	data  any
+	table table
+	view  func(w io.Writer)
+}
+
+func (o output) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(o.data)
+	case "yaml":
+		// The models only have json names, so they are written through json to use them in the yaml too
+		var data any
+		encoded, err := json.Marshal(o.data)
+		if err == nil {
+			err = json.Unmarshal(encoded, &data)
+		}
+		if err != nil {
+			return err
+		}
+
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(data); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "csv":
+		return o.table.csv(w)
+	default:
+		if o.view != nil {
+			o.view(w)
+		} else {
+			o.table.print(w)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/pool"
+)
+
+var poolRules models.Rules
+var poolWinner string
+var poolLate bool
+var poolFormat string
+var poolHeaders map[string]string
+
+// poolCmd represents the pool command
+var poolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Run a prediction pool for the matches",
+	Long: `Run a prediction pool, where each user guesses the scores of the matches (and who goes through the
+knockout matches) before they kick off.
+
+Each user is given a reader api key when they join, which they can send their predictions to
+PUT /pool/:id/predictions with. The leaderboard is at /pool/:id/leaderboard.
+
+The points for each match are the best of:
+
+  exact       the exact score
+  difference  the right goal difference
+  result      the right winner, or a draw
+
+and the winner of a knockout match is worth its points on top of that.`,
+}
+
+var poolCreateCmd = &cobra.Command{
+	Use:          "create <name>",
+	Short:        "Create a new pool",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := databaseInit(false)
+		if err != nil {
+			return err
+		}
+
+		created, err := pool.Create(store.DB, args[0], poolRules)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("created pool %d (%s)\n", created.ID, created.Name)
+		return nil
+	},
+}
+
+var poolRulesCmd = &cobra.Command{
+	Use:          "rules <pool>",
+	Short:        "Change how many points the predictions of a pool are worth",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, found, err := poolInit(args[0])
+		if err != nil {
+			return err
+		}
+
+		// Only the rules that were given are changed
+		rules := found.Rules
+		for flag, value := range map[string]*int{
+			"exact":      &rules.Exact,
+			"difference": &rules.Difference,
+			"result":     &rules.Result,
+			"winner":     &rules.Winner,
+		} {
+			if cmd.Flags().Changed(flag) {
+				*value, _ = cmd.Flags().GetInt(flag)
+			}
+		}
+
+		updated, err := pool.SetRules(store.DB, found, rules)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("pool %s: %s\n", updated.Name, describeRules(updated.Rules))
+		return nil
+	},
+}
+
+var poolListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the pools",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			pools, err := pool.List(ctx, store.DB)
+			if err != nil {
+				return output{}, err
+			}
+
+			t := table{headers: []string{"id", "name", "users", "exact", "difference", "result", "winner"}}
+			for _, row := range pools {
+				t.add(
+					plain(strconv.Itoa(row.ID)),
+					colored(row.Name, color.FgCyan),
+					plain(strconv.Itoa(len(row.Users))),
+					plain(strconv.Itoa(row.Rules.Exact)),
+					plain(strconv.Itoa(row.Rules.Difference)),
+					plain(strconv.Itoa(row.Rules.Result)),
+					plain(strconv.Itoa(row.Rules.Winner)),
+				)
+			}
+
+			return output{data: pools, table: t}, nil
+		})
+	},
+}
+
+var poolJoinCmd = &cobra.Command{
+	Use:          "join <pool> <user>",
+	Short:        "Add a user to a pool, with an api key for their predictions",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, found, err := poolInit(args[0])
+		if err != nil {
+			return err
+		}
+
+		user, key, err := pool.Join(store.DB, found, args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("added %s to the %s pool\n", user.Name, found.Name)
+		fmt.Println("this is the only time their api key is shown, so pass it on somewhere safe:")
+		fmt.Println(key)
+
+		return nil
+	},
+}
+
+var poolPredictCmd = &cobra.Command{
+	Use:          "predict <pool> <user> <match> <score>",
+	Short:        "Save a user's prediction for a match",
+	Example:      "  wc pool predict office alex 49 2-1 --winner ARG",
+	Args:         cobra.ExactArgs(4),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := matchId(args[2])
+		if err != nil {
+			return err
+		}
+
+		score, err := utils.ParseScore(args[3])
+		if err != nil {
+			return err
+		}
+
+		store, found, err := poolInit(args[0])
+		if err != nil {
+			return err
+		}
+
+		user, err := pool.FindUser(store.DB, found, args[1])
+		if err != nil {
+			return err
+		}
+
+		prediction, err := pool.Predict(store.DB, user, pool.Pick{MatchID: id, Score: *score, Winner: poolWinner})
+		if err != nil {
+			return err
+		}
+
+		winner := ""
+		if prediction.Winner != nil {
+			winner = fmt.Sprintf(", with %s going through", prediction.Winner.Name)
+		}
+
+		fmt.Printf("%s predicted %d-%d for match %d%s\n", user.Name, prediction.AScore, prediction.BScore, id, winner)
+		return nil
+	},
+}
+
+var poolImportCmd = &cobra.Command{
+	Use:   "import <pool> <file>",
+	Short: "Import the predictions of a pool from a file",
+	Long: `Import the predictions of a pool from a yaml, json or csv file, with a user, match and score on
+each row (and a winner for the knockout matches). The users are added to the pool when they
+aren't in it yet, without an api key.
+
+Nothing is saved if any of the predictions can't be, which includes those for matches that
+have kicked off, unless --late is given.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := utils.Source{Path: args[1], Headers: poolHeaders}
+		if poolFormat != "" {
+			format, err := utils.ParseFormat(poolFormat)
+			if err != nil {
+				return err
+			}
+			source.Format = format
+		}
+
+		predictions, err := utils.LoadPredictionsFrom(source)
+		if err != nil {
+			return err
+		}
+
+		store, found, err := poolInit(args[0])
+		if err != nil {
+			return err
+		}
+
+		saved, err := pool.Import(store.DB, found, predictions, poolLate)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("imported %d predictions into the %s pool\n", saved, found.Name)
+		return nil
+	},
+}
+
+var poolLeaderboardCmd = &cobra.Command{
+	Use:          "leaderboard <pool>",
+	Short:        "Show the leaderboard of a pool",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			found, err := pool.Find(ctx, store.DB, args[0])
+			if err != nil {
+				return output{}, err
+			}
+
+			entries, err := pool.Leaderboard(ctx, store.DB, found)
+			if err != nil {
+				return output{}, err
+			}
+
+			t := table{headers: []string{"rank", "user", "points", "exact", "winners", "predicted"}}
+			for _, entry := range entries {
+				t.add(
+					plain(strconv.Itoa(entry.Rank)),
+					colored(entry.User.Name, color.FgCyan),
+					colored(strconv.Itoa(entry.Points), color.Bold),
+					plain(strconv.Itoa(entry.Exact)),
+					plain(strconv.Itoa(entry.Winners)),
+					plain(strconv.Itoa(entry.Predicted)),
+				)
+			}
+
+			return output{data: entries, table: t}, nil
+		})
+	},
+}
+
+// poolInit opens the database and finds the pool by its id or name
+func poolInit(text string) (*db.Store, models.Pool, error) {
+	store, err := databaseInit(false)
+	if err != nil {
+		return nil, models.Pool{}, err
+	}
+
+	found, err := pool.Find(context.Background(), store.DB, text)
+	return store, found, err
+}
+
+func describeRules(rules models.Rules) string {
+	return strings.Join([]string{
+		fmt.Sprintf("%d for the exact score", rules.Exact),
+		fmt.Sprintf("%d for the goal difference", rules.Difference),
+		fmt.Sprintf("%d for the result", rules.Result),
+		fmt.Sprintf("%d for the winner of a knockout match", rules.Winner),
+	}, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(poolCmd)
+	poolCmd.AddCommand(poolCreateCmd)
+	poolCmd.AddCommand(poolRulesCmd)
+	poolCmd.AddCommand(poolListCmd)
+	poolCmd.AddCommand(poolJoinCmd)
+	poolCmd.AddCommand(poolPredictCmd)
+	poolCmd.AddCommand(poolImportCmd)
+	poolCmd.AddCommand(poolLeaderboardCmd)
+
+	databaseCommand(poolCmd)
+
+	for _, cmd := range []*cobra.Command{poolCreateCmd, poolRulesCmd} {
+		cmd.Flags().IntVar(&poolRules.Exact, "exact", models.DEFAULT_RULES.Exact, "points for the exact score")
+		cmd.Flags().IntVar(&poolRules.Difference, "difference", models.DEFAULT_RULES.Difference, "points for the right goal difference")
+		cmd.Flags().IntVar(&poolRules.Result, "result", models.DEFAULT_RULES.Result, "points for the right winner, or a draw")
+		cmd.Flags().IntVar(&poolRules.Winner, "winner", models.DEFAULT_RULES.Winner, "extra points for who goes through a knockout match")
+	}
+
+	poolPredictCmd.Flags().StringVar(&poolWinner, "winner", "", "who goes through a knockout match, by name or FIFA code")
+
+	poolImportCmd.Flags().BoolVar(&poolLate, "late", false, "also import the predictions for matches that have kicked off")
+	poolImportCmd.Flags().StringVar(&poolFormat, "format", "", "format of the file (yaml, json or csv)")
+	poolImportCmd.Flags().StringToStringVar(&poolHeaders, "map", nil, "map a csv header onto a field name, e.g. \"Player=user\"")
+
+	for _, cmd := range []*cobra.Command{poolListCmd, poolLeaderboardCmd} {
+		cmd.Flags().StringVarP(&queryOutput, "output", "o", "table", "how to print the results (table, json, yaml or csv)")
+	}
+}
@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
+)
+
+var queryOutput string
+
+var countryGroup string
+var countryName string
+
+var playerName string
+var playerCountry string
+
+var matchCountry string
+var matchPlayer string
+var matchGroup string
+var matchStage string
+var matchDay int
+var matchPlayed bool
+var matchUpcoming bool
+
+var countryCmd = &cobra.Command{
+	Use:   "country",
+	Short: "Look up the countries in the database",
+}
+
+var countryListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the countries",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			countries, err := query.FindCountries(ctx, store.DB, query.CountryFilter{Group: countryGroup, Name: countryName})
+			return output{data: countries, table: countryTable(countries)}, err
+		})
+	},
+}
+
+var countryShowCmd = &cobra.Command{
+	Use:          "show <id, code or name>",
+	Short:        "Show a single country",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			country, err := findCountry(ctx, store, args[0])
+			if err != nil {
+				return output{}, err
+			}
+
+			view := func(w io.Writer) {
+				printDetails(w, []field{
+					{"id", plain(strconv.Itoa(country.ID))},
+					{"name", colored(country.Name, color.FgCyan)},
+					{"code", colored(country.FifaCode, color.FgYellow)},
+					{"group", plain(country.Group)},
+				})
+			}
+
+			return output{data: country, table: countryTable([]models.Country{country}), view: view}, nil
+		})
+	},
+}
+
+var playerCmd = &cobra.Command{
+	Use:   "player",
+	Short: "Look up the players in the database",
+}
+
+var playerListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the players",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			players, err := query.FindPlayers(ctx, store.DB, query.PlayerFilter{Name: playerName, Country: playerCountry})
+			return output{data: players, table: playerTable(players)}, err
+		})
+	},
+}
+
+var playerShowCmd = &cobra.Command{
+	Use:          "show <id or name>",
+	Short:        "Show a single player",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			filter := query.PlayerFilter{Name: args[0]}
+			if id, err := strconv.Atoi(args[0]); err == nil {
+				filter = query.PlayerFilter{ID: id}
+			}
+
+			players, err := query.FindPlayers(ctx, store.DB, filter)
+			if err != nil {
+				return output{}, err
+			}
+
+			switch {
+			case len(players) == 0:
+				return output{}, fmt.Errorf("no player matches `%s`", args[0])
+			case len(players) > 1:
+				return output{}, fmt.Errorf("%d players match `%s`; use the id of one of them instead", len(players), args[0])
+			}
+
+			player := players[0]
+			view := func(w io.Writer) {
+				printDetails(w, []field{
+					{"id", plain(strconv.Itoa(player.ID))},
+					{"name", colored(player.Name, color.FgCyan)},
+					{"country", plain(player.Country.Name)},
+					{"position", plain(player.Position)},
+					{"number", plain(number(player.Number))},
+					{"goals", plain(strconv.Itoa(int(player.Goals)))},
+					{"yellows", colored(strconv.Itoa(int(player.Yellow)), color.FgYellow)},
+					{"reds", colored(strconv.Itoa(int(player.Red)), color.FgRed)},
+				})
+			}
+
+			return output{data: player, table: playerTable(players), view: view}, nil
+		})
+	},
+}
+
+var matchCmd = &cobra.Command{
+	Use:   "match",
+	Short: "Look up the matches in the database, and record their results",
+	Long: `Look up the matches in the database, and record their scores, goals and cards as
+they are played, with the same rules as the api.
+
+A running api keeps the results it has already read until its cache_ttl passes,
+since it can't see the changes made from the command line straight away.`,
+}
+
+var matchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the matches, in the order they kick off",
+	Long: `List the matches, in the order they kick off. The country can be either its name
+or FIFA code, and the names of the countries and players can use the % and _
+wildcards.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := query.MatchFilter{Country: matchCountry, Player: matchPlayer, Group: matchGroup, Day: matchDay}
+
+		if matchStage != "" {
+			stage, err := models.ParseStage(strings.ToUpper(strings.ReplaceAll(matchStage, " ", "_")))
+			if err != nil {
+				return err
+			}
+			filter.Stage = &stage
+		}
+
+		if matchPlayed || matchUpcoming {
+			filter.Played = &matchPlayed
+		}
+
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			matches, err := query.FindMatches(ctx, store.DB, filter)
+			return output{data: matches, table: matchTable(matches)}, err
+		})
+	},
+}
+
+var matchShowCmd = &cobra.Command{
+	Use:          "show <id>",
+	Short:        "Show a single match, along with its goals and cards",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := matchId(args[0])
+		if err != nil {
+			return err
+		}
+
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			matches, err := query.FindMatches(ctx, store.DB, query.MatchFilter{ID: id, Events: true})
+			if err != nil {
+				return output{}, err
+			}
+			if len(matches) == 0 {
+				return output{}, fmt.Errorf("no match has the id %d", id)
+			}
+
+			match := matches[0]
+			return output{data: match, table: matchTable(matches), view: func(w io.Writer) { printMatch(w, match) }}, nil
+		})
+	},
+}
+
+// runQuery checks the output format, opens the database, and writes out what the query finds
+func runQuery(run func(ctx context.Context, store *db.Store) (output, error)) error {
+	format, err := parseOutput(queryOutput)
+	if err != nil {
+		return err
+	}
+
+	// The database logs would be mixed in with the results, so they are only shown when a log level is chosen
+	viper.SetDefault("log.level", "silent")
+
+	store, err := databaseRead()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	result, err := run(context.Background(), store)
+	if err != nil {
+		return err
+	}
+
+	return result.write(os.Stdout, format)
+}
+
+// findCountry looks up a country by its id, FIFA code or name, in that order
+func findCountry(ctx context.Context, store *db.Store, text string) (models.Country, error) {
+	filters := []query.CountryFilter{{Code: text, Limit: 1}, {Name: text, Limit: 1}}
+	if id, err := strconv.Atoi(text); err == nil {
+		filters = []query.CountryFilter{{ID: id}}
+	}
+
+	for _, filter := range filters {
+		filter.Placeholders = true
+
+		countries, err := query.FindCountries(ctx, store.DB, filter)
+		if err != nil {
+			return models.Country{}, err
+		}
+		if len(countries) > 0 {
+			return countries[0], nil
+		}
+	}
+
+	return models.Country{}, fmt.Errorf("no country matches `%s`", text)
+}
+
+func countryTable(countries []models.Country) table {
+	t := table{headers: []string{"id", "name", "code", "group", "rating"}}
+	for _, country := range countries {
+		t.add(
+			plain(strconv.Itoa(country.ID)),
+			colored(country.Name, color.FgCyan),
+			colored(country.FifaCode, color.FgYellow),
+			plain(country.Group),
+			plain(strconv.FormatFloat(country.Rating, 'f', 0, 64)),
+		)
+	}
+	return t
+}
+
+func playerTable(players []models.Player) table {
+	t := table{headers: []string{"id", "name", "country", "position", "number", "goals", "yellows", "reds"}}
+	for _, player := range players {
+		t.add(
+			plain(strconv.Itoa(player.ID)),
+			colored(player.Name, color.FgCyan),
+			plain(player.Country.Name),
+			plain(player.Position),
+			plain(number(player.Number)),
+			plain(strconv.Itoa(int(player.Goals))),
+			colored(strconv.Itoa(int(player.Yellow)), color.FgYellow),
+			colored(strconv.Itoa(int(player.Red)), color.FgRed),
+		)
+	}
+	return t
+}
+
+func matchTable(matches []models.Match) table {
+	t := table{headers: []string{"id", "day", "stage", "kickoff", "country a", "score", "country b", "status"}}
+	for _, match := range matches {
+		t.add(
+			plain(strconv.Itoa(match.ID)),
+			plain(strconv.Itoa(match.Day)),
+			plain(stage(match.Stage)),
+			plain(match.When.Local().Format("2006-01-02 15:04")),
+			colored(match.ACountry.Name, color.FgCyan),
+			score(match),
+			colored(match.BCountry.Name, color.FgCyan),
+			status(match),
+		)
+	}
+	return t
+}
+
+// printMatch shows the details of a match, followed by its events
+func printMatch(w io.Writer, match models.Match) {
+	printDetails(w, []field{
+		{"id", plain(strconv.Itoa(match.ID))},
+		{"match", plain(fmt.Sprintf("%s v %s", match.ACountry.Name, match.BCountry.Name))},
+		{"stage", plain(stage(match.Stage))},
+		{"kickoff", plain(match.When.Local().Format("Mon 2 Jan 2006 15:04 MST"))},
+		{"score", score(match)},
+		{"status", status(match)},
+	})
+
+	if len(match.Events) == 0 {
+		return
+	}
+
+	t := table{headers: []string{"minute", "event", "country", "player", "notes"}}
+	for _, event := range match.Events {
+		minute := fmt.Sprintf("%d'", event.Minute)
+		if event.Offset > 0 {
+			minute = fmt.Sprintf("%d+%d'", event.Minute, event.Offset)
+		}
+
+		var notes []string
+		if event.Penalty {
+			notes = append(notes, "penalty")
+		}
+		if event.OwnGoal {
+			notes = append(notes, "own goal")
+		}
+
+		kind := map[models.EventKind]cell{
+			models.GOAL:   colored(string(event.Kind), color.FgGreen),
+			models.YELLOW: colored(string(event.Kind), color.FgYellow),
+			models.RED:    colored(string(event.Kind), color.FgRed),
+		}[event.Kind]
+
+		t.add(plain(minute), kind, plain(event.Country.Name), plain(event.Player), plain(strings.Join(notes, ", ")))
+	}
+
+	fmt.Fprintln(w)
+	t.print(w)
+}
+
+// stage names the stage of a match, like "round of sixteen"
+func stage(s models.Stage) string {
+	return strings.ToLower(strings.ReplaceAll(s.String(), "_", " "))
+}
+
+// number is a player's shirt number, which is -1 when it isn't known
+func number(n int) string {
+	if n < 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// score is the score of a match that has started, along with the penalties when there were any
+func score(match models.Match) cell {
+	if !match.Played && time.Now().Before(match.When) {
+		return plain("-")
+	}
+
+	text := fmt.Sprintf("%d-%d", match.AScore, match.BScore)
+	if match.APenalties != 0 || match.BPenalties != 0 {
+		text += fmt.Sprintf(" (%d-%d pens)", match.APenalties, match.BPenalties)
+	}
+
+	if match.Played {
+		return colored(text, color.Bold)
+	}
+	return colored(text, color.FgYellow)
+}
+
+func status(match models.Match) cell {
+	now := time.Now()
+
+	switch {
+	case match.Played:
+		return colored("finished", color.FgGreen)
+	case now.Before(match.When):
+		return plain("upcoming")
+	case now.Before(match.When.Add(models.LiveWindow)):
+		return colored("playing", color.FgYellow, color.Bold)
+	default:
+		return colored("awaiting result", color.FgMagenta)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(countryCmd)
+	countryCmd.AddCommand(countryListCmd)
+	countryCmd.AddCommand(countryShowCmd)
+
+	rootCmd.AddCommand(playerCmd)
+	playerCmd.AddCommand(playerListCmd)
+	playerCmd.AddCommand(playerShowCmd)
+
+	rootCmd.AddCommand(matchCmd)
+	matchCmd.AddCommand(matchListCmd)
+	matchCmd.AddCommand(matchShowCmd)
+
+	for _, cmd := range []*cobra.Command{countryCmd, playerCmd, matchCmd} {
+		databaseCommand(cmd)
+	}
+
+	for _, cmd := range []*cobra.Command{countryListCmd, countryShowCmd, playerListCmd, playerShowCmd, matchListCmd, matchShowCmd} {
+		cmd.Flags().StringVarP(&queryOutput, "output", "o", "table", "how to print the results (table, json, yaml or csv)")
+	}
+
+	countryListCmd.Flags().StringVar(&countryGroup, "group", "", "only list the countries in the group")
+	countryListCmd.Flags().StringVar(&countryName, "name", "", "only list the countries with a matching name")
+
+	playerListCmd.Flags().StringVar(&playerName, "name", "", "only list the players with a matching name")
+	playerListCmd.Flags().StringVar(&playerCountry, "country", "", "only list the players from the country (a name or FIFA code)")
+
+	matchListCmd.Flags().StringVar(&matchCountry, "country", "", "only list the matches of the country (a name or FIFA code)")
+	matchListCmd.Flags().StringVar(&matchPlayer, "player", "", "only list the matches of the player's country")
+	matchListCmd.Flags().StringVar(&matchGroup, "group", "", "only list the matches in the group")
+	matchListCmd.Flags().StringVar(&matchStage, "stage", "", "only list the matches in the stage (like group or final)")
+	matchListCmd.Flags().IntVar(&matchDay, "day", 0, "only list the matches on the match day")
+	matchListCmd.Flags().BoolVar(&matchPlayed, "played", false, "only list the matches that have finished")
+	matchListCmd.Flags().BoolVar(&matchUpcoming, "upcoming", false, "only list the matches that haven't finished")
+	matchListCmd.MarkFlagsMutuallyExclusive("played", "upcoming")
+}
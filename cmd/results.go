@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
+	"github.com/cazier/wc/db/scoring"
+)
+
+var scorePenalties string
+
+var eventPlayer string
+var eventCountry string
+var eventMinute string
+var eventPenalty bool
+var eventOwnGoal bool
+
+var matchScoreCmd = &cobra.Command{
+	Use:   "score <id> <score>",
+	Short: "Set the score of a match that hasn't finished, like 2-1",
+	Long: `Set the score of a match that hasn't finished, like 2-1. The penalties of a knockout
+match that is level can be given with --penalties.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := matchId(args[0])
+		if err != nil {
+			return err
+		}
+
+		score, err := utils.ParseScore(args[1])
+		if err != nil {
+			return err
+		}
+
+		var penalties *utils.Score
+		if scorePenalties != "" {
+			if penalties, err = utils.ParseScore(scorePenalties); err != nil {
+				return err
+			}
+		}
+
+		return runResult(id, func(ctx context.Context, store *db.Store) error {
+			_, err := scoring.SetScore(store.DB, id, *score, penalties)
+			return err
+		})
+	},
+}
+
+var matchEventCmd = &cobra.Command{
+	Use:   "event <id> <goal, yellow or red>",
+	Short: "Record a goal or card in a match that hasn't finished",
+	Long: `Record a goal or card in a match that hasn't finished, and update the totals of the
+player. The minute can include the stoppage time, like 90+3.
+
+The country (a name or FIFA code) is found from the player's squad when it isn't
+given with --country. An own goal counts for the other country in the match.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := matchId(args[0])
+		if err != nil {
+			return err
+		}
+
+		event := utils.Event{
+			Kind:    models.EventKind(strings.ToLower(args[1])),
+			Country: eventCountry,
+			Player:  eventPlayer,
+			Penalty: eventPenalty,
+			OwnGoal: eventOwnGoal,
+		}
+
+		if event.Minute, event.Offset, err = utils.ParseMinute(eventMinute); err != nil {
+			return err
+		}
+
+		return runResult(id, func(ctx context.Context, store *db.Store) error {
+			if event.Country == "" {
+				if event.Country, err = squad(ctx, store, id, event); err != nil {
+					return err
+				}
+			}
+
+			_, err := scoring.AddEvent(store.DB, id, event)
+			return err
+		})
+	},
+}
+
+var matchFinishCmd = &cobra.Command{
+	Use:   "finish <id>",
+	Short: "Mark a match as played, once its score is complete",
+	Long: `Mark a match as played, once its score is complete. A knockout match needs a
+winner, and any goals that were recorded must add up to the score.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := matchId(args[0])
+		if err != nil {
+			return err
+		}
+
+		return runResult(id, func(ctx context.Context, store *db.Store) error {
+			_, err := scoring.Finish(store.DB, id)
+			return err
+		})
+	},
+}
+
+// runResult records a result with the same rules as the api, and then shows the match as it is now
+func runResult(id int, record func(ctx context.Context, store *db.Store) error) error {
+	return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+		var missing *exceptions.NoResultsFoundError
+		if err := record(ctx, store); errors.As(err, &missing) {
+			return output{}, fmt.Errorf("no match has the id %d", id)
+		} else if err != nil {
+			return output{}, err
+		}
+
+		matches, err := query.FindMatches(ctx, store.DB, query.MatchFilter{ID: id, Events: true})
+		if err != nil {
+			return output{}, err
+		}
+
+		match := matches[0]
+		return output{data: match, table: matchTable(matches), view: func(w io.Writer) { printMatch(w, match) }}, nil
+	})
+}
+
+func matchId(text string) (int, error) {
+	id, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("the id must be a number: `%s`", text)
+	}
+	return id, nil
+}
+
+// squad finds the country of an event from the player's squad, which has to be one of the countries in the match
+func squad(ctx context.Context, store *db.Store, id int, event utils.Event) (string, error) {
+	matches, err := query.FindMatches(ctx, store.DB, query.MatchFilter{ID: id})
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no match has the id %d", id)
+	}
+	match := matches[0]
+
+	players, err := query.FindPlayers(ctx, store.DB, query.PlayerFilter{Name: event.Player})
+	if err != nil {
+		return "", err
+	}
+
+	var countries []models.Country
+	for _, player := range players {
+		if player.Country.ID == match.AID || player.Country.ID == match.BID {
+			countries = append(countries, player.Country)
+		}
+	}
+
+	switch {
+	case len(countries) == 0:
+		return "", fmt.Errorf("`%s` isn't in the squad of either country; give the country with --country", event.Player)
+	case len(countries) > 1:
+		return "", fmt.Errorf("%d players in the match are named `%s`; give the country with --country", len(countries), event.Player)
+	}
+
+	// An own goal counts for the other country
+	switch {
+	case !event.OwnGoal:
+		return countries[0].FifaCode, nil
+	case countries[0].ID == match.AID:
+		return match.BCountry.FifaCode, nil
+	default:
+		return match.ACountry.FifaCode, nil
+	}
+}
+
+func init() {
+	matchCmd.AddCommand(matchScoreCmd)
+	matchCmd.AddCommand(matchEventCmd)
+	matchCmd.AddCommand(matchFinishCmd)
+
+	for _, cmd := range []*cobra.Command{matchScoreCmd, matchEventCmd, matchFinishCmd} {
+		cmd.Flags().StringVarP(&queryOutput, "output", "o", "table", "how to print the match afterwards (table, json, yaml or csv)")
+	}
+
+	matchScoreCmd.Flags().StringVar(&scorePenalties, "penalties", "", "the penalties of a knockout match that is level, like 4-3")
+
+	matchEventCmd.Flags().StringVar(&eventPlayer, "player", "", "the name of the player")
+	matchEventCmd.Flags().StringVar(&eventCountry, "country", "", "the country of the event, when it isn't found from the player's squad")
+	matchEventCmd.Flags().StringVar(&eventMinute, "minute", "", "the minute of the event, like 57 or 90+3")
+	matchEventCmd.Flags().BoolVar(&eventPenalty, "penalty", false, "the goal was a penalty")
+	matchEventCmd.Flags().BoolVar(&eventOwnGoal, "own-goal", false, "the goal was an own goal")
+	matchEventCmd.MarkFlagRequired("player")
+	matchEventCmd.MarkFlagRequired("minute")
+}
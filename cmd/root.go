@@ -21,6 +21,9 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig(cmd)
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -47,7 +50,9 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.wc.yaml)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default is $HOME/.config/wc/config.toml or ./config.toml)")
+	rootCmd.PersistentFlags().String("log-level", "", "database log level (silent, error, warn or info)")
+	rootCmd.PersistentFlags().String("log-path", "", "file to write the database logs to, or stdout")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
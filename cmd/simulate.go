@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/simulate"
+)
+
+var simulateRuns int
+var simulateSeed int64
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Play out the rest of the tournament many times, to find each country's chances",
+	Long: `Play out the matches that haven't been played yet many times over, keeping the
+results so far, and show how likely each country is to get out of its group, reach
+each round of the knockouts, and win the final.
+
+The goals in each match are made up from the ratings of the two countries. The
+knockout matches that don't have their countries yet are filled in from the group
+tables (the winners of group A play the runners up of group B, and so on) and the
+rounds before them.
+
+The same --seed gives the same odds for the same results. Without a seed, a random
+one is picked.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if simulateRuns <= 0 {
+			return fmt.Errorf("the runs must be more than 0: %d", simulateRuns)
+		}
+
+		if !cmd.Flags().Changed("seed") {
+			simulateSeed = time.Now().UnixNano()
+		}
+
+		return runQuery(func(ctx context.Context, store *db.Store) (output, error) {
+			odds, err := simulate.Load(ctx, store.DB, simulate.Options{Runs: simulateRuns, Seed: simulateSeed})
+			return output{data: odds, table: oddsTable(odds)}, err
+		})
+	},
+}
+
+func oddsTable(odds []simulate.Odds) table {
+	t := table{headers: []string{"country", "group", "rating", "advance", "quarterfinals", "semifinals", "final", "champion"}}
+	for _, row := range odds {
+		t.add(
+			colored(row.Country.Name, color.FgCyan),
+			plain(row.Country.Group),
+			plain(strconv.FormatFloat(row.Rating, 'f', 0, 64)),
+			chance(row.Advance),
+			chance(row.Quarterfinals),
+			chance(row.Semifinals),
+			chance(row.Final),
+			colored(percent(row.Champion), color.Bold),
+		)
+	}
+	return t
+}
+
+// chance shows a probability as a percentage, greyed out once it's settled either way
+func chance(probability float64) cell {
+	if probability == 0 || probability == 1 {
+		return colored(percent(probability), color.Faint)
+	}
+	return plain(percent(probability))
+}
+
+func percent(probability float64) string {
+	return strconv.FormatFloat(probability*100, 'f', 1, 64) + "%"
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+	databaseCommand(simulateCmd)
+
+	simulateCmd.Flags().IntVar(&simulateRuns, "runs", 10000, "how many times to play out the tournament")
+	simulateCmd.Flags().Int64Var(&simulateSeed, "seed", 0, "the seed for the made up results (random by default)")
+	simulateCmd.Flags().StringVarP(&queryOutput, "output", "o", "table", "how to print the odds (table, json, yaml or csv)")
+}
@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cazier/wc/client"
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
+	"github.com/cazier/wc/db/standings"
+)
+
+var watchDate string
+var watchOnce bool
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Show a scoreboard of the day's matches and the group tables, which keeps itself up to date",
+	Long: `Show a scoreboard of the day's matches, their live scores, and the tables of the
+groups playing in them, refreshed every --interval until it is stopped with ctrl-c.
+
+The results are read from the local database, or from a running api when --url is
+given. When the api's read routes are private, its reader key is read from the
+WC_WATCH_KEY environment variable (or watch.key in the configuration file).
+
+On a day without any matches, the next matches to be played are shown instead.
+The tables of all the groups are shown once none of the matches are in the group
+stage.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Without a date, the day is found again on each refresh, so a scoreboard left open past midnight moves on
+		var date *time.Time
+		if watchDate != "" {
+			day, err := time.ParseInLocation("2006-01-02", watchDate, time.Local)
+			if err != nil {
+				return fmt.Errorf("the date must look like 2022-11-20: `%s`", watchDate)
+			}
+			date = &day
+		}
+
+		interval := viper.GetDuration("watch.interval")
+		if interval <= 0 && !watchOnce {
+			return fmt.Errorf("the interval must be more than 0: `%s`", viper.GetString("watch.interval"))
+		}
+
+		source, err := watchSource()
+		if err != nil {
+			return err
+		}
+		defer source.close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// The screen is only cleared on a terminal, so the output can still be piped somewhere else
+		clear := false
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			clear = true
+		}
+
+		var last scoreboard
+		for {
+			board, err := source.read(ctx)
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			// A failed refresh keeps showing the last results, since the api might only be restarting
+			if err != nil {
+				if watchOnce {
+					return err
+				}
+				last.problem = err
+			} else {
+				last = board
+			}
+
+			var buffer bytes.Buffer
+			if clear {
+				buffer.WriteString("\033[H\033[2J")
+			}
+			now := time.Now()
+			day := now
+			if date != nil {
+				day = *date
+			}
+
+			last.print(&buffer, day, now)
+			os.Stdout.Write(buffer.Bytes())
+
+			if watchOnce {
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+		}
+	},
+}
+
+// scoreboard is everything read for one refresh of the watch command
+type scoreboard struct {
+	matches   []models.Match
+	standings []models.GroupStandings
+
+	// The error from the latest refresh, when it failed
+	problem error
+}
+
+// A boardSource reads the scoreboard, either from the database or a running api
+type boardSource interface {
+	read(ctx context.Context) (scoreboard, error)
+	close()
+}
+
+type databaseBoard struct {
+	store *db.Store
+}
+
+func (d databaseBoard) read(ctx context.Context) (scoreboard, error) {
+	matches, err := query.FindMatches(ctx, d.store.DB, query.MatchFilter{})
+	if err != nil {
+		return scoreboard{}, err
+	}
+
+	tables, err := standings.Load(ctx, d.store.DB)
+	return scoreboard{matches: matches, standings: tables}, err
+}
+
+func (d databaseBoard) close() {
+	d.store.Close()
+}
+
+type apiBoard struct {
+	client *client.Client
+}
+
+func (a apiBoard) read(ctx context.Context) (scoreboard, error) {
+	matches, err := a.client.Matches(ctx, client.MatchFilter{})
+	if err != nil {
+		return scoreboard{}, err
+	}
+
+	tables, err := a.client.Standings(ctx)
+	return scoreboard{matches: matches, standings: tables}, err
+}
+
+func (a apiBoard) close() {}
+
+// watchSource picks where the scoreboard is read from, which is the api when its url is set
+func watchSource() (boardSource, error) {
+	if url := viper.GetString("watch.url"); url != "" {
+		// The refreshes already try again, so the client only needs a single attempt at each
+		c, err := client.New(url, client.Options{Key: viper.GetString("watch.key"), Retries: -1})
+		if err != nil {
+			return nil, err
+		}
+		return apiBoard{client: c}, nil
+	}
+
+	// The database logs would be mixed in with the scoreboard, so they are only shown when a log level is chosen
+	viper.SetDefault("log.level", "silent")
+
+	store, err := databaseRead()
+	if err != nil {
+		return nil, err
+	}
+	return databaseBoard{store: store}, nil
+}
+
+// print shows the matches on the day, or the next ones when there aren't any, followed by the tables of the groups
+// playing in them
+func (s scoreboard) print(w io.Writer, day, now time.Time) {
+	bold := color.New(color.Bold)
+	bold.Fprintf(w, "World Cup, %s", day.Format("Monday 2 January 2006"))
+	fmt.Fprintf(w, "  (updated %s)\n\n", now.Format("15:04:05"))
+
+	matches := matchesOn(s.matches, day)
+	if len(matches) == 0 {
+		if matches = nextMatches(s.matches, day); len(matches) > 0 {
+			fmt.Fprintf(w, "No matches on the day. The next are on %s:\n\n", matches[0].When.Local().Format("Monday 2 January 2006"))
+		} else {
+			fmt.Fprintln(w, "No matches on the day, and none still to come.")
+		}
+	}
+
+	if len(matches) > 0 {
+		t := table{headers: []string{"kickoff", "stage", "country a", "score", "country b", "status"}}
+		for _, match := range matches {
+			t.add(
+				plain(match.When.Local().Format("15:04")),
+				plain(stage(match.Stage)),
+				colored(match.ACountry.Name, color.FgCyan),
+				score(match),
+				colored(match.BCountry.Name, color.FgCyan),
+				status(match),
+			)
+		}
+		t.print(w)
+	}
+
+	// Only the groups with one of the matches are shown, unless there aren't any
+	groups := make(map[string]bool)
+	for _, match := range matches {
+		if match.Stage == models.GROUP {
+			groups[match.ACountry.Group] = true
+		}
+	}
+
+	for _, group := range s.standings {
+		if len(groups) > 0 && !groups[group.Group] {
+			continue
+		}
+
+		fmt.Fprintln(w)
+		groupTable(group, matches).print(w)
+	}
+
+	if s.problem != nil {
+		fmt.Fprintln(w)
+		color.New(color.FgRed).Fprintf(w, "could not refresh the scoreboard: %s\n", s.problem)
+	}
+}
+
+// groupTable lays out the standings of a group, highlighting the countries that are playing right now
+func groupTable(group models.GroupStandings, matches []models.Match) table {
+	live := make(map[int]bool)
+	for _, match := range matches {
+		if !match.Played && !time.Now().Before(match.When) {
+			live[match.ACountry.ID], live[match.BCountry.ID] = true, true
+		}
+	}
+
+	t := table{headers: []string{"group " + group.Group, "p", "w", "d", "l", "gf", "ga", "gd", "pts"}}
+	for _, row := range group.Standings {
+		name := plain(row.Country.Name)
+		if live[row.Country.ID] {
+			name = colored(row.Country.Name, color.FgYellow, color.Bold)
+		}
+
+		t.add(
+			name,
+			plain(strconv.Itoa(row.Played)),
+			plain(strconv.Itoa(row.Won)),
+			plain(strconv.Itoa(row.Drawn)),
+			plain(strconv.Itoa(row.Lost)),
+			plain(strconv.Itoa(row.GoalsFor)),
+			plain(strconv.Itoa(row.GoalsAgainst)),
+			plain(difference(row.GoalDifference)),
+			colored(strconv.Itoa(row.Points), color.Bold),
+		)
+	}
+	return t
+}
+
+// difference shows the sign of a goal difference, unless it is level
+func difference(goals int) string {
+	if goals == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%+d", goals)
+}
+
+// matchesOn finds the matches that kick off on the day, in the local time zone
+func matchesOn(matches []models.Match, day time.Time) []models.Match {
+	var found []models.Match
+	for _, match := range matches {
+		if sameDay(match.When.Local(), day) {
+			found = append(found, match)
+		}
+	}
+	return found
+}
+
+// nextMatches finds the matches on the first day after the given one that has any
+func nextMatches(matches []models.Match, day time.Time) []models.Match {
+	end := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+
+	var later []models.Match
+	for _, match := range matches {
+		if !match.When.Before(end) {
+			later = append(later, match)
+		}
+	}
+	if len(later) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(later, func(i, j int) bool { return later[i].When.Before(later[j].When) })
+	return matchesOn(later, later[0].When.Local())
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	databaseCommand(watchCmd)
+
+	watchCmd.Flags().String("url", "", "read the results from the api at the url, instead of the database")
+	watchCmd.Flags().Duration("interval", 30*time.Second, "how often to refresh the scoreboard")
+	watchCmd.Flags().StringVar(&watchDate, "date", "", "show the matches on another day, like 2022-11-20")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "show the scoreboard once, and exit")
+}
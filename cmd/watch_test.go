@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cazier/wc/api"
+	"github.com/cazier/wc/client"
+	"github.com/cazier/wc/db/dbtest"
+	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/models"
+)
+
+func TestWatchApi(t *testing.T) {
+	assert := assert.New(t)
+	gin.SetMode(gin.TestMode)
+
+	store := dbtest.Imported(t, load.Files{Teams: "../test/teams.yaml", Matches: "../test/matches.yaml"})
+	server, err := api.New(store, api.Options{Mode: gin.TestMode})
+	assert.NoError(err)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, err := client.New(ts.URL, client.Options{Retries: -1})
+	assert.NoError(err)
+
+	board, err := apiBoard{client: c}.read(context.Background())
+	assert.NoError(err)
+
+	days := make(map[models.Stage]models.Match)
+	for _, match := range board.matches {
+		days[match.Stage] = match
+	}
+	assert.Len(days, 6)
+
+	// A day in the group stage only has the tables of the groups playing
+	var output bytes.Buffer
+	group := days[models.GROUP]
+	board.print(&output, group.When.Local(), group.When)
+	assert.Contains(output.String(), "GROUP "+group.ACountry.Group+" ")
+
+	shown := 0
+	for _, table := range board.standings {
+		if bytes.Contains(output.Bytes(), []byte("GROUP "+table.Group+" ")) {
+			shown++
+		}
+	}
+	assert.Less(shown, len(board.standings))
+
+	// The knockout days have every table
+	output.Reset()
+	final := days[models.FINAL]
+	board.print(&output, final.When.Local(), final.When)
+	assert.Contains(output.String(), "final")
+
+	for _, table := range board.standings {
+		assert.Contains(output.String(), "GROUP "+table.Group+" ")
+	}
+}
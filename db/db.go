@@ -4,31 +4,41 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/cazier/wc/cache"
 	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/metrics"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
-var Database *gorm.DB
+// Store is a connection to the database, along with the metrics of the queries run on it and the imports into it.
+// Each store is separate, so several can be used in the same process.
+type Store struct {
+	DB      *gorm.DB
+	Metrics *metrics.Database
+	// The versions of the tables, which the read caches of the store use to find the entries that are stale
+	Versions *cache.Versions
+}
 
-func open(database gorm.Dialector, logLevel int, logPath string) {
-	var err error
+func open(database gorm.Dialector, logLevel int, logPath string) (*Store, error) {
 	var writer *log.Logger
 
-	if logPath == "stdout" {
+	if logPath == "stdout" || logPath == "<stdout>" {
 		writer = log.New(os.Stdout, "\n", log.LstdFlags)
 	} else {
-		logPath = filepath.Dir(logPath)
-
-		logFile, _ := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
-		writer = log.New(logFile, "db.db", log.LstdFlags)
-
-		defer logFile.Close()
+		// The file stays open for as long as the database connection is used
+		logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open the database log file: %w", err)
+		}
+		writer = log.New(logFile, "\n", log.LstdFlags)
 	}
 
 	newLogger := logger.New(
@@ -42,64 +52,103 @@ func open(database gorm.Dialector, logLevel int, logPath string) {
 		},
 	)
 
-	Database, err = gorm.Open(database, &gorm.Config{Logger: newLogger})
-
+	instance, err := gorm.Open(database, &gorm.Config{Logger: newLogger})
 	if err != nil {
-		log.Fatalf("Could not connect to the database. %s", err)
+		return nil, fmt.Errorf("could not connect to the database: %w", err)
+	}
+
+	store := &Store{DB: instance, Metrics: metrics.NewDatabase()}
+
+	if err = store.Metrics.Instrument(store.DB); err != nil {
+		return nil, fmt.Errorf("could not add the query metrics to the database: %w", err)
 	}
+
+	if store.Versions, err = cache.Watch(store.DB); err != nil {
+		return nil, fmt.Errorf("could not add the cache invalidation to the database: %w", err)
+	}
+
+	return store, nil
 }
 
-func InitMariaDB(options *MariaDBOptions) {
-	options.validate()
+func OpenMariaDB(options *MariaDBOptions) (*Store, error) {
+	if err := options.validate(); err != nil {
+		return nil, fmt.Errorf("could not connect to the database: %w", err)
+	}
 
-	dialect := mysql.Open(
-		fmt.Sprintf(
-			"%s:%s@tcp(%s:%d)/%s?%s",
-			options.Username,
-			options.Password,
-			options.Host,
-			options.Port,
-			options.Database,
-			options.Other,
-		),
-	)
+	return open(mysql.Open(options.dsn()), options.LogLevel, options.LogPath)
+}
 
-	open(dialect, options.LogLevel, options.LogPath)
+func OpenPostgres(options *PostgresOptions) (*Store, error) {
+	if err := options.validate(); err != nil {
+		return nil, fmt.Errorf("could not connect to the database: %w", err)
+	}
+
+	return open(postgres.Open(options.dsn()), options.LogLevel, options.LogPath)
 }
 
-func InitSqlite(options *SqliteDBOptions) {
+func OpenSqlite(options *SqliteDBOptions) (*Store, error) {
 	options.validate()
 
 	dialect := sqlite.Open(fmt.Sprintf("%s?%s", options.Path, options.Other))
-	open(dialect, options.LogLevel, options.LogPath)
+	store, err := open(dialect, options.LogLevel, options.LogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every connection to ":memory:" is a new, empty database, so only one can be used
+	if options.Memory {
+		connection, err := store.DB.DB()
+		if err != nil {
+			return nil, err
+		}
+		connection.SetMaxOpenConns(1)
+	}
+
+	return store, nil
 }
 
-// Create tables in the database for the specific data models
-func LinkTables(purge bool) {
+// LinkTables brings the database schema up to date by applying any pending migrations. With purge, every table is
+// dropped first, leaving an empty database.
+func (s *Store) LinkTables(purge bool) error {
 	if purge {
-		Database.Migrator().DropTable(
-			&models.Country{},
-			&models.Player{},
-			&models.Match{},
-			// &models.MatchResult{},
-		)
-	}
-	Database.AutoMigrate(
-		&models.Country{},
-		&models.Player{},
-		&models.Match{},
-		// &models.MatchResult{},
-	)
+		// Any tables from before the migrations existed are adopted first, so that they are dropped as well
+		if _, err := MigrateUp(s.DB, 0); err != nil {
+			return err
+		}
+
+		if _, err := MigrateDown(s.DB, -1); err != nil {
+			return err
+		}
+	}
+
+	_, err := MigrateUp(s.DB, 0)
+	return err
 }
 
-func AddMatchDays() {
+// Close closes the connections to the database
+func (s *Store) Close() error {
+	connection, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return connection.Close()
+}
+
+func AddMatchDays(tx *gorm.DB) error {
 	var teams []models.Country
-	Database.Find(&teams)
+	if err := tx.Find(&teams).Error; err != nil {
+		return err
+	}
 
 	for _, team := range teams {
 		matches := []models.Match{}
 
-		Database.Where(&models.Match{AID: team.ID}).Or(&models.Match{BID: team.ID}).Find(&matches).Order("when")
+		when := clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "when"}}
+
+		err := tx.Where(&models.Match{AID: team.ID}).Or(&models.Match{BID: team.ID}).Order(when).Find(&matches).Error
+		if err != nil {
+			return err
+		}
 
 		for index, match := range matches {
 			if match.Day == 0 && match.Stage == models.GROUP {
@@ -108,7 +157,68 @@ func AddMatchDays() {
 				match.Day = -1
 			}
 			match.Assigned = true
-			Database.Save(&match)
+			if err := tx.Save(&match).Error; err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+// UpdatePlayerStats recounts the goals and cards for every player from the recorded match events. Players are matched
+// to the events by their name and country, so the events can be recorded before the squads are known.
+func UpdatePlayerStats(tx *gorm.DB) error {
+	type key struct {
+		country int
+		name    string
+	}
+	type stats struct {
+		goals, yellow, red uint
+	}
+
+	var events []models.Event
+	var players []models.Player
+
+	if err := tx.Find(&events).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Find(&players).Error; err != nil {
+		return err
+	}
+
+	counts := make(map[key]*stats)
+	for _, event := range events {
+		k := key{event.CountryID, strings.ToLower(event.Player)}
+		if counts[k] == nil {
+			counts[k] = &stats{}
+		}
+
+		switch {
+		case event.Kind == models.GOAL && !event.OwnGoal:
+			counts[k].goals++
+		case event.Kind == models.YELLOW:
+			counts[k].yellow++
+		case event.Kind == models.RED:
+			counts[k].red++
+		}
+	}
+
+	for _, player := range players {
+		count := counts[key{player.CountryID, strings.ToLower(player.Name)}]
+		if count == nil {
+			count = &stats{}
+		}
+
+		if player.Goals == count.goals && player.Yellow == count.yellow && player.Red == count.red {
+			continue
+		}
+
+		update := map[string]any{"goals": count.goals, "yellow": count.yellow, "red": count.red}
+		if err := tx.Model(&player).Updates(update).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
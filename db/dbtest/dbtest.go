@@ -0,0 +1,52 @@
+// Package dbtest makes the in memory databases the tests of the other packages run against. Each database is separate,
+// and is closed once the test finishes.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/seed"
+)
+
+// Open makes an empty database, with all of the tables
+func Open(t testing.TB) *db.Store {
+	t.Helper()
+
+	store, err := db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if err := store.LinkTables(false); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// Seeded makes a database with a tournament made up by the seed package
+func Seeded(t testing.TB, options seed.Options) *db.Store {
+	t.Helper()
+
+	store := Open(t)
+	if _, err := load.ImportTables(store, "seed", seed.Generate(options), load.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// Imported makes a database with the files imported into it
+func Imported(t testing.TB, files load.Files) *db.Store {
+	t.Helper()
+
+	store := Open(t)
+	if _, err := load.Import(store, files, load.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
@@ -0,0 +1,108 @@
+package load
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+type Action string
+
+const (
+	Insert Action = "+"
+	Update Action = "~"
+	Delete Action = "-"
+)
+
+// Name is the action written out in full, like "insert"
+func (a Action) Name() string {
+	switch a {
+	case Insert:
+		return "insert"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	}
+	return string(a)
+}
+
+// Change records a single row that an import added, modified or removed
+type Change struct {
+	Action Action
+	Table  string
+	Key    string
+	Fields []string
+}
+
+func (c Change) String() string {
+	text := fmt.Sprintf("%s %s %s", c.Action, c.Table, c.Key)
+
+	if len(c.Fields) > 0 {
+		text = fmt.Sprintf("%s (%s)", text, strings.Join(c.Fields, ", "))
+	}
+
+	switch c.Action {
+	case Insert:
+		return color.GreenString(text)
+	case Update:
+		return color.YellowString(text)
+	case Delete:
+		return color.RedString(text)
+	}
+	return text
+}
+
+type Changes []Change
+
+// Summary counts the changes made to each table, e.g. "countries: 2 inserted, 1 updated, 0 deleted"
+func (c Changes) Summary() []string {
+	var tables []string
+	counts := make(map[string]map[Action]int)
+
+	for _, change := range c {
+		if _, found := counts[change.Table]; !found {
+			tables = append(tables, change.Table)
+			counts[change.Table] = make(map[Action]int)
+		}
+		counts[change.Table][change.Action]++
+	}
+
+	lines := make([]string, len(tables))
+	for index, table := range tables {
+		lines[index] = fmt.Sprintf(
+			"%s: %d inserted, %d updated, %d deleted",
+			table, counts[table][Insert], counts[table][Update], counts[table][Delete],
+		)
+	}
+
+	return lines
+}
+
+// compare takes alternating name, old and new values, and describes each of the values that differ
+func compare(values ...any) []string {
+	var fields []string
+
+	for index := 0; index+2 < len(values); index += 3 {
+		before, after := fmt.Sprint(values[index+1]), fmt.Sprint(values[index+2])
+
+		if before != after {
+			fields = append(fields, fmt.Sprintf("%s: %s -> %s", values[index], before, after))
+		}
+	}
+
+	return fields
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
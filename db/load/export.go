@@ -0,0 +1,147 @@
+package load
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tables holds everything in the database in the same layout as the import files
+type Tables struct {
+	Teams   []utils.Team
+	Matches []utils.Match
+	Players []utils.Player
+	Ratings []utils.Rating
+}
+
+// Export reads the countries (and their ratings before the tournament), matches (with their results and events) and
+// players from the database. The knockout placeholder countries are left out, since they are added by every import
+// anyway.
+func Export(database *gorm.DB) (*Tables, error) {
+	var countries []models.Country
+	var matches []models.Match
+	var players []models.Player
+
+	tables := &Tables{
+		Teams:   []utils.Team{},
+		Matches: []utils.Match{},
+		Players: []utils.Player{},
+		Ratings: []utils.Rating{},
+	}
+
+	if err := database.Order("id").Find(&countries).Error; err != nil {
+		return nil, err
+	}
+
+	when := clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "when"}}
+	events := func(tx *gorm.DB) *gorm.DB {
+		return tx.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Name: "minute"}},
+			{Column: clause.Column{Name: "offset"}},
+			{Column: clause.Column{Name: "id"}},
+		}})
+	}
+
+	err := database.Preload("ACountry").Preload("BCountry").Preload("Events", events).Preload("Events.Country").
+		Order(when).Order("id").Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.Preload("Country").Order("country_id").Order("number").Order("id").Find(&players).Error; err != nil {
+		return nil, err
+	}
+
+	for _, country := range countries {
+		if country.FifaCode == "<A>" || country.FifaCode == "<B>" {
+			continue
+		}
+
+		tables.Teams = append(tables.Teams, utils.Team{Name: country.Name, Code: country.FifaCode, Group: country.Group})
+		tables.Ratings = append(tables.Ratings, utils.Rating{Country: country.FifaCode, Rating: country.BaseRating})
+	}
+
+	for _, match := range matches {
+		item := utils.Match{A: match.ACountry.Name, B: match.BCountry.Name, Stage: match.Stage, Date: match.When.UTC()}
+
+		if match.Played {
+			item.Score = &utils.Score{A: match.AScore, B: match.BScore}
+
+			if match.APenalties != 0 || match.BPenalties != 0 {
+				item.Penalties = &utils.Score{A: match.APenalties, B: match.BPenalties}
+			}
+
+			for _, event := range match.Events {
+				item.Events = append(item.Events, utils.Event{
+					Kind:    event.Kind,
+					Country: event.Country.Name,
+					Player:  event.Player,
+					Minute:  event.Minute,
+					Offset:  event.Offset,
+					Penalty: event.Penalty,
+					OwnGoal: event.OwnGoal,
+				})
+			}
+		}
+
+		tables.Matches = append(tables.Matches, item)
+	}
+
+	for _, player := range players {
+		tables.Players = append(tables.Players, utils.Player{
+			Name:     player.Name,
+			Country:  player.Country.FifaCode,
+			Number:   player.Number,
+			Position: player.Position,
+		})
+	}
+
+	return tables, nil
+}
+
+// Write saves each of the tables into the directory as teams, matches, players and ratings files in the given format.
+// The paths of the files that were written are returned, in that order.
+func (t *Tables) Write(directory string, format utils.Format) ([]string, error) {
+	var paths []string
+
+	files := []struct {
+		name  string
+		write func(*os.File) error
+	}{
+		{"teams", func(f *os.File) error { return utils.WriteTeams(f, format, t.Teams) }},
+		{"matches", func(f *os.File) error { return utils.WriteMatches(f, format, t.Matches) }},
+		{"players", func(f *os.File) error { return utils.WritePlayers(f, format, t.Players) }},
+		{"ratings", func(f *os.File) error { return utils.WriteRatings(f, format, t.Ratings) }},
+	}
+
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create the directory %s: %w", directory, err)
+	}
+
+	for _, file := range files {
+		path := filepath.Join(directory, fmt.Sprintf("%s.%s", file.name, format))
+
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not create the file %s: %w", path, err)
+		}
+
+		err = file.write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not write the file %s: %w", path, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
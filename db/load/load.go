@@ -2,121 +2,788 @@ package load
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/cazier/wc/api/exceptions"
 	"github.com/cazier/wc/db"
 	"github.com/cazier/wc/db/load/utils"
 	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/ratings"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var cache map[string]models.Country
+var countryCache map[string]models.Country
 
-func Teams(path string) {
-	var counter int64
+// importing only lets one import run at a time, since they share the country cache
+var importing sync.Mutex
 
-	teams := utils.LoadTeams(path)
+// ErrDryRun is used to roll back the import transaction once all of the changes have been found
+var ErrDryRun = errors.New("dry run; no changes were saved")
+
+type Options struct {
+	// Remove any rows from the database that are not in the imported file
+	Prune bool
+	// Work out all of the changes, but roll them back instead of saving them
+	DryRun bool
+
+	// The format of the files, which is found from each file extension when empty
+	Format utils.Format
+	// Extra csv header names to map onto the field names
+	Headers map[string]string
+}
+
+func (o Options) source(path string) utils.Source {
+	return utils.Source{Path: path, Format: o.Format, Headers: o.Headers}
+}
+
+type Files struct {
+	Teams   string
+	Matches string
+	Players string
+	// The ratings of the countries before the tournament
+	Ratings string
+
+	// Datasets in the openfootball worldcup.json or football.txt layouts
+	OpenFootball []string
+}
+
+// Import loads each of the given files in a single transaction, so that a failure in any one of them leaves the
+// database as it was before the import started.
+func Import(store *db.Store, files Files, options Options) (Changes, error) {
+	var steps []step
+
+	add := func(path string, read func(*gorm.DB, string, Options) (Changes, error)) {
+		if path != "" {
+			steps = append(steps, func(tx *gorm.DB) (Changes, error) { return read(tx, path, options) })
+		}
+	}
+
+	add(files.Teams, Teams)
+	add(files.Matches, Matches)
+	add(files.Players, Players)
+	add(files.Ratings, Ratings)
+
+	for _, path := range files.OpenFootball {
+		add(path, OpenFootball)
+	}
+
+	return run(store, options, steps)
+}
+
+// ImportTables loads the teams, matches, players and ratings that are already in memory, like those made up by the seed
+// package, in the same way as Import. The name stands in for the path of a file in any errors.
+func ImportTables(store *db.Store, name string, tables *Tables, options Options) (Changes, error) {
+	return run(store, options, []step{
+		func(tx *gorm.DB) (Changes, error) { return importTeams(tx, name, tables.Teams, options) },
+		func(tx *gorm.DB) (Changes, error) { return importMatches(tx, name, tables.Matches, options) },
+		func(tx *gorm.DB) (Changes, error) { return importPlayers(tx, name, tables.Players, options) },
+		func(tx *gorm.DB) (Changes, error) { return importRatings(tx, name, tables.Ratings, options) },
+	})
+}
+
+// A step imports one of the files (or tables) inside the transaction
+type step func(tx *gorm.DB) (Changes, error)
+
+// run imports each of the steps in a single transaction
+func run(store *db.Store, options Options, steps []step) (Changes, error) {
+	var changes Changes
+
+	importing.Lock()
+	defer importing.Unlock()
+
+	err := store.DB.Transaction(func(tx *gorm.DB) error {
+		for _, step := range steps {
+			output, err := step(tx)
+			if err != nil {
+				return err
+			}
+
+			changes = append(changes, output...)
+		}
+
+		if err := db.UpdatePlayerStats(tx); err != nil {
+			return err
+		}
+
+		if err := ratings.Update(tx); err != nil {
+			return err
+		}
+
+		if options.DryRun {
+			return ErrDryRun
+		}
+		return nil
+	})
+
+	// The country cache may hold countries that were rolled back
+	countryCache = nil
+
+	switch {
+	case errors.Is(err, ErrDryRun):
+		store.Metrics.Imported("dry_run")
+		return changes, nil
+	case err != nil:
+		store.Metrics.Imported("failed")
+		return changes, err
+	}
+
+	// The writes already invalidated the read caches, but an api request could have read the tables again before
+	// the transaction was committed
+	store.Versions.Invalidate()
+
+	store.Metrics.Imported("saved")
+	for _, change := range changes {
+		store.Metrics.ImportedRow(change.Table, change.Action.Name())
+	}
+
+	return changes, nil
+}
+
+// Teams inserts or updates each of the countries in the file, using the FIFA code to match existing rows
+func Teams(tx *gorm.DB, path string, options Options) (Changes, error) {
+	teams, err := utils.LoadTeamsFrom(options.source(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return importTeams(tx, path, teams, options)
+}
+
+func importTeams(tx *gorm.DB, path string, teams []utils.Team, options Options) (Changes, error) {
+	var changes Changes
+	var existing []models.Country
+	var errs []*exceptions.ValidationError
+	var err error
+
+	seen := make(map[string]bool)
+	for _, team := range teams {
+		if seen[team.Code] {
+			errs = append(errs, duplicate(team.Line, "country", team.Code))
+		}
+		seen[team.Code] = true
+	}
+
+	if errs != nil {
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+	}
 
 	teams = append([]utils.Team{
 		{Name: "Team A", Group: "", Code: "<A>"},
 		{Name: "Team B", Group: "", Code: "<B>"},
 	}, teams...)
 
+	if err = tx.Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]models.Country)
+	for _, row := range existing {
+		rows[row.FifaCode] = row
+	}
+
 	for _, team := range teams {
-		input := models.Country{Name: team.Name, FifaCode: team.Code, Group: team.Group}
-		output := models.Country{}
+		row, found := rows[team.Code]
+		delete(rows, team.Code)
+
+		if !found {
+			row = models.Country{Name: team.Name, FifaCode: team.Code, Group: team.Group}
+
+			if err = tx.Create(&row).Error; err != nil {
+				return nil, fmt.Errorf("could not add the country %s: %w", team.Name, err)
+			}
+
+			changes = append(changes, Change{Action: Insert, Table: "countries", Key: team.Code})
+			continue
+		}
+
+		fields := compare("name", row.Name, team.Name, "group", row.Group, team.Group)
+		if fields == nil {
+			continue
+		}
+
+		if err = tx.Model(&row).Updates(map[string]any{"name": team.Name, "group": team.Group}).Error; err != nil {
+			return nil, fmt.Errorf("could not update the country %s: %w", team.Name, err)
+		}
+
+		changes = append(changes, Change{Action: Update, Table: "countries", Key: team.Code, Fields: fields})
+	}
+
+	if options.Prune {
+		for _, code := range sortedKeys(rows) {
+			row := rows[code]
+
+			used, err := inUse(tx,
+				usage{"matches", &models.Match{}, "? IN (a_id, b_id)", []any{row.ID}},
+				usage{"players", &models.Player{}, "country_id = ?", []any{row.ID}},
+				usage{"events", &models.Event{}, "country_id = ?", []any{row.ID}},
+				usage{"predictions", &models.Prediction{}, "winner_id = ?", []any{row.ID}},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			if used != "" {
+				errs = append(errs, stillUsed("country", code, used))
+				continue
+			}
+
+			if err = tx.Unscoped().Where(&models.Rating{CountryID: row.ID}).Delete(&models.Rating{}).Error; err != nil {
+				return nil, fmt.Errorf("could not remove the ratings of the country %s: %w", row.Name, err)
+			}
+
+			if err = tx.Unscoped().Delete(&row).Error; err != nil {
+				return nil, fmt.Errorf("could not remove the country %s: %w", row.Name, err)
+			}
+
+			changes = append(changes, Change{Action: Delete, Table: "countries", Key: code})
+		}
+
+		if errs != nil {
+			return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+		}
+	}
+
+	// Any countries cached before this import are now out of date
+	countryCache = nil
 
-		db.Database.FirstOrCreate(&output, input)
+	log.Printf("Imported %d countries (+2 placeholders)", len(teams)-2)
+	return changes, nil
+}
 
-		counter++
+// Matches inserts or updates each of the matches in the file. Matches are identified by their two countries and
+// the date they are played on, so that the kickoff time can be changed by a later import. When there are several
+// matches between the same countries on one day (like the knockout placeholders), they are paired up in kickoff
+// order.
+func Matches(tx *gorm.DB, path string, options Options) (Changes, error) {
+	matches, err := utils.LoadMatchesFrom(options.source(path))
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("Added %d (+2) countries to the database", counter-2)
+
+	return importMatches(tx, path, matches, options)
 }
 
-func Matches(path string) {
-	var counter int64
-	cache, _ = cacheCountries()
+func importMatches(tx *gorm.DB, path string, matches []utils.Match, options Options) (Changes, error) {
+	type key struct {
+		a, b int
+		date string
+	}
+
+	var changes Changes
+	var existing []models.Match
+	var errs []*exceptions.ValidationError
+	var err error
 
-	matches := utils.LoadMatches(path)
+	if countryCache, err = cacheCountries(tx); err != nil {
+		return nil, err
+	}
 
 	for _, match := range matches {
-		input := models.Match{
-			AID:   cache[match.A].ID,
-			BID:   cache[match.B].ID,
-			When:  match.Date,
-			Stage: match.Stage,
+		errs = append(errs, unknownCountries(match.Line, match.A, match.B)...)
+
+		for _, event := range match.Events {
+			errs = append(errs, unknownCountries(match.Line, event.Country)...)
+
+			if id := countryCache[event.Country].ID; id != 0 && id != countryCache[match.A].ID && id != countryCache[match.B].ID {
+				errs = append(errs, &exceptions.ValidationError{
+					Line:    match.Line,
+					Col:     1,
+					Message: fmt.Sprintf("the event for `%s` is not for either team in the match", event.Player),
+				})
+			}
 		}
-		output := models.Match{}
+	}
 
-		if db.Database.FirstOrCreate(&output, input).RowsAffected == 0 {
-			continue
+	if errs != nil {
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+	}
+
+	when := clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "when"}}
+	if err = tx.Order(when).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string)
+	for _, country := range countryCache {
+		names[country.ID] = country.Name
+	}
+
+	describe := func(k key) string {
+		return fmt.Sprintf("%s v %s (%s)", names[k.a], names[k.b], k.date)
+	}
+
+	rows := make(map[key][]models.Match)
+	for _, row := range existing {
+		k := key{row.AID, row.BID, row.When.UTC().Format("2006-01-02")}
+		rows[k] = append(rows[k], row)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Date.Before(matches[j].Date) })
+
+	for _, match := range matches {
+		var row models.Match
+		k := key{countryCache[match.A].ID, countryCache[match.B].ID, match.Date.Format("2006-01-02")}
+
+		penalties := utils.Score{}
+		if match.Penalties != nil {
+			penalties = *match.Penalties
+		}
+
+		if len(rows[k]) == 0 {
+			row = models.Match{AID: k.a, BID: k.b, When: match.Date, Stage: match.Stage}
+
+			if match.Score != nil {
+				row.Played = true
+				row.AScore, row.BScore = match.Score.A, match.Score.B
+				row.APenalties, row.BPenalties = penalties.A, penalties.B
+			}
+
+			if err = tx.Create(&row).Error; err != nil {
+				return nil, fmt.Errorf("could not add the match %s v %s: %w", match.A, match.B, err)
+			}
+
+			changes = append(changes, Change{Action: Insert, Table: "matches", Key: describe(k)})
+		} else {
+			row = rows[k][0]
+			rows[k] = rows[k][1:]
+
+			values := []any{
+				"when", row.When.UTC().Format("15:04"), match.Date.Format("15:04"),
+				"stage", row.Stage, match.Stage,
+			}
+			update := map[string]any{"when": match.Date, "stage": match.Stage}
+
+			// Matches without a score in the file keep any results that were recorded some other way
+			if match.Score != nil {
+				values = append(values,
+					"played", row.Played, true,
+					"score", utils.Score{A: row.AScore, B: row.BScore}, match.Score,
+					"penalties", utils.Score{A: row.APenalties, B: row.BPenalties}, penalties,
+				)
+				update["played"] = true
+				update["a_score"], update["b_score"] = match.Score.A, match.Score.B
+				update["a_penalties"], update["b_penalties"] = penalties.A, penalties.B
+			}
+
+			if fields := compare(values...); fields != nil {
+				if err = tx.Model(&row).Updates(update).Error; err != nil {
+					return nil, fmt.Errorf("could not update the match %s v %s: %w", match.A, match.B, err)
+				}
+
+				changes = append(changes, Change{Action: Update, Table: "matches", Key: describe(k), Fields: fields})
+			}
+		}
+
+		if match.Score != nil {
+			output, err := importEvents(tx, row, match.Events, describe(k))
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, output...)
+		}
+	}
+
+	if options.Prune {
+		leftovers := make(map[int]bool)
+		for _, unmatched := range rows {
+			for _, row := range unmatched {
+				leftovers[row.ID] = true
+			}
+		}
+
+		for _, row := range existing {
+			if !leftovers[row.ID] {
+				continue
+			}
+
+			k := key{row.AID, row.BID, row.When.UTC().Format("2006-01-02")}
+
+			used, err := inUse(tx,
+				usage{"events", &models.Event{}, "match_id = ?", []any{row.ID}},
+				usage{"predictions", &models.Prediction{}, "match_id = ?", []any{row.ID}},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			if used != "" {
+				errs = append(errs, stillUsed("match", describe(k), used))
+				continue
+			}
+
+			if err = tx.Unscoped().Where(&models.Rating{MatchID: row.ID}).Delete(&models.Rating{}).Error; err != nil {
+				return nil, fmt.Errorf("could not remove the ratings of the match %s: %w", describe(k), err)
+			}
+
+			if err = tx.Unscoped().Delete(&row).Error; err != nil {
+				return nil, fmt.Errorf("could not remove the match %s: %w", describe(k), err)
+			}
+
+			changes = append(changes, Change{Action: Delete, Table: "matches", Key: describe(k)})
 		}
 
-		counter++
+		if errs != nil {
+			return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+		}
 	}
 
-	log.Printf("Added %d matches to the database", counter)
+	log.Printf("Imported %d matches", len(matches))
+
+	return changes, db.AddMatchDays(tx)
+}
+
+// Players inserts or updates each of the players in the file, using their name and country to match existing rows
+func Players(tx *gorm.DB, path string, options Options) (Changes, error) {
+	players, err := utils.LoadPlayersFrom(options.source(path))
+	if err != nil {
+		return nil, err
+	}
 
-	db.AddMatchDays()
+	return importPlayers(tx, path, players, options)
 }
 
-func Players(path string) {
-	var counter int64
-	cache, _ = cacheCountries()
+func importPlayers(tx *gorm.DB, path string, players []utils.Player, options Options) (Changes, error) {
+	var changes Changes
+	var existing []models.Player
+	var errs []*exceptions.ValidationError
+	var err error
+
+	if countryCache, err = cacheCountries(tx); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, player := range players {
+		errs = append(errs, unknownCountries(player.Line, player.Country)...)
+
+		if k := playerKey(player.Name, countryCache[player.Country].ID); seen[k] {
+			errs = append(errs, duplicate(player.Line, "player", fmt.Sprintf("%s (%s)", player.Name, player.Country)))
+		} else {
+			seen[k] = true
+		}
+	}
+
+	if errs != nil {
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+	}
 
-	playerMap := utils.LoadPlayers(path)
+	if err = tx.Find(&existing).Error; err != nil {
+		return nil, err
+	}
 
-	countryMap := make(map[string][]utils.Player)
+	codes := make(map[int]string)
+	for _, country := range countryCache {
+		codes[country.ID] = country.FifaCode
+	}
 
-	for _, player := range playerMap {
-		countryMap[player.Country] = append(countryMap[player.Country], player)
+	rows := make(map[string]models.Player)
+	for _, row := range existing {
+		rows[playerKey(row.Name, row.CountryID)] = row
 	}
 
-	for country, players := range countryMap {
-		for _, player := range players {
-			input := models.Player{
+	for _, player := range players {
+		k := playerKey(player.Name, countryCache[player.Country].ID)
+		description := fmt.Sprintf("%s (%s)", player.Name, countryCache[player.Country].FifaCode)
+
+		row, found := rows[k]
+		delete(rows, k)
+
+		if !found {
+			row = models.Player{
 				Name:      player.Name,
 				Position:  player.Position,
 				Number:    player.Number,
-				CountryID: cache[country].ID,
+				CountryID: countryCache[player.Country].ID,
+			}
+
+			if err = tx.Create(&row).Error; err != nil {
+				return nil, fmt.Errorf("could not add the player %s: %w", player.Name, err)
 			}
-			output := models.Player{}
 
-			if db.Database.FirstOrCreate(&output, input).RowsAffected == 0 {
+			changes = append(changes, Change{Action: Insert, Table: "players", Key: description})
+			continue
+		}
+
+		fields := compare("position", row.Position, player.Position, "number", row.Number, player.Number)
+		if fields == nil {
+			continue
+		}
+
+		update := map[string]any{"position": player.Position, "number": player.Number}
+		if err = tx.Model(&row).Updates(update).Error; err != nil {
+			return nil, fmt.Errorf("could not update the player %s: %w", player.Name, err)
+		}
+
+		changes = append(changes, Change{Action: Update, Table: "players", Key: description, Fields: fields})
+	}
+
+	if options.Prune {
+		for _, k := range sortedKeys(rows) {
+			row := rows[k]
+			key := fmt.Sprintf("%s (%s)", row.Name, codes[row.CountryID])
+
+			// Events name their player rather than pointing at the row, and an own goal is recorded against the
+			// other country, so any own goal with the same name counts too
+			used, err := inUse(tx, usage{
+				table: "events",
+				model: &models.Event{},
+				query: "player = ? AND (country_id = ? OR own_goal = ?)",
+				args:  []any{row.Name, row.CountryID, true},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if used != "" {
+				errs = append(errs, stillUsed("player", key, used))
 				continue
 			}
 
-			counter++
+			if err = tx.Unscoped().Delete(&row).Error; err != nil {
+				return nil, fmt.Errorf("could not remove the player %s: %w", row.Name, err)
+			}
+
+			changes = append(changes, Change{Action: Delete, Table: "players", Key: key})
+		}
+
+		if errs != nil {
+			return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+		}
+	}
+
+	log.Printf("Imported %d players", len(players))
+	return changes, nil
+}
+
+// Ratings sets the rating each of the countries in the file had before the tournament, which their ratings are
+// worked out from. With prune, the countries that aren't in the file go back to the initial rating.
+func Ratings(tx *gorm.DB, path string, options Options) (Changes, error) {
+	ratings, err := utils.LoadRatingsFrom(options.source(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return importRatings(tx, path, ratings, options)
+}
+
+func importRatings(tx *gorm.DB, path string, ratings []utils.Rating, options Options) (Changes, error) {
+	var changes Changes
+	var errs []*exceptions.ValidationError
+	var err error
+
+	if countryCache, err = cacheCountries(tx); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool)
+	for _, rating := range ratings {
+		errs = append(errs, unknownCountries(rating.Line, rating.Country)...)
+
+		if id := countryCache[rating.Country].ID; seen[id] {
+			errs = append(errs, duplicate(rating.Line, "rating for", rating.Country))
+		} else if id != 0 {
+			seen[id] = true
+		}
+	}
+
+	if errs != nil {
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+	}
+
+	base := make(map[string]float64)
+	for _, rating := range ratings {
+		base[countryCache[rating.Country].FifaCode] = rating.Rating
+	}
+
+	if options.Prune {
+		for _, country := range countryCache {
+			if _, found := base[country.FifaCode]; !found && country.Group != "" {
+				base[country.FifaCode] = models.INITIAL_RATING
+			}
+		}
+	}
+
+	for _, code := range sortedKeys(base) {
+		row := countryCache[code]
+
+		fields := compare("base_rating", row.BaseRating, base[code])
+		if fields == nil {
+			continue
+		}
+
+		if err = tx.Model(&row).Update("base_rating", base[code]).Error; err != nil {
+			return nil, fmt.Errorf("could not update the rating of %s: %w", row.Name, err)
+		}
+
+		changes = append(changes, Change{Action: Update, Table: "countries", Key: code, Fields: fields})
+	}
+
+	// The cached countries still have their old ratings
+	countryCache = nil
+
+	log.Printf("Imported %d ratings", len(ratings))
+	return changes, nil
+}
+
+// importEvents replaces the events recorded for a match with the imported ones, if they are any different
+func importEvents(tx *gorm.DB, match models.Match, events []utils.Event, key string) (Changes, error) {
+	var changes Changes
+	var existing []models.Event
+
+	if err := tx.Where(&models.Event{MatchID: match.ID}).Order("id").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.Event, len(events))
+	for index, event := range events {
+		rows[index] = models.Event{
+			MatchID:   match.ID,
+			CountryID: countryCache[event.Country].ID,
+			Kind:      event.Kind,
+			Player:    event.Player,
+			Minute:    event.Minute,
+			Offset:    event.Offset,
+			Penalty:   event.Penalty,
+			OwnGoal:   event.OwnGoal,
+		}
+	}
+
+	describe := func(event models.Event) string {
+		minute := fmt.Sprintf("%d'", event.Minute)
+		if event.Offset != 0 {
+			minute = fmt.Sprintf("%d+%d'", event.Minute, event.Offset)
 		}
+		return fmt.Sprintf("%s: %s %s %s", key, event.Kind, event.Player, minute)
+	}
+
+	counts := make(map[string]int)
+	for _, event := range existing {
+		counts[describe(event)]--
 	}
-	log.Printf("Added %d players to the database", counter)
+	for _, event := range rows {
+		counts[describe(event)]++
+	}
+
+	for _, description := range sortedKeys(counts) {
+		for ; counts[description] > 0; counts[description]-- {
+			changes = append(changes, Change{Action: Insert, Table: "events", Key: description})
+		}
+		for ; counts[description] < 0; counts[description]++ {
+			changes = append(changes, Change{Action: Delete, Table: "events", Key: description})
+		}
+	}
+
+	if changes == nil {
+		return nil, nil
+	}
+
+	if err := tx.Unscoped().Where(&models.Event{MatchID: match.ID}).Delete(&models.Event{}).Error; err != nil {
+		return nil, fmt.Errorf("could not remove the events for %s: %w", key, err)
+	}
+
+	if len(rows) > 0 {
+		if err := tx.Create(&rows).Error; err != nil {
+			return nil, fmt.Errorf("could not add the events for %s: %w", key, err)
+		}
+	}
+
+	return changes, nil
+}
+
+func playerKey(name string, country int) string {
+	return fmt.Sprintf("%d/%s", country, name)
+}
+
+// A usage is a table whose rows can point at a row that is about to be pruned
+type usage struct {
+	table string
+	model any
+	query string
+	args  []any
+}
+
+// inUse counts the rows in each table that still point at a row that is about to be pruned, returning a description
+// of them, or an empty string when nothing does
+func inUse(tx *gorm.DB, usages ...usage) (string, error) {
+	var found []string
+
+	for _, usage := range usages {
+		var count int64
+		if err := tx.Unscoped().Model(usage.model).Where(usage.query, usage.args...).Count(&count).Error; err != nil {
+			return "", err
+		}
+
+		if count > 0 {
+			found = append(found, fmt.Sprintf("%d row(s) in %s", count, usage.table))
+		}
+	}
+
+	return strings.Join(found, ", "), nil
+}
+
+// stillUsed reports a row that is missing from the file, but can't be pruned. It isn't on any line of the file, so
+// the error is for the file as a whole.
+func stillUsed(kind, key, used string) *exceptions.ValidationError {
+	message := fmt.Sprintf("the %s `%s` is not in the file, but can't be removed while %s still point at it", kind, key, used)
+	return &exceptions.ValidationError{Message: message}
+}
+
+func duplicate(line int, kind, key string) *exceptions.ValidationError {
+	return &exceptions.ValidationError{
+		Line:    line,
+		Col:     1,
+		Message: fmt.Sprintf("the %s `%s` is listed more than once", kind, key),
+	}
+}
+
+// unknownCountries reports each of the names or codes that can't be found in the country cache
+func unknownCountries(line int, countries ...string) []*exceptions.ValidationError {
+	var errs []*exceptions.ValidationError
+
+	for _, country := range countries {
+		if _, found := countryCache[country]; !found {
+			errs = append(errs, &exceptions.ValidationError{
+				Line:    line,
+				Col:     1,
+				Message: fmt.Sprintf("the country `%s` could not be found in the database", country),
+			})
+		}
+	}
+
+	return errs
 }
 
-func cacheCountries() (map[string]models.Country, error) {
+func cacheCountries(tx *gorm.DB) (map[string]models.Country, error) {
 	var countries []models.Country
 
-	if cache != nil {
-		return cache, nil
+	if countryCache != nil {
+		return countryCache, nil
 	}
 
-	tx := db.Database.Find(&countries)
+	result := tx.Find(&countries)
 
-	if tx.Error != nil {
-		return nil, tx.Error
+	if result.Error != nil {
+		return nil, result.Error
 	}
 
-	if tx.RowsAffected == 0 {
+	if result.RowsAffected == 0 {
 		return nil, errors.New("cannot import match data when there are no countries in the table")
 	}
 
-	cache = make(map[string]models.Country)
+	countryCache = make(map[string]models.Country)
 
 	for _, item := range countries {
-		cache[item.FifaCode] = item
-		cache[item.Name] = item
+		countryCache[item.FifaCode] = item
+		countryCache[item.Name] = item
 	}
 
-	log.Printf("Loaded %d countries into a cache map", len(cache))
+	log.Printf("Loaded %d countries into a cache map", len(countryCache))
 
-	return cache, nil
+	return countryCache, nil
 
 }
@@ -6,16 +6,19 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/cazier/wc/api/exceptions"
 	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load/utils"
 	"github.com/cazier/wc/db/models"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
-	"gorm.io/gorm"
 )
 
+var store *db.Store
+
 func init() {
-	db.InitSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 3})
-	db.LinkTables(false)
+	store, _ = db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 3})
+	store.LinkTables(false)
 }
 
 var TempDir string
@@ -56,7 +59,7 @@ func TestEmpty(t *testing.T) {
 		num := int64(1)
 		assert.NotZero(t, num)
 
-		db.Database.Model(&model).Count(&num)
+		store.DB.Model(&model).Count(&num)
 		assert.Zero(t, num)
 	}
 }
@@ -73,13 +76,14 @@ func TestTeams(t *testing.T) {
 	}
 
 	path := createYaml(testData, "teams.yaml")
-	Teams(path)
+	_, err := Teams(store.DB, path, Options{})
+	assert.NoError(err)
 
 	var num int64
 	var rows []models.Country
 
-	db.Database.Model(&rows).Count(&num)
-	db.Database.Find(&rows)
+	store.DB.Model(&rows).Count(&num)
+	store.DB.Find(&rows)
 
 	assert.Len(rows, len(testData)+2)
 	assert.Equal("<A>", rows[0].FifaCode)
@@ -121,13 +125,14 @@ func TestMatches(t *testing.T) {
 	testData = testData[:counter]
 
 	path := createYaml(testData, "matches.yaml")
-	Matches(path)
+	_, err := Matches(store.DB, path, Options{})
+	assert.NoError(err)
 
 	var num int64
 	var rows []models.Match
 
-	db.Database.Model(&rows).Count(&num)
-	db.Database.Joins("ACountry").Joins("BCountry").Find(&rows)
+	store.DB.Model(&rows).Count(&num)
+	store.DB.Joins("ACountry").Joins("BCountry").Find(&rows)
 
 	assert.Len(rows, int(num))
 
@@ -136,8 +141,9 @@ func TestMatches(t *testing.T) {
 		assert.NotZero(rows[index].BCountry.Name)
 	}
 
-	Matches(path)
-	db.Database.Model(&rows).Count(&num)
+	_, err = Matches(store.DB, path, Options{})
+	assert.NoError(err)
+	store.DB.Model(&rows).Count(&num)
 	assert.Len(rows, int(num))
 }
 
@@ -167,13 +173,14 @@ func TestPlayers(t *testing.T) {
 	testData = testData[:counter]
 
 	path := createYaml(testData, "players.yaml")
-	Players(path)
+	_, err := Players(store.DB, path, Options{})
+	assert.NoError(err)
 
 	var num int64
 	var rows []models.Player
 
-	db.Database.Model(&rows).Count(&num)
-	db.Database.Joins("Country").Find(&rows)
+	store.DB.Model(&rows).Count(&num)
+	store.DB.Joins("Country").Find(&rows)
 
 	assert.Len(rows, int(num))
 
@@ -184,34 +191,284 @@ func TestPlayers(t *testing.T) {
 		assert.NotZero(rows[index].Country.Name)
 	}
 
-	Players(path)
-	db.Database.Model(&rows).Count(&num)
+	_, err = Players(store.DB, path, Options{})
+	assert.NoError(err)
+	store.DB.Model(&rows).Count(&num)
 	assert.Len(rows, int(num))
 }
 
+func TestInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	TestTeams(t)
+
+	var before, after int64
+	store.DB.Model(&models.Match{}).Count(&before)
+
+	path := createYaml(`- a: Country A
+  b: Country Z
+  date: 01-Jan-01
+  time: '1:00'
+  stage: GROUP
+- a: Country A
+  b: Country B
+  date: 01-Jan-01
+  time: '1:00'
+  stage: GROUP
+`, "invalid.yaml")
+
+	_, err := Matches(store.DB, path, Options{})
+
+	var validation *exceptions.ValidationErrors
+	assert.ErrorAs(err, &validation)
+	assert.Len(validation.Errors, 1)
+	assert.Equal(1, validation.Errors[0].Line)
+	assert.Contains(validation.Errors[0].Message, "Country Z")
+
+	store.DB.Model(&models.Match{}).Count(&after)
+	assert.Equal(before, after)
+
+	_, err = Players(store.DB, createYaml("- name: [", "broken.yaml"), Options{})
+	assert.Error(err)
+}
+
 func TestCache(t *testing.T) {
 	assert := assert.New(t)
 
 	// Depending on the test order, this may have been filled by the TestTeam function
-	cache = nil
+	countryCache = nil
 
-	sql, _ := db.Database.DB()
+	sql, _ := store.DB.DB()
 	sql.Close()
 
-	_, err := cacheCountries()
+	_, err := cacheCountries(store.DB)
 	assert.ErrorContains(err, "sql: database is closed")
 
-	db.Database, _ = gorm.Open(db.Database.Dialector)
-	db.LinkTables(true)
+	store, _ = db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 3})
+	store.LinkTables(true)
 
-	_, err = cacheCountries()
+	_, err = cacheCountries(store.DB)
 	assert.ErrorContains(err, "cannot import match data when there are no countries in the table")
 
 	TestTeams(t)
 
-	output, err := cacheCountries()
+	output, err := cacheCountries(store.DB)
 
-	assert.NotEmpty(cache)
+	assert.NotEmpty(countryCache)
 	assert.NotEmpty(output)
 	assert.Nil(err)
 }
+
+func TestImport(t *testing.T) {
+	assert := assert.New(t)
+
+	store.LinkTables(true)
+	countryCache = nil
+
+	teams := createYaml(`- name: Country A
+  code: C_A
+  group: A
+- name: Country B
+  code: C_B
+  group: A
+`, "import_teams.yaml")
+	matches := createYaml(`- a: Country A
+  b: Country B
+  date: 01-Jan-01
+  time: '1:00'
+  stage: GROUP
+`, "import_matches.yaml")
+
+	changes, err := Import(store, Files{Teams: teams, Matches: matches}, Options{DryRun: true})
+	assert.NoError(err)
+	assert.Len(changes, 5)
+	assert.Equal([]string{"countries: 4 inserted, 0 updated, 0 deleted", "matches: 1 inserted, 0 updated, 0 deleted"}, changes.Summary())
+
+	var num int64
+	store.DB.Model(&models.Country{}).Count(&num)
+	assert.Zero(num)
+
+	_, err = Import(store, Files{Teams: teams, Matches: matches}, Options{})
+	assert.NoError(err)
+
+	changes, err = Import(store, Files{Teams: teams, Matches: matches}, Options{})
+	assert.NoError(err)
+	assert.Empty(changes)
+
+	matches = createYaml(`- a: Country A
+  b: Country B
+  date: 01-Jan-01
+  time: '2:30'
+  stage: GROUP
+`, "import_matches.yaml")
+
+	changes, err = Import(store, Files{Matches: matches}, Options{})
+	assert.NoError(err)
+	assert.Equal(Changes{{
+		Action: Update,
+		Table:  "matches",
+		Key:    "Country A v Country B (2001-01-01)",
+		Fields: []string{"when: 01:00 -> 02:30"},
+	}}, changes)
+
+	var match models.Match
+	store.DB.First(&match)
+	assert.Equal(2, match.When.UTC().Hour())
+
+	teams = createYaml(`- name: Country A
+  code: C_A
+  group: B
+`, "import_teams.yaml")
+	players := createYaml("- name: Player\n  country: Country Z\n", "import_players.yaml")
+
+	_, err = Import(store, Files{Teams: teams, Players: players}, Options{Prune: true})
+	assert.Error(err)
+
+	store.DB.Model(&models.Country{}).Count(&num)
+	assert.EqualValues(4, num)
+
+	// Country B can't be pruned while its match is still there, and nothing else is saved either
+	_, err = Import(store, Files{Teams: teams}, Options{Prune: true})
+	assert.EqualError(err, "found 1 problem(s)\n"+teams+": the country `C_B` is not in the file, "+
+		"but can't be removed while 1 row(s) in matches still point at it")
+
+	store.DB.Model(&models.Country{}).Count(&num)
+	assert.EqualValues(4, num)
+
+	matches = createYaml("[]\n", "import_matches.yaml")
+	changes, err = Import(store, Files{Matches: matches}, Options{Prune: true})
+	assert.NoError(err)
+	assert.Equal([]string{"matches: 0 inserted, 0 updated, 1 deleted"}, changes.Summary())
+
+	changes, err = Import(store, Files{Teams: teams}, Options{Prune: true})
+	assert.NoError(err)
+	assert.Equal([]string{"countries: 0 inserted, 1 updated, 1 deleted"}, changes.Summary())
+}
+
+func TestOpenFootball(t *testing.T) {
+	assert := assert.New(t)
+
+	store.LinkTables(true)
+	countryCache = nil
+
+	teams := createYaml("- name: Ecuador\n  code: ECU\n  group: A\n", "openfootball_teams.yaml")
+	players := createYaml("- name: Enner Valencia\n  country: ECU\n  number: 13\n  position: FW\n", "openfootball_players.yaml")
+	dataset := createYaml(`= World Cup 2022
+
+▪ Group A
+Sun Nov 20
+  19:00     Qatar   0-2 (0-2)   Ecuador     @ Al Bayt Stadium, Al Khor (UTC+3)
+              (Enner Valencia 16' (pen.), 31')
+`, "cup.txt")
+
+	files := Files{Teams: teams, Players: players, OpenFootball: []string{dataset}}
+	changes, err := Import(store, files, Options{})
+	assert.NoError(err)
+	assert.Contains(changes, Change{Action: Insert, Table: "countries", Key: "QAT"})
+	assert.Contains(changes.Summary(), "events: 2 inserted, 0 updated, 0 deleted")
+
+	var match models.Match
+	store.DB.Preload("Events").Joins("ACountry").Joins("BCountry").First(&match)
+	assert.True(match.Played)
+	assert.Equal("Ecuador", match.BCountry.Name)
+	assert.Equal(2, match.BScore)
+	assert.Len(match.Events, 2)
+	assert.Equal(match.BID, match.Events[0].CountryID)
+
+	var player models.Player
+	store.DB.First(&player, "name = ?", "Enner Valencia")
+	assert.EqualValues(2, player.Goals)
+
+	changes, err = Import(store, files, Options{})
+	assert.NoError(err)
+	assert.Empty(changes)
+}
+
+func TestExport(t *testing.T) {
+	assert := assert.New(t)
+
+	store.LinkTables(true)
+	countryCache = nil
+
+	teams := createYaml("- name: Qatar\n  code: QAT\n  group: A\n- name: Ecuador\n  code: ECU\n  group: A\n", "export_teams.yaml")
+	players := createYaml("- name: Enner Valencia\n  country: ECU\n  number: 13\n  position: FW\n", "export_players.yaml")
+	matches := createYaml(`- a: Qatar
+  b: Ecuador
+  date: 20-Nov-22
+  time: '16:00'
+  stage: GROUP
+  score: 0-2
+  events:
+    - {kind: goal, country: Ecuador, player: Enner Valencia, minute: 16, penalty: true}
+    - {kind: yellow, country: Qatar, player: Boualem Khoukhi, minute: 45, offset: 2}
+- a: Team A
+  b: Team B
+  date: 18-Dec-22
+  time: '15:00'
+  stage: FINAL
+`, "export_matches.yaml")
+	ratings := createYaml("- {country: Ecuador, rating: 1650}\n", "export_ratings.yaml")
+
+	_, err := Import(store, Files{Teams: teams, Matches: matches, Players: players, Ratings: ratings}, Options{})
+	assert.NoError(err)
+
+	// The ratings start from the imported ones, and then count the result
+	var ecuador models.Country
+	store.DB.Where(&models.Country{FifaCode: "ECU"}).First(&ecuador)
+	assert.Equal(1650.0, ecuador.BaseRating)
+	assert.Greater(ecuador.Rating, 1650.0)
+
+	tables, err := Export(store.DB)
+	assert.NoError(err)
+	assert.Len(tables.Teams, 2)
+	assert.Len(tables.Matches, 2)
+	assert.Equal("0-2", tables.Matches[0].Score.String())
+	assert.Len(tables.Matches[0].Events, 2)
+	assert.Nil(tables.Matches[1].Score)
+	assert.Equal([]utils.Rating{{Country: "QAT", Rating: 1500}, {Country: "ECU", Rating: 1650}}, tables.Ratings)
+
+	for _, format := range []utils.Format{utils.YAML, utils.JSON, utils.CSV} {
+		paths, err := tables.Write(filepath.Join(TempDir, "export", string(format)), format)
+		assert.NoError(err)
+
+		files := Files{Teams: paths[0], Matches: paths[1], Players: paths[2], Ratings: paths[3]}
+		changes, err := Import(store, files, Options{Prune: true})
+		assert.NoError(err, format)
+		assert.Empty(changes, format)
+	}
+}
+
+func TestExportHistorical(t *testing.T) {
+	assert := assert.New(t)
+
+	store.LinkTables(true)
+	countryCache = nil
+
+	dataset := createYaml(`= World Cup 1930
+
+▪ Group 1
+Sun Jul 13
+  15:00     France   4-1 (3-0)   Mexico     @ Estadio Pocitos, Montevideo
+`, "historical_cup.txt")
+
+	_, err := Import(store, Files{OpenFootball: []string{dataset}}, Options{})
+	assert.NoError(err)
+
+	tables, err := Export(store.DB)
+	assert.NoError(err)
+	assert.Len(tables.Matches, 1)
+	assert.Equal(1930, tables.Matches[0].Date.Year())
+
+	for _, format := range []utils.Format{utils.YAML, utils.JSON, utils.CSV} {
+		paths, err := tables.Write(filepath.Join(TempDir, "historical", string(format)), format)
+		assert.NoError(err)
+
+		matches, err := utils.LoadMatches(paths[1])
+		assert.NoError(err, format)
+		assert.Equal(tables.Matches[0].Date, matches[0].Date, format)
+
+		changes, err := Import(store, Files{Teams: paths[0], Matches: paths[1]}, Options{Prune: true})
+		assert.NoError(err, format)
+		assert.Empty(changes, format)
+	}
+}
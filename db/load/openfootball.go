@@ -0,0 +1,91 @@
+package load
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/cazier/wc/db/load/openfootball"
+	"github.com/cazier/wc/db/models"
+	"gorm.io/gorm"
+)
+
+// OpenFootball imports the teams and matches (with their results and goals) from an openfootball dataset. The
+// datasets don't always include the FIFA codes, so countries are matched by name where they already exist, and are
+// otherwise given a code made from their name.
+func OpenFootball(tx *gorm.DB, path string, options Options) (Changes, error) {
+	var existing []models.Country
+
+	tournament, err := openfootball.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make(map[string]string)
+	used := make(map[string]bool)
+
+	for _, country := range existing {
+		codes[strings.ToLower(country.Name)] = country.FifaCode
+		used[country.FifaCode] = true
+	}
+
+	for _, team := range tournament.Teams {
+		used[team.Code] = team.Code != ""
+	}
+
+	for index, team := range tournament.Teams {
+		if team.Code != "" {
+			continue
+		}
+
+		if code, found := codes[strings.ToLower(team.Name)]; found {
+			tournament.Teams[index].Code = code
+			continue
+		}
+
+		tournament.Teams[index].Code = generateCode(team.Name, used)
+	}
+
+	changes, err := importTeams(tx, path, tournament.Teams, options)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := importMatches(tx, path, tournament.Matches, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(changes, output...), nil
+}
+
+// generateCode makes a three letter code for a country from the letters in its name, avoiding any codes already used
+func generateCode(name string, used map[string]bool) string {
+	var letters []rune
+
+	for _, char := range name {
+		if unicode.IsLetter(char) {
+			letters = append(letters, unicode.ToUpper(char))
+		}
+	}
+
+	for len(letters) < 3 {
+		letters = append(letters, 'X')
+	}
+
+	code := string(letters[:3])
+	for index := 3; used[code] && index < len(letters); index++ {
+		code = string(letters[:2]) + string(letters[index])
+	}
+
+	for number := 1; used[code]; number++ {
+		code = fmt.Sprintf("%s%d", string(letters[:2]), number)
+	}
+
+	used[code] = true
+	return code
+}
@@ -0,0 +1,251 @@
+package openfootball
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"gopkg.in/yaml.v3"
+)
+
+// The worldcup.json files come in two layouts. The older one nests the matches under "rounds", with the scores as
+// "score1"/"score2" and each team as an object with a name and code. The newer one lists the "matches" directly, with
+// a "score" object and each team as a plain name. Both are decoded into the same structure.
+type jsonMatch struct {
+	Round    string
+	Date     string
+	Time     string
+	Timezone string
+	Group    string
+	Team1    jsonTeam
+	Team2    jsonTeam
+
+	Score    *jsonScore
+	Score1   *int
+	Score2   *int
+	Score1et *int
+	Score2et *int
+	Score1p  *int
+	Score2p  *int
+
+	Goals1 []jsonGoal
+	Goals2 []jsonGoal
+}
+
+type jsonTeam struct {
+	Name string
+	Code string
+}
+
+type jsonScore struct {
+	FT []int
+	ET []int
+	P  []int
+}
+
+type jsonGoal struct {
+	Name    string
+	Minute  jsonMinute
+	Offset  int
+	Penalty bool
+	Owngoal bool
+}
+
+type jsonMinute struct {
+	Minute int
+	Offset int
+}
+
+func (t *jsonTeam) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		t.Name = node.Value
+		return nil
+	}
+
+	var base struct {
+		Name string
+		Code string
+	}
+
+	err := node.Decode(&base)
+	t.Name, t.Code = base.Name, base.Code
+
+	return err
+}
+
+// UnmarshalYAML accepts the minute as either a number, or text with the stoppage time like "90+3"
+func (m *jsonMinute) UnmarshalYAML(node *yaml.Node) error {
+	parts := strings.SplitN(strings.TrimSuffix(node.Value, "'"), "+", 2)
+
+	minute, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return fmt.Errorf("could not parse the minute: `%s`", node.Value)
+	}
+	m.Minute = minute
+
+	if len(parts) == 2 {
+		if m.Offset, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return fmt.Errorf("could not parse the minute: `%s`", node.Value)
+		}
+	}
+
+	return nil
+}
+
+// result picks the final score, using the extra time score when there was one
+func (m *jsonMatch) result() (score *utils.Score, penalties *utils.Score) {
+	pair := func(values []int) *utils.Score {
+		if len(values) != 2 {
+			return nil
+		}
+		return &utils.Score{A: values[0], B: values[1]}
+	}
+
+	single := func(a, b *int) *utils.Score {
+		if a == nil || b == nil {
+			return nil
+		}
+		return &utils.Score{A: *a, B: *b}
+	}
+
+	if m.Score != nil {
+		if score = pair(m.Score.ET); score == nil {
+			score = pair(m.Score.FT)
+		}
+		return score, pair(m.Score.P)
+	}
+
+	if score = single(m.Score1et, m.Score2et); score == nil {
+		score = single(m.Score1, m.Score2)
+	}
+	return score, single(m.Score1p, m.Score2p)
+}
+
+func parseJson(data []byte) (*Tournament, []*exceptions.ValidationError) {
+	var document yaml.Node
+	var errs []*exceptions.ValidationError
+
+	b := newBuilder()
+
+	if err := yaml.Unmarshal(data, &document); err != nil || len(document.Content) == 0 {
+		message := fmt.Sprintf("could not parse the file: %v", err)
+		return nil, []*exceptions.ValidationError{{Line: 1, Col: 1, Message: message}}
+	}
+
+	root := document.Content[0]
+	b.tournament.Name = value(root, "name").Value
+
+	type entry struct {
+		node  *yaml.Node
+		round string
+	}
+
+	var entries []entry
+
+	for _, node := range value(root, "matches").Content {
+		entries = append(entries, entry{node: node})
+	}
+
+	for _, round := range value(root, "rounds").Content {
+		for _, node := range value(round, "matches").Content {
+			entries = append(entries, entry{node: node, round: value(round, "name").Value})
+		}
+	}
+
+	for _, entry := range entries {
+		var item jsonMatch
+
+		line, col := entry.node.Line, entry.node.Column
+		fail := func(format string, args ...any) {
+			errs = append(errs, &exceptions.ValidationError{Line: line, Col: col, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if err := entry.node.Decode(&item); err != nil {
+			fail("%s", err.Error())
+			continue
+		}
+
+		if item.Round == "" {
+			item.Round = entry.round
+		}
+
+		match := utils.Match{A: item.Team1.Name, B: item.Team2.Name, Line: line}
+
+		if match.A == "" || match.B == "" {
+			fail("the match is missing one of its teams")
+			continue
+		}
+
+		var err error
+		if match.Stage, err = stage(item.Round); err != nil {
+			fail("%s", err.Error())
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", item.Date)
+		if err != nil {
+			fail("could not parse the date: `%s`", item.Date)
+			continue
+		}
+
+		// The time may include the offset, like "19:00 UTC+3"
+		clock := ""
+		if fields := strings.Fields(item.Time); len(fields) > 0 {
+			clock = fields[0]
+		}
+
+		offset := zone(item.Time + " " + item.Timezone)
+		if match.Date, err = kickoff(date, clock, offset); err != nil {
+			fail("%s", err.Error())
+			continue
+		}
+
+		match.Score, match.Penalties = item.result()
+
+		if match.Score != nil {
+			match.Events = []utils.Event{}
+			for side, goals := range [][]jsonGoal{item.Goals1, item.Goals2} {
+				country := []string{match.A, match.B}[side]
+
+				for _, goal := range goals {
+					offset := goal.Minute.Offset
+					if goal.Offset != 0 {
+						offset = goal.Offset
+					}
+
+					match.Events = append(match.Events, utils.Event{
+						Kind:    models.GOAL,
+						Country: country,
+						Player:  goal.Name,
+						Minute:  goal.Minute.Minute,
+						Offset:  offset,
+						Penalty: goal.Penalty,
+						OwnGoal: goal.Owngoal,
+					})
+				}
+			}
+		}
+
+		b.team(item.Team1.Name, item.Team1.Code, "", line)
+		b.team(item.Team2.Name, item.Team2.Code, "", line)
+		b.match(match, group(item.Group))
+	}
+
+	return &b.tournament, errs
+}
+
+// value finds the value for a key in a mapping node, returning an empty node when it is missing
+func value(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			if node.Content[index].Value == key {
+				return node.Content[index+1]
+			}
+		}
+	}
+	return &yaml.Node{}
+}
@@ -0,0 +1,157 @@
+// Package openfootball reads the public openfootball world cup datasets (https://github.com/openfootball) from local
+// files, in either the worldcup.json layout or the football.txt plain text layout, into the same types used by the
+// other importers.
+package openfootball
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+)
+
+type Tournament struct {
+	Name    string
+	Teams   []utils.Team
+	Matches []utils.Match
+}
+
+func Read(path string) (*Tournament, error) {
+	var tournament *Tournament
+	var errs []*exceptions.ValidationError
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not read the file %s because the file doesn't exist", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read the file %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		tournament, errs = parseJson(data)
+	case ".txt":
+		tournament, errs = parseText(data)
+	default:
+		return nil, fmt.Errorf("unsupported openfootball file: `%s`", path)
+	}
+
+	if errs != nil {
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+	}
+
+	return tournament, nil
+}
+
+// builder collects the teams as they are found in the matches, along with their group if one is known
+type builder struct {
+	tournament Tournament
+	teams      map[string]int
+}
+
+func newBuilder() *builder {
+	return &builder{teams: make(map[string]int)}
+}
+
+func (b *builder) team(name, code, group string, line int) {
+	index, found := b.teams[name]
+
+	if !found {
+		b.teams[name] = len(b.tournament.Teams)
+		b.tournament.Teams = append(b.tournament.Teams, utils.Team{Name: name, Code: code, Line: line})
+		index = b.teams[name]
+	}
+
+	if code != "" {
+		b.tournament.Teams[index].Code = code
+	}
+
+	if group != "" {
+		b.tournament.Teams[index].Group = group
+	}
+}
+
+func (b *builder) match(match utils.Match, group string) {
+	if match.Stage != models.GROUP {
+		group = ""
+	}
+
+	b.team(match.A, "", group, match.Line)
+	b.team(match.B, "", group, match.Line)
+
+	b.tournament.Matches = append(b.tournament.Matches, match)
+}
+
+// stage converts the openfootball round names (like "Matchday 2" or "Quarter-finals") into a Stage
+func stage(round string) (models.Stage, error) {
+	name := strings.NewReplacer("-", "", " ", "", "_", "").Replace(strings.ToLower(round))
+
+	switch {
+	case strings.HasPrefix(name, "matchday"), strings.HasPrefix(name, "group"):
+		return models.GROUP, nil
+	case name == "roundof16", name == "eighthfinals":
+		return models.ROUND_OF_SIXTEEN, nil
+	case strings.HasPrefix(name, "quarterfinal"):
+		return models.QUARTERFINALS, nil
+	case strings.HasPrefix(name, "semifinal"):
+		return models.SEMIFINALS, nil
+	case strings.Contains(name, "third"), strings.Contains(name, "3rd"):
+		return models.THIRD_PLACE, nil
+	case name == "final":
+		return models.FINAL, nil
+	}
+	return 0, fmt.Errorf("unsupported round: `%s`", round)
+}
+
+// group strips the "Group " prefix, e.g. "Group A" becomes "A"
+func group(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(strings.ToLower(name), "group ") {
+		return strings.TrimSpace(name[len("group "):])
+	}
+	return ""
+}
+
+var zonePattern = regexp.MustCompile(`UTC\s*([+-])\s*(\d{1,2})(?::?(\d{2}))?`)
+
+// zone finds a "UTC+3" style offset in the text, returning the number of seconds east of UTC
+func zone(text string) int {
+	match := zonePattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0
+	}
+
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	offset := hours*3600 + minutes*60
+
+	if match[1] == "-" {
+		return -offset
+	}
+	return offset
+}
+
+// kickoff combines the local date and time of a match with its UTC offset
+func kickoff(date time.Time, clock string, offset int) (time.Time, error) {
+	hour, minute := 0, 0
+
+	if clock = strings.TrimSpace(clock); clock != "" {
+		parsed, err := time.Parse("15:04", strings.Replace(clock, ".", ":", 1))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("could not parse the time: `%s`", clock)
+		}
+		hour, minute = parsed.Hour(), parsed.Minute()
+	}
+
+	local := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.FixedZone("", offset))
+	return local.UTC(), nil
+}
@@ -0,0 +1,207 @@
+package openfootball
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var TempDir string
+
+func TestMain(m *testing.M) {
+	TempDir, _ = os.MkdirTemp("", "go_test")
+
+	status := m.Run()
+
+	os.RemoveAll(TempDir)
+	os.Exit(status)
+}
+
+func write(name, data string) string {
+	path := filepath.Join(TempDir, name)
+	os.WriteFile(path, []byte(data), os.ModePerm)
+	return path
+}
+
+func TestReadJsonRounds(t *testing.T) {
+	assert := assert.New(t)
+
+	path := write("rounds.json", `{
+  "name": "World Cup 2018",
+  "rounds": [
+    {
+      "name": "Matchday 1",
+      "matches": [
+        {
+          "num": 1, "date": "2018-06-14", "time": "18:00", "timezone": "UTC+3",
+          "team1": {"name": "Russia", "code": "RUS"}, "team2": {"name": "Saudi Arabia", "code": "KSA"},
+          "score1": 5, "score2": 0,
+          "goals1": [{"name": "Gazinsky", "minute": 12}, {"name": "Cheryshev", "minute": 90, "offset": 1}],
+          "goals2": [],
+          "group": "Group A"
+        }
+      ]
+    },
+    {
+      "name": "Final",
+      "matches": [
+        {
+          "date": "2018-07-15", "time": "18:00", "timezone": "UTC+3",
+          "team1": {"name": "France", "code": "FRA"}, "team2": {"name": "Croatia", "code": "CRO"},
+          "score1": null, "score2": null
+        }
+      ]
+    }
+  ]
+}`)
+
+	tournament, err := Read(path)
+	assert.NoError(err)
+	assert.Equal("World Cup 2018", tournament.Name)
+
+	assert.Equal([]utils.Team{
+		{Name: "Russia", Code: "RUS", Group: "A", Line: 7},
+		{Name: "Saudi Arabia", Code: "KSA", Group: "A", Line: 7},
+		{Name: "France", Code: "FRA", Line: 20},
+		{Name: "Croatia", Code: "CRO", Line: 20},
+	}, tournament.Teams)
+
+	assert.Len(tournament.Matches, 2)
+
+	opening := tournament.Matches[0]
+	assert.Equal(models.GROUP, opening.Stage)
+	assert.Equal(time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC), opening.Date)
+	assert.Equal(&utils.Score{A: 5, B: 0}, opening.Score)
+	assert.Equal([]utils.Event{
+		{Kind: models.GOAL, Country: "Russia", Player: "Gazinsky", Minute: 12},
+		{Kind: models.GOAL, Country: "Russia", Player: "Cheryshev", Minute: 90, Offset: 1},
+	}, opening.Events)
+
+	final := tournament.Matches[1]
+	assert.Equal(models.FINAL, final.Stage)
+	assert.Nil(final.Score)
+	assert.Nil(final.Events)
+}
+
+func TestReadJsonMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	path := write("matches.json", `{
+  "name": "World Cup 2022",
+  "matches": [
+    {
+      "round": "Final", "date": "2022-12-18", "time": "18:00 UTC+3",
+      "team1": "Argentina", "team2": "France",
+      "score": {"ft": [2, 2], "ht": [2, 0], "et": [3, 3], "p": [4, 2]},
+      "goals1": [{"name": "Lionel Messi", "minute": "23", "penalty": true}],
+      "goals2": [{"name": "Kylian Mbappé", "minute": "90+7"}]
+    },
+    {
+      "round": "Round of 32", "date": "2022-12-01", "time": "18:00",
+      "team1": "Argentina", "team2": "France"
+    }
+  ]
+}`)
+
+	_, err := Read(path)
+
+	var validation *exceptions.ValidationErrors
+	assert.ErrorAs(err, &validation)
+	assert.Equal([]*exceptions.ValidationError{{Line: 11, Col: 5, Message: "unsupported round: `Round of 32`"}}, validation.Errors)
+
+	path = write("matches.json", `{"matches": [{
+  "round": "Final", "date": "2022-12-18", "time": "18:00 UTC+3",
+  "team1": "Argentina", "team2": "France",
+  "score": {"ft": [2, 2], "ht": [2, 0], "et": [3, 3], "p": [4, 2]},
+  "goals1": [{"name": "Lionel Messi", "minute": "23", "penalty": true}],
+  "goals2": [{"name": "Kylian Mbappé", "minute": "90+7"}]
+}]}`)
+
+	tournament, err := Read(path)
+	assert.NoError(err)
+
+	final := tournament.Matches[0]
+	assert.Equal(time.Date(2022, 12, 18, 15, 0, 0, 0, time.UTC), final.Date)
+	assert.Equal(&utils.Score{A: 3, B: 3}, final.Score)
+	assert.Equal(&utils.Score{A: 4, B: 2}, final.Penalties)
+	assert.Equal([]utils.Event{
+		{Kind: models.GOAL, Country: "Argentina", Player: "Lionel Messi", Minute: 23, Penalty: true},
+		{Kind: models.GOAL, Country: "France", Player: "Kylian Mbappé", Minute: 90, Offset: 7},
+	}, final.Events)
+}
+
+func TestReadText(t *testing.T) {
+	assert := assert.New(t)
+
+	path := write("cup.txt", `= World Cup 2022
+
+Group A  |  Qatar  Ecuador  Senegal  Netherlands
+
+Matchday 1  |  Sun Nov 20
+
+▪ Group A
+Sun Nov 20
+  19:00     Qatar   0-2 (0-2)   Ecuador     @ Al Bayt Stadium, Al Khor (UTC+3)
+              (Enner Valencia 16' (pen.), 31')
+Mon Nov 21
+  19:00     Senegal   v   Netherlands     @ Al Thumama Stadium, Doha (UTC+3)
+
+▪ Final
+(64) Sun Dec 18 18:00   Argentina  3-3 a.e.t. (2-2, 2-0) 4-2 pen.  France   @ Lusail Stadium (UTC+3)
+              (Messi 23' (pen.), 108'  Di María 36'; Mbappé 80' (pen.), 81', 118' (pen.))
+`)
+
+	tournament, err := Read(path)
+	assert.NoError(err)
+	assert.Equal("World Cup 2022", tournament.Name)
+
+	assert.Len(tournament.Teams, 6)
+	assert.Equal(utils.Team{Name: "Netherlands", Group: "A", Line: 3}, tournament.Teams[3])
+	assert.Equal(utils.Team{Name: "Argentina", Line: 15}, tournament.Teams[4])
+
+	assert.Len(tournament.Matches, 3)
+
+	opening := tournament.Matches[0]
+	assert.Equal("Qatar", opening.A)
+	assert.Equal("Ecuador", opening.B)
+	assert.Equal(time.Date(2022, 11, 20, 16, 0, 0, 0, time.UTC), opening.Date)
+	assert.Equal(&utils.Score{A: 0, B: 2}, opening.Score)
+	assert.Equal([]utils.Event{
+		{Kind: models.GOAL, Country: "Ecuador", Player: "Enner Valencia", Minute: 16, Penalty: true},
+		{Kind: models.GOAL, Country: "Ecuador", Player: "Enner Valencia", Minute: 31},
+	}, opening.Events)
+
+	fixture := tournament.Matches[1]
+	assert.Equal("Senegal", fixture.A)
+	assert.Equal("Netherlands", fixture.B)
+	assert.Nil(fixture.Score)
+
+	final := tournament.Matches[2]
+	assert.Equal(models.FINAL, final.Stage)
+	assert.Equal(&utils.Score{A: 3, B: 3}, final.Score)
+	assert.Equal(&utils.Score{A: 4, B: 2}, final.Penalties)
+	assert.Len(final.Events, 6)
+	assert.Equal(utils.Event{Kind: models.GOAL, Country: "Argentina", Player: "Di María", Minute: 36}, final.Events[2])
+	assert.Equal(utils.Event{Kind: models.GOAL, Country: "France", Player: "Mbappé", Minute: 118, Penalty: true}, final.Events[5])
+}
+
+func TestReadTextBad(t *testing.T) {
+	var validation *exceptions.ValidationErrors
+
+	_, err := Read(write("cup.txt", "Sun Nov 20\n  19:00  Qatar  0-2  Ecuador\n"))
+	assert.ErrorAs(t, err, &validation)
+	assert.Equal(t, 1, validation.Errors[0].Line)
+
+	_, err = Read(write("cup.txt", "= World Cup 2022\n  19:00  Qatar  0-2  Ecuador\n"))
+	assert.ErrorAs(t, err, &validation)
+	assert.Equal(t, []*exceptions.ValidationError{{Line: 2, Col: 1, Message: "the match is listed before any date"}}, validation.Errors)
+
+	_, err = Read(write("cup.csv", ""))
+	assert.Error(t, err)
+}
@@ -0,0 +1,247 @@
+package openfootball
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+)
+
+// The football.txt layout is meant to be written by hand, so only the common parts of it are understood here:
+//
+//	= World Cup 2022
+//	Group A  |  Qatar  Ecuador  Senegal  Netherlands
+//
+//	▪ Group A
+//	Sun Nov 20
+//	  19:00     Qatar   0-2 (0-2)   Ecuador     @ Al Bayt Stadium, Al Khor (UTC+3)
+//	              (Enner Valencia 16' (pen.), 31')
+//
+//	▪ Final
+//	(64) Sun Dec 18 18:00   Argentina  3-3 a.e.t. (2-2, 2-0) 4-2 pen.  France
+//	              (Messi 23' (pen.), 108'  Di María 36'; Mbappé 80' (pen.), 81', 118' (pen.))
+//
+// Goals for each team are separated by a semicolon. Lines that aren't recognized (like the matchday listings) are
+// skipped.
+var (
+	titlePattern   = regexp.MustCompile(`^=+\s*(.*?(\d{4}).*)$`)
+	groupsPattern  = regexp.MustCompile(`^(Group\s+\S+)\s*\|\s*(.+)$`)
+	headingPattern = regexp.MustCompile(`^(?:▪|»|::)?\s*((?:Group\s+\S+|Round of 16|Eighth-finals|Quarter-?finals?|Semi-?finals?|Match for third place|Third[- ]place.*|Final)(?:\s*\|.*)?)$`)
+	numberPattern  = regexp.MustCompile(`^\(\d+\)\s*`)
+	datePattern    = regexp.MustCompile(`^(?:(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun)\w*\s+)?(Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\w*[\s/]+(\d{1,2})\b\s*(.*)$`)
+	timePattern    = regexp.MustCompile(`^(\d{1,2}[:.]\d{2})\b\s*(.*)$`)
+	playedPattern  = regexp.MustCompile(`^(.+?)\s+(\d+)-(\d+)((?:\s+(?:a\.?e\.?t\.?|\([\d\s,-]+\)|\d+-\d+\s*(?:pen\.?|p\.?)))*)\s+(.+)$`)
+	fixturePattern = regexp.MustCompile(`^(.+?)\s+(?:v|vs\.?|-)\s+(.+)$`)
+	penaltyPattern = regexp.MustCompile(`(\d+)-(\d+)\s*(?:pen\.?|p\.?)`)
+	goalsPattern   = regexp.MustCompile(`^[(\[](\D.*)[)\]]$`)
+	minutePattern  = regexp.MustCompile(`^(\d+)(?:'?\+(\d+))?'$`)
+	venuePattern   = regexp.MustCompile(`\s+@.*$|\s*\(UTC[^)]*\)\s*$`)
+	spacesPattern  = regexp.MustCompile(`\s{2,}`)
+)
+
+func parseText(data []byte) (*Tournament, []*exceptions.ValidationError) {
+	var errs []*exceptions.ValidationError
+	var date time.Time
+	var last *utils.Match
+
+	b := newBuilder()
+	year := 0
+	round := "Group"
+	currentGroup := ""
+
+	for index, raw := range strings.Split(string(data), "\n") {
+		line := index + 1
+		text := strings.TrimSpace(raw)
+
+		fail := func(format string, args ...any) {
+			errs = append(errs, &exceptions.ValidationError{Line: line, Col: 1, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if match := titlePattern.FindStringSubmatch(text); match != nil {
+			b.tournament.Name = match[1]
+			year, _ = strconv.Atoi(match[2])
+			continue
+		}
+
+		if match := groupsPattern.FindStringSubmatch(text); match != nil {
+			for _, name := range spacesPattern.Split(strings.TrimSpace(match[2]), -1) {
+				b.team(name, "", group(match[1]), line)
+			}
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(text); match != nil {
+			round = strings.TrimSpace(strings.SplitN(match[1], "|", 2)[0])
+			currentGroup = group(round)
+			continue
+		}
+
+		if match := goalsPattern.FindStringSubmatch(text); match != nil && last != nil {
+			if err := goals(last, match[1]); err != nil {
+				fail("%s", err.Error())
+			}
+			continue
+		}
+
+		offset := zone(text)
+		text = numberPattern.ReplaceAllString(text, "")
+
+		if match := datePattern.FindStringSubmatch(text); match != nil {
+			if year == 0 {
+				fail("the year of the tournament must be given in the title, like `= World Cup 2022`")
+				return nil, errs
+			}
+
+			parsed, err := time.Parse("Jan 2 2006", fmt.Sprintf("%s %s %d", match[1], match[2], year))
+			if err != nil {
+				fail("could not parse the date: `%s %s`", match[1], match[2])
+				continue
+			}
+
+			date, text = parsed, match[3]
+			if text == "" {
+				continue
+			}
+		}
+
+		match := timePattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		if date.IsZero() {
+			fail("the match is listed before any date")
+			continue
+		}
+
+		parsed, err := parseMatch(venuePattern.ReplaceAllString(match[2], ""))
+		if err != nil {
+			fail("%s", err.Error())
+			continue
+		}
+
+		parsed.Line = line
+		if parsed.Stage, err = stage(round); err != nil {
+			fail("%s", err.Error())
+			continue
+		}
+
+		if parsed.Date, err = kickoff(date, match[1], offset); err != nil {
+			fail("%s", err.Error())
+			continue
+		}
+
+		b.match(*parsed, currentGroup)
+		last = &b.tournament.Matches[len(b.tournament.Matches)-1]
+	}
+
+	return &b.tournament, errs
+}
+
+// parseMatch reads the teams, and the score if the match has been played, from the rest of a match line
+func parseMatch(text string) (*utils.Match, error) {
+	if match := playedPattern.FindStringSubmatch(text); match != nil {
+		a, _ := strconv.Atoi(match[2])
+		b, _ := strconv.Atoi(match[3])
+
+		result := &utils.Match{
+			A:      strings.TrimSpace(match[1]),
+			B:      strings.TrimSpace(match[5]),
+			Score:  &utils.Score{A: a, B: b},
+			Events: []utils.Event{},
+		}
+
+		if penalties := penaltyPattern.FindStringSubmatch(match[4]); penalties != nil {
+			a, _ = strconv.Atoi(penalties[1])
+			b, _ = strconv.Atoi(penalties[2])
+			result.Penalties = &utils.Score{A: a, B: b}
+		}
+
+		return result, nil
+	}
+
+	if match := fixturePattern.FindStringSubmatch(text); match != nil {
+		return &utils.Match{A: strings.TrimSpace(match[1]), B: strings.TrimSpace(match[2])}, nil
+	}
+
+	return nil, fmt.Errorf("could not find the teams in the match: `%s`", text)
+}
+
+// goals adds the goals listed after a match, like "Messi 23' (pen.), 108'  Di María 36'; Mbappé 80'"
+func goals(match *utils.Match, text string) error {
+	if match.Score == nil {
+		return fmt.Errorf("goals are listed for a match without a score")
+	}
+
+	sides := strings.Split(text, ";")
+	countries := []string{match.A, match.B}
+
+	// With only one list of goals, it can only belong to the team that scored
+	if len(sides) == 1 && match.Score.A == 0 && match.Score.B > 0 {
+		countries = []string{match.B}
+	}
+
+	if len(sides) > 2 {
+		return fmt.Errorf("expected the goals for at most two teams: `%s`", text)
+	}
+
+	markers := strings.NewReplacer("(pen.)", " pen ", "(p)", " pen ", "(o.g.)", " og ", "(og)", " og ", ",", " ")
+
+	for side, list := range sides {
+		list = markers.Replace(list)
+
+		var name []string
+		var previous *utils.Event
+
+		for _, token := range strings.Fields(list) {
+			minute := minutePattern.FindStringSubmatch(token)
+
+			switch {
+			case minute != nil:
+				if len(name) == 0 {
+					return fmt.Errorf("a goal is missing the player's name: `%s`", text)
+				}
+
+				value, _ := strconv.Atoi(minute[1])
+				offset, _ := strconv.Atoi(minute[2])
+
+				match.Events = append(match.Events, utils.Event{
+					Kind:    models.GOAL,
+					Country: countries[side],
+					Player:  strings.Join(name, " "),
+					Minute:  value,
+					Offset:  offset,
+				})
+				previous = &match.Events[len(match.Events)-1]
+
+			case token == "pen" || token == "pen.":
+				if previous != nil {
+					previous.Penalty = true
+				}
+
+			case token == "og" || token == "o.g.":
+				if previous != nil {
+					previous.OwnGoal = true
+				}
+
+			default:
+				// A name after a minute starts the next player
+				if previous != nil {
+					name, previous = nil, nil
+				}
+				name = append(name, token)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/cazier/wc/api/exceptions"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	YAML Format = "yaml"
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+// Source describes an input file, and how it should be read
+type Source struct {
+	Path string
+	// The format of the file, which is found from the file extension when empty
+	Format Format
+	// Extra csv header names to map onto fields, on top of the built in aliases
+	Headers map[string]string
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "yaml", "yml":
+		return YAML, nil
+	case "json":
+		return JSON, nil
+	case "csv":
+		return CSV, nil
+	}
+	return "", fmt.Errorf("unsupported file format: `%s`", s)
+}
+
+// format is the format given for the source, or the one its extension is for. A file without an extension is read as
+// yaml, like every file was before the other formats were added.
+func (s Source) format() (Format, error) {
+	if s.Format != "" {
+		return s.Format, nil
+	}
+
+	extension := filepath.Ext(s.Path)
+	if extension == "" {
+		return YAML, nil
+	}
+	return ParseFormat(extension)
+}
+
+// parse reads the file contents into a yaml document, regardless of the original format, so that every format
+// shares the same decoding and validation (with line numbers) afterwards.
+func (s Source) parse(data []byte, aliases map[string]string) (*yaml.Node, error) {
+	var document yaml.Node
+
+	format, err := s.format()
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case CSV:
+		return parseCsv(data, s.headers(aliases))
+	case JSON:
+		var syntax *json.SyntaxError
+
+		if err = json.Unmarshal(data, new(any)); errors.As(err, &syntax) {
+			line, col := position(data, syntax.Offset)
+			return nil, &exceptions.ValidationError{Line: line, Col: col, Message: syntax.Error()}
+		} else if err != nil {
+			return nil, &exceptions.ValidationError{Line: 1, Col: 1, Message: err.Error()}
+		}
+		// JSON is a subset of yaml, so the yaml parser can be used to keep track of the line numbers
+		fallthrough
+	default:
+		if err = yaml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+	}
+
+	return &document, nil
+}
+
+func (s Source) headers(aliases map[string]string) map[string]string {
+	headers := make(map[string]string)
+
+	for header, field := range aliases {
+		headers[header] = field
+	}
+
+	for header, field := range s.Headers {
+		headers[normalize(header)] = field
+	}
+
+	return headers
+}
+
+// normalize turns spreadsheet style headers like "Shirt Number" into "shirt_number"
+func normalize(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(header)
+}
+
+// parseCsv builds a yaml sequence of mappings from a csv file with a header row. Each value keeps the line and
+// column it was found at, and any empty cells are left out.
+func parseCsv(data []byte, headers map[string]string) (*yaml.Node, error) {
+	var parse *csv.ParseError
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return &yaml.Node{Kind: yaml.DocumentNode}, nil
+	} else if errors.As(err, &parse) {
+		return nil, &exceptions.ValidationError{Line: parse.Line, Col: parse.Column, Message: parse.Err.Error()}
+	}
+
+	keys := make([]string, len(header))
+	for index, name := range header {
+		keys[index] = normalize(name)
+
+		if field, found := headers[keys[index]]; found {
+			keys[index] = field
+		}
+	}
+
+	root := &yaml.Node{Kind: yaml.SequenceNode, Line: 1, Column: 1}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if errors.As(err, &parse) {
+			return nil, &exceptions.ValidationError{Line: parse.Line, Col: parse.Column, Message: parse.Err.Error()}
+		}
+
+		line, _ := reader.FieldPos(0)
+		item := &yaml.Node{Kind: yaml.MappingNode, Line: line, Column: 1}
+
+		for index, value := range record {
+			if value == "" {
+				continue
+			}
+
+			line, col := reader.FieldPos(index)
+			item.Content = append(item.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: keys[index], Line: 1, Column: index + 1},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: value, Line: line, Column: col},
+			)
+		}
+
+		root.Content = append(root.Content, item)
+	}
+
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}, nil
+}
+
+// position converts a byte offset into a line and column number
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, col
+}
@@ -4,25 +4,51 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
-	"log"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cazier/wc/api/exceptions"
 	"github.com/cazier/wc/db/models"
 	"gopkg.in/yaml.v3"
 )
 
 type Team struct {
-	Name  string
-	Code  string
-	Group string
+	Name  string `json:"name"`
+	Code  string `json:"code"`
+	Group string `json:"group"`
+
+	Line int `yaml:"-" json:"-"`
 }
 
 type Player struct {
-	Name     string
-	Country  string
-	Number   int
-	Position string
+	Name     string `json:"name"`
+	Country  string `json:"country"`
+	Number   int    `json:"number"`
+	Position string `json:"position"`
+
+	Line int `yaml:"-" json:"-"`
+}
+
+// Rating is the rating of a country before the tournament, which its rating is worked out from
+type Rating struct {
+	Country string  `json:"country"`
+	Rating  float64 `json:"rating"`
+
+	Line int `yaml:"-" json:"-"`
+}
+
+// Prediction is a user's guess at a match in a pool, which is found by its id in the database. The winner is the
+// name or FIFA code of the country that goes through a knockout match.
+type Prediction struct {
+	User   string
+	Match  int
+	Score  Score
+	Winner string
+
+	Line int `yaml:"-"`
 }
 
 type Match struct {
@@ -30,90 +56,520 @@ type Match struct {
 	B     string
 	Stage models.Stage
 	Date  time.Time
+
+	// The result of the match, which is only set once it has been played
+	Score     *Score
+	Penalties *Score
+	Events    []Event
+
+	Line int `yaml:"-"`
+}
+
+// Score holds the goals for each side of a match, and is written as "2-1"
+type Score struct {
+	A int
+	B int
+}
+
+type Event struct {
+	Kind    models.EventKind `json:"kind"`
+	Country string           `json:"country"`
+	Player  string           `json:"player"`
+	Minute  int              `json:"minute"`
+	Offset  int              `yaml:"offset,omitempty" json:"offset,omitempty"`
+	Penalty bool             `yaml:"penalty,omitempty" json:"penalty,omitempty"`
+	OwnGoal bool             `yaml:"own_goal,omitempty" json:"own_goal,omitempty"`
+}
+
+// ParseEvent reads the single line form of an event used in csv files, "kind|country|player|minute[+offset][|flags]",
+// where the flags are "pen" and "og", separated by commas.
+func ParseEvent(s string) (Event, error) {
+	var event Event
+	var err error
+
+	parts := strings.Split(strings.TrimSpace(s), "|")
+	if len(parts) < 4 || len(parts) > 5 {
+		return event, fmt.Errorf("could not parse the event: `%s`", s)
+	}
+
+	event.Kind = models.EventKind(strings.TrimSpace(parts[0]))
+	event.Country = strings.TrimSpace(parts[1])
+	event.Player = strings.TrimSpace(parts[2])
+
+	if event.Minute, event.Offset, err = ParseMinute(parts[3]); err != nil {
+		return event, fmt.Errorf("could not parse the minute of the event: `%s`", s)
+	}
+
+	if len(parts) == 5 {
+		for _, flag := range strings.Split(parts[4], ",") {
+			switch strings.TrimSpace(flag) {
+			case "pen":
+				event.Penalty = true
+			case "og":
+				event.OwnGoal = true
+			case "":
+			default:
+				return event, fmt.Errorf("unknown event flag `%s`: `%s`", flag, s)
+			}
+		}
+	}
+
+	return event, nil
+}
+
+// ParseMinute reads the minute of an event, along with any stoppage time after it, like "57" or "90+3"
+func ParseMinute(s string) (int, int, error) {
+	var offset int
+
+	parts := strings.SplitN(strings.TrimSpace(s), "+", 2)
+	minute, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err == nil && len(parts) == 2 {
+		offset, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+	}
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not parse the minute: `%s`", s)
+	}
+	return minute, offset, nil
+}
+
+func (e Event) String() string {
+	var flags []string
+
+	minute := strconv.Itoa(e.Minute)
+	if e.Offset != 0 {
+		minute = fmt.Sprintf("%d+%d", e.Minute, e.Offset)
+	}
+
+	if e.Penalty {
+		flags = append(flags, "pen")
+	}
+	if e.OwnGoal {
+		flags = append(flags, "og")
+	}
+
+	parts := []string{string(e.Kind), e.Country, e.Player, minute}
+	if flags != nil {
+		parts = append(parts, strings.Join(flags, ","))
+	}
+
+	return strings.Join(parts, "|")
 }
 
-func (m *Match) UnmarshalYAML(unmarshal func(interface{}) error) error {
+var scorePattern = regexp.MustCompile(`^\s*(\d+)\s*[-:]\s*(\d+)\s*$`)
+
+func ParseScore(s string) (*Score, error) {
+	match := scorePattern.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("could not parse the score: `%s`", s)
+	}
+
+	a, _ := strconv.Atoi(match[1])
+	b, _ := strconv.Atoi(match[2])
+
+	return &Score{A: a, B: b}, nil
+}
+
+func (s Score) String() string {
+	return fmt.Sprintf("%d-%d", s.A, s.B)
+}
+
+// record is implemented by each of the importable types, to check the values that the yaml decoder itself can't
+type record interface {
+	validate(node *yaml.Node) []*exceptions.ValidationError
+	setLine(line int)
+	// aliases maps other common csv header names onto the field names
+	aliases() map[string]string
+}
+
+func (t *Team) setLine(line int)       { t.Line = line }
+func (p *Player) setLine(line int)     { p.Line = line }
+func (m *Match) setLine(line int)      { m.Line = line }
+func (r *Rating) setLine(line int)     { r.Line = line }
+func (p *Prediction) setLine(line int) { p.Line = line }
+
+func (t *Team) aliases() map[string]string {
+	return map[string]string{
+		"team":      "name",
+		"country":   "name",
+		"fifa":      "code",
+		"fifa_code": "code",
+	}
+}
+
+func (p *Player) aliases() map[string]string {
+	return map[string]string{
+		"player":       "name",
+		"team":         "country",
+		"no":           "number",
+		"shirt":        "number",
+		"shirt_number": "number",
+		"pos":          "position",
+	}
+}
+
+func (m *Match) aliases() map[string]string {
+	return map[string]string{
+		"team_a":    "a",
+		"country_a": "a",
+		"home":      "a",
+		"team_b":    "b",
+		"country_b": "b",
+		"away":      "b",
+		"round":     "stage",
+		"kickoff":   "time",
+	}
+}
+
+func (r *Rating) aliases() map[string]string {
+	return map[string]string{
+		"team":      "country",
+		"code":      "country",
+		"fifa_code": "country",
+		"elo":       "rating",
+	}
+}
+
+func (p *Prediction) aliases() map[string]string {
+	return map[string]string{
+		"name":       "user",
+		"player":     "user",
+		"match_id":   "match",
+		"id":         "match",
+		"prediction": "score",
+		"pick":       "score",
+	}
+}
+
+func (t *Team) validate(node *yaml.Node) []*exceptions.ValidationError {
+	return required(node, "name", t.Name, "code", t.Code)
+}
+
+func (p *Player) validate(node *yaml.Node) []*exceptions.ValidationError {
+	return required(node, "name", p.Name, "country", p.Country)
+}
+
+func (r *Rating) validate(node *yaml.Node) []*exceptions.ValidationError {
+	errs := required(node, "country", r.Country)
+
+	if r.Rating <= 0 {
+		errs = append(errs, invalid(nil, node, "the rating for `%s` must be more than 0", r.Country))
+	}
+
+	return errs
+}
+
+func (p *Prediction) validate(node *yaml.Node) []*exceptions.ValidationError {
+	errs := required(node, "user", p.User)
+
+	if p.Match <= 0 {
+		errs = append(errs, invalid(fields(node)["match"], node, "the match needs to be the id of a match"))
+	}
+
+	return errs
+}
+
+func (m *Match) validate(node *yaml.Node) []*exceptions.ValidationError {
+	errs := required(node, "a", m.A, "b", m.B)
+
+	for _, event := range m.Events {
+		errs = append(errs, required(node, "event country", event.Country, "event player", event.Player)...)
+
+		switch event.Kind {
+		case models.GOAL, models.YELLOW, models.RED:
+		default:
+			errs = append(errs, invalid(nil, node, "unknown event kind: `%s`", event.Kind))
+		}
+	}
+
+	if m.Events != nil && m.Score == nil {
+		errs = append(errs, invalid(nil, node, "events can only be recorded for a match with a score"))
+	}
+
+	return errs
+}
+
+func (m *Match) UnmarshalYAML(node *yaml.Node) error {
 	var base struct {
-		A     string
-		B     string
-		Stage string
-		Date  string
-		Time  string
+		A         string
+		B         string
+		Stage     string
+		Date      string
+		Time      string
+		Score     string
+		Penalties string
+		Events    yaml.Node
 	}
 
+	values := fields(node)
+
 	var tt time.Time
 	var dd time.Time
 	var err error
+	var errs []*exceptions.ValidationError
 
-	unmarshal(&base)
+	if err = node.Decode(&base); err != nil {
+		return err
+	}
 
-	if dd, err = time.Parse("02-Jan-06", base.Date); err != nil {
-		log.Printf("error: %s", err.Error())
-		panic(fmt.Errorf("could not parse the date from the yaml file: `%s`", base.Date))
+	if dd, err = parseDate(base.Date); err != nil {
+		errs = append(errs, invalid(values["date"], node, "could not parse the date: `%s`", base.Date))
 	}
 
 	if tt, err = time.Parse("15:04", base.Time); err != nil {
-		log.Printf("error: %s", err.Error())
-		panic(fmt.Errorf("could not parse the time from the yaml file: `%s`", base.Time))
+		errs = append(errs, invalid(values["time"], node, "could not parse the time: `%s`", base.Time))
+	}
+
+	if m.Stage, err = models.ParseStage(base.Stage); err != nil {
+		errs = append(errs, invalid(values["stage"], node, "%s", err.Error()))
+	}
+
+	if base.Score != "" {
+		if m.Score, err = ParseScore(base.Score); err != nil {
+			errs = append(errs, invalid(values["score"], node, "%s", err.Error()))
+		}
+	}
+
+	if base.Penalties != "" {
+		if m.Penalties, err = ParseScore(base.Penalties); err != nil {
+			errs = append(errs, invalid(values["penalties"], node, "%s", err.Error()))
+		}
+	}
+
+	switch base.Events.Kind {
+	case yaml.SequenceNode:
+		if err = base.Events.Decode(&m.Events); err != nil {
+			errs = append(errs, invalid(values["events"], node, "could not read the events: %s", err.Error()))
+		}
+
+	// In csv files, the events are all written together as text separated by semicolons
+	case yaml.ScalarNode:
+		for _, text := range strings.Split(base.Events.Value, ";") {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+
+			event, err := ParseEvent(text)
+			if err != nil {
+				errs = append(errs, invalid(values["events"], node, "%s", err.Error()))
+				break
+			}
+			m.Events = append(m.Events, event)
+		}
+	}
+
+	if errs != nil {
+		return &exceptions.ValidationErrors{Errors: errs}
 	}
 
 	m.A = base.A
 	m.B = base.B
-	m.Stage = UnmarshalText(base.Stage)
 	m.Date = time.Date(dd.Year(), dd.Month(), dd.Day(), tt.Hour(), tt.Minute(), 0, 0, time.UTC)
 
 	return nil
 }
 
-func UnmarshalText(s string) models.Stage {
-	switch s {
-	case "GROUP":
-		return models.GROUP
-	case "ROUND_OF_SIXTEEN":
-		return models.ROUND_OF_SIXTEEN
-	case "QUARTERFINALS":
-		return models.QUARTERFINALS
-	case "SEMIFINALS":
-		return models.SEMIFINALS
-	case "THIRD_PLACE":
-		return models.THIRD_PLACE
-	case "FINAL":
-		return models.FINAL
+// dateLayouts are the ways a match date can be written, with the first used for exports. Older files were written with
+// a 2-digit year, which can't tell the 1930 matches from the 2030 ones.
+var dateLayouts = []string{"02-Jan-2006", "02-Jan-06"}
+
+func parseDate(text string) (date time.Time, err error) {
+	for _, layout := range dateLayouts {
+		if date, err = time.Parse(layout, text); err == nil {
+			return date, nil
+		}
+	}
+	return date, err
+}
+
+func (p *Prediction) UnmarshalYAML(node *yaml.Node) error {
+	var base struct {
+		User   string
+		Match  int
+		Score  string
+		Winner string
+	}
+
+	if err := node.Decode(&base); err != nil {
+		return err
+	}
+
+	score, err := ParseScore(base.Score)
+	if err != nil {
+		return &exceptions.ValidationErrors{Errors: []*exceptions.ValidationError{invalid(fields(node)["score"], node, "%s", err.Error())}}
+	}
+
+	*p = Prediction{User: base.User, Match: base.Match, Score: *score, Winner: base.Winner}
+	return nil
+}
+
+// fields maps the keys of a yaml mapping node to their value nodes, so errors can point at the offending value
+func fields(node *yaml.Node) map[string]*yaml.Node {
+	values := make(map[string]*yaml.Node)
+
+	if node.Kind != yaml.MappingNode {
+		return values
+	}
+
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		values[node.Content[index].Value] = node.Content[index+1]
+	}
+
+	return values
+}
+
+// invalid creates a ValidationError located at the value node, or its parent if the value is missing entirely
+func invalid(value *yaml.Node, parent *yaml.Node, format string, args ...any) *exceptions.ValidationError {
+	if value == nil {
+		value = parent
 	}
-	panic(fmt.Errorf("could not parse stage value: %s", s))
+	return &exceptions.ValidationError{Line: value.Line, Col: value.Column, Message: fmt.Sprintf(format, args...)}
 }
 
-func load(path string, i interface{}) {
+// required checks that none of the values are empty, with the arguments given as alternating key/value pairs
+func required(node *yaml.Node, pairs ...string) []*exceptions.ValidationError {
+	var errs []*exceptions.ValidationError
+
+	for index := 0; index+1 < len(pairs); index += 2 {
+		if pairs[index+1] == "" {
+			errs = append(errs, invalid(nil, node, "missing a value for the required field `%s`", pairs[index]))
+		}
+	}
+
+	return errs
+}
+
+var typeErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// convert turns the different errors returned by the yaml decoder into a list of ValidationErrors
+func convert(err error, node *yaml.Node) []*exceptions.ValidationError {
+	var validation *exceptions.ValidationErrors
+	var single *exceptions.ValidationError
+	var typeError *yaml.TypeError
+
+	if errors.As(err, &validation) {
+		return validation.Errors
+	} else if errors.As(err, &single) {
+		return []*exceptions.ValidationError{single}
+	}
+
+	messages := []string{err.Error()}
+	if errors.As(err, &typeError) {
+		messages = typeError.Errors
+	}
+
+	errs := make([]*exceptions.ValidationError, len(messages))
+	for index, message := range messages {
+		errs[index] = &exceptions.ValidationError{Line: node.Line, Col: node.Column, Message: message}
+
+		if match := typeErrorLine.FindStringSubmatch(message); match != nil {
+			errs[index].Line, _ = strconv.Atoi(match[1])
+			errs[index].Col = 0
+			errs[index].Message = match[2]
+		}
+	}
+
+	return errs
+}
+
+func load[T any, P interface {
+	*T
+	record
+}](source Source) ([]T, error) {
+	var errs []*exceptions.ValidationError
+	path := source.Path
+
 	data, err := os.ReadFile(path)
 	if errors.Is(err, fs.ErrNotExist) {
-		panic(fmt.Errorf("could not read the yaml file %s because the file doesn't exist", path))
+		return nil, fmt.Errorf("could not read the file %s because the file doesn't exist", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read the file %s: %w", path, err)
 	}
 
-	err = yaml.Unmarshal(data, i)
+	if _, err = source.format(); err != nil {
+		return nil, err
+	}
 
+	document, err := source.parse(data, P(new(T)).aliases())
 	if err != nil {
-		log.Printf("error: %s", err.Error())
-		panic(fmt.Errorf("could not parse the yaml file: %s", path))
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: convert(err, &yaml.Node{Line: 1, Column: 1})}
 	}
+
+	if len(document.Content) == 0 {
+		return []T{}, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		return nil, &exceptions.ValidationErrors{
+			Path:   path,
+			Errors: []*exceptions.ValidationError{invalid(root, nil, "expected the file to contain a list of items")},
+		}
+	}
+
+	items := make([]T, 0, len(root.Content))
+
+	for _, node := range root.Content {
+		var item T
+
+		if err = node.Decode(&item); err != nil {
+			errs = append(errs, convert(err, node)...)
+			continue
+		}
+
+		P(&item).setLine(node.Line)
+		errs = append(errs, P(&item).validate(node)...)
+		items = append(items, item)
+	}
+
+	if errs != nil {
+		return nil, &exceptions.ValidationErrors{Path: path, Errors: errs}
+	}
+
+	return items, nil
+}
+
+// LoadTeams reads the teams from a yaml, json or csv file, based on its extension
+func LoadTeams(path string) ([]Team, error) {
+	return load[Team](Source{Path: path})
+}
+
+func LoadTeamsFrom(source Source) ([]Team, error) {
+	return load[Team](source)
+}
+
+// LoadMatches reads the matches from a yaml, json or csv file, based on its extension
+func LoadMatches(path string) ([]Match, error) {
+	return load[Match](Source{Path: path})
 }
 
-func LoadTeams(path string) []Team {
-	team := []Team{}
-	load(path, &team)
+func LoadMatchesFrom(source Source) ([]Match, error) {
+	return load[Match](source)
+}
 
-	return team
+// LoadPlayers reads the players from a yaml, json or csv file, based on its extension
+func LoadPlayers(path string) ([]Player, error) {
+	return load[Player](Source{Path: path})
 }
 
-func LoadMatches(path string) []Match {
-	match := []Match{}
-	load(path, &match)
+func LoadPlayersFrom(source Source) ([]Player, error) {
+	return load[Player](source)
+}
 
-	return match
+// LoadRatings reads the ratings of the countries from a yaml, json or csv file, based on its extension
+func LoadRatings(path string) ([]Rating, error) {
+	return load[Rating](Source{Path: path})
 }
 
-func LoadPlayers(path string) []Player {
-	players := []Player{}
-	load(path, &players)
+func LoadRatingsFrom(source Source) ([]Rating, error) {
+	return load[Rating](source)
+}
+
+// LoadPredictions reads the predictions for a pool from a yaml, json or csv file, based on its extension
+func LoadPredictions(path string) ([]Prediction, error) {
+	return load[Prediction](Source{Path: path})
+}
 
-	return players
+func LoadPredictionsFrom(source Source) ([]Prediction, error) {
+	return load[Prediction](source)
 }
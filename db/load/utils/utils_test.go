@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cazier/wc/api/exceptions"
 	"github.com/cazier/wc/db/models"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
@@ -24,18 +25,29 @@ func TestMain(m *testing.M) {
 }
 
 func Test_load(t *testing.T) {
+	var validation *exceptions.ValidationErrors
 	path := filepath.Join(TempDir, "loadfile")
 
-	assert.PanicsWithError(
-		t, fmt.Sprintf("could not read the yaml file %s because the file doesn't exist", path), func() {
-			load(path, "")
-		})
+	_, err := load[Team](Source{Path: path, Format: YAML})
+	assert.EqualError(t, err, fmt.Sprintf("could not read the file %s because the file doesn't exist", path))
 
-	os.WriteFile(path, []byte("-"), os.ModePerm)
-	assert.PanicsWithError(
-		t, fmt.Sprintf("could not parse the yaml file: %s", path), func() {
-			load(path, "")
-		})
+	os.WriteFile(path, []byte("- name: ["), os.ModePerm)
+	_, err = load[Team](Source{Path: path, Format: YAML})
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 1)
+	assert.Equal(t, path, validation.Path)
+
+	os.WriteFile(path, []byte("name: Country A"), os.ModePerm)
+	_, err = load[Team](Source{Path: path, Format: YAML})
+	assert.ErrorAs(t, err, &validation)
+	assert.Equal(t, "expected the file to contain a list of items", validation.Errors[0].Message)
+
+	os.WriteFile(path, []byte("-\n- name: Country B\n  code: [C_B]\n"), os.ModePerm)
+	_, err = load[Team](Source{Path: path, Format: YAML})
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 3)
+	assert.Equal(t, 1, validation.Errors[0].Line)
+	assert.Equal(t, 3, validation.Errors[2].Line)
 }
 
 func TestLoadTeams(t *testing.T) {
@@ -54,7 +66,8 @@ func TestLoadTeams(t *testing.T) {
 `
 	os.WriteFile(filepath.Join(TempDir, "teams.yaml"), []byte(testData), os.ModePerm)
 
-	data := LoadTeams(filepath.Join(TempDir, "teams.yaml"))
+	data, err := LoadTeams(filepath.Join(TempDir, "teams.yaml"))
+	assert.NoError(t, err)
 	letters := []string{"A", "B", "C", "D"}
 
 	for index, team := range data {
@@ -99,7 +112,8 @@ func TestLoadMatches(t *testing.T) {
 `
 	os.WriteFile(filepath.Join(TempDir, "matches.yaml"), []byte(testData), os.ModePerm)
 
-	data := LoadMatches(filepath.Join(TempDir, "matches.yaml"))
+	data, err := LoadMatches(filepath.Join(TempDir, "matches.yaml"))
+	assert.NoError(t, err)
 	letters := []string{"A", "B", "C", "D", "E", "F"}
 
 	for index, match := range data {
@@ -112,23 +126,43 @@ func TestLoadMatches(t *testing.T) {
 }
 
 func TestMatchUnmarshalBad(t *testing.T) {
+	var validation *exceptions.ValidationErrors
+
 	date := "January 01, 2001"
 	testData := fmt.Sprintf("a: Country A_1\nb: Country A_2\ndate: %s\nstage: GROUP\ntime: '1:00'", date)
 
-	assert.PanicsWithError(t, fmt.Sprintf("could not parse the date from the yaml file: `%s`", date),
-		func() {
-			yaml.Unmarshal([]byte(testData), &Match{})
-		})
+	err := yaml.Unmarshal([]byte(testData), &Match{})
+	assert.ErrorAs(t, err, &validation)
+	assert.Equal(t, []*exceptions.ValidationError{
+		{Line: 3, Col: 7, Message: fmt.Sprintf("could not parse the date: `%s`", date)},
+	}, validation.Errors)
 
 	time := "1 AM"
-	testData = fmt.Sprintf("a: Country A_1\nb: Country A_2\ndate: 01-Jan-01\nstage: GROUP\ntime: '%s'", time)
+	testData = fmt.Sprintf("a: Country A_1\nb: Country A_2\ndate: 01-Jan-01\nstage: FIRST\ntime: '%s'", time)
+
+	err = yaml.Unmarshal([]byte(testData), &Match{})
+	assert.ErrorAs(t, err, &validation)
+	assert.Equal(t, []*exceptions.ValidationError{
+		{Line: 5, Col: 7, Message: fmt.Sprintf("could not parse the time: `%s`", time)},
+		{Line: 4, Col: 8, Message: "could not parse stage value: `FIRST`"},
+	}, validation.Errors)
+
+	testData = "a: Country A_1\nb: Country A_2\ndate: 01-Jan-01\nstage: GROUP\ntime: '1:00'\nscore: 1-0\nevents:\n  - minute: ten"
+
+	err = yaml.Unmarshal([]byte(testData), &Match{})
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 1)
+	assert.Equal(t, 8, validation.Errors[0].Line)
+	assert.Contains(t, validation.Errors[0].Message, "could not read the events")
 
-	assert.PanicsWithError(t, fmt.Sprintf("could not parse the time from the yaml file: `%s`", time),
-		func() {
-			yaml.Unmarshal([]byte(testData), &Match{})
-		})
+	testData = "a: Country A_1\nb: Country A_2\ndate: 01-Jan-01\nstage: FIRST\ntime: '1:00'\nscore: 1-0\nevents:\n  - minute: ten"
 
-	assert.Panics(t, func() { UnmarshalText("INVALID_STAGE") })
+	err = yaml.Unmarshal([]byte(testData), &Match{})
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 2)
+
+	_, err = models.ParseStage("INVALID_STAGE")
+	assert.Error(t, err)
 }
 
 func TestLoadPlayers(t *testing.T) {
@@ -139,8 +173,142 @@ func TestLoadPlayers(t *testing.T) {
 `
 	os.WriteFile(filepath.Join(TempDir, "players.yaml"), []byte(testData), os.ModePerm)
 
-	data := LoadPlayers(filepath.Join(TempDir, "players.yaml"))
+	data, err := LoadPlayers(filepath.Join(TempDir, "players.yaml"))
+	assert.NoError(t, err)
 
 	assert.IsType(t, []Player{}, data)
-	assert.EqualValues(t, []Player{{Name: "First Middle Last", Country: "ABC", Number: 1, Position: "GK"}}, data)
+	assert.EqualValues(t, []Player{{Name: "First Middle Last", Country: "ABC", Number: 1, Position: "GK", Line: 1}}, data)
+}
+
+func TestLoadRatings(t *testing.T) {
+	csvData := "Team,Elo\nABC,1712.5\nDEF,0\n"
+	os.WriteFile(filepath.Join(TempDir, "ratings.csv"), []byte(csvData), os.ModePerm)
+
+	var validation *exceptions.ValidationErrors
+	_, err := LoadRatings(filepath.Join(TempDir, "ratings.csv"))
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 1)
+	assert.Equal(t, 3, validation.Errors[0].Line)
+
+	csvData = "Team,Elo\nABC,1712.5\nDEF,1650\n"
+	os.WriteFile(filepath.Join(TempDir, "ratings.csv"), []byte(csvData), os.ModePerm)
+
+	data, err := LoadRatings(filepath.Join(TempDir, "ratings.csv"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, []Rating{{Country: "ABC", Rating: 1712.5, Line: 2}, {Country: "DEF", Rating: 1650, Line: 3}}, data)
+}
+
+func TestLoadPredictions(t *testing.T) {
+	csvData := "Name,Match ID,Pick,Winner\nAlex,1,2-1,\nSam,49,1-1,ARG\nSam,0,3-0,\nAlex,2,two,\n"
+	os.WriteFile(filepath.Join(TempDir, "predictions.csv"), []byte(csvData), os.ModePerm)
+
+	var validation *exceptions.ValidationErrors
+	_, err := LoadPredictions(filepath.Join(TempDir, "predictions.csv"))
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 2)
+	assert.Equal(t, 4, validation.Errors[0].Line)
+	assert.Equal(t, "could not parse the score: `two`", validation.Errors[1].Message)
+
+	csvData = "Name,Match ID,Pick,Winner\nAlex,1,2-1,\nSam,49,1-1,ARG\n"
+	os.WriteFile(filepath.Join(TempDir, "predictions.csv"), []byte(csvData), os.ModePerm)
+
+	data, err := LoadPredictions(filepath.Join(TempDir, "predictions.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Prediction{
+		{User: "Alex", Match: 1, Score: Score{A: 2, B: 1}, Line: 2},
+		{User: "Sam", Match: 49, Score: Score{A: 1, B: 1}, Winner: "ARG", Line: 3},
+	}, data)
+}
+
+func TestLoadFormats(t *testing.T) {
+	jsonData := `[
+	{"name": "Country A", "code": "C_A", "group": "A"},
+	{"name": "Country B", "code": "C_B", "group": "B"}
+]`
+	csvData := "Country,FIFA Code,Group\nCountry A,C_A,A\nCountry B,C_B,B\n"
+
+	os.WriteFile(filepath.Join(TempDir, "teams.json"), []byte(jsonData), os.ModePerm)
+	os.WriteFile(filepath.Join(TempDir, "teams.csv"), []byte(csvData), os.ModePerm)
+	os.WriteFile(filepath.Join(TempDir, "teams.txt"), []byte(csvData), os.ModePerm)
+
+	expected := []Team{{Name: "Country A", Code: "C_A", Group: "A"}, {Name: "Country B", Code: "C_B", Group: "B"}}
+
+	for _, source := range []Source{
+		{Path: filepath.Join(TempDir, "teams.json")},
+		{Path: filepath.Join(TempDir, "teams.csv")},
+		{Path: filepath.Join(TempDir, "teams.txt"), Format: CSV},
+	} {
+		data, err := LoadTeamsFrom(source)
+		assert.NoError(t, err, source.Path)
+
+		for index := range data {
+			assert.Equal(t, expected[index].Name, data[index].Name)
+			assert.Equal(t, expected[index].Code, data[index].Code)
+			assert.Equal(t, expected[index].Group, data[index].Group)
+			assert.Equal(t, index+2, data[index].Line)
+		}
+	}
+
+	_, err := LoadTeams(filepath.Join(TempDir, "teams.txt"))
+	assert.EqualError(t, err, "unsupported file format: `.txt`")
+
+	// A file without an extension is read as yaml
+	os.WriteFile(filepath.Join(TempDir, "teams"), []byte("- name: Country A\n  code: C_A\n  group: A\n"), os.ModePerm)
+	data, err := LoadTeams(filepath.Join(TempDir, "teams"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Team{{Name: "Country A", Code: "C_A", Group: "A", Line: 1}}, data)
+
+	csvData = "Player Name,Nation,Shirt Number,Pos\nFirst Last,ABC,seven,GK\n"
+	os.WriteFile(filepath.Join(TempDir, "players.csv"), []byte(csvData), os.ModePerm)
+
+	headers := map[string]string{"Player Name": "name", "Nation": "country"}
+	_, err = LoadPlayersFrom(Source{Path: filepath.Join(TempDir, "players.csv"), Headers: headers})
+
+	var validation *exceptions.ValidationErrors
+	assert.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Errors, 1)
+	assert.Equal(t, 2, validation.Errors[0].Line)
+
+	csvData = "Player Name,Nation,Shirt Number,Pos\nFirst Last,ABC,7,GK\n"
+	os.WriteFile(filepath.Join(TempDir, "players.csv"), []byte(csvData), os.ModePerm)
+
+	players, err := LoadPlayersFrom(Source{Path: filepath.Join(TempDir, "players.csv"), Headers: headers})
+	assert.NoError(t, err)
+	assert.EqualValues(t, []Player{{Name: "First Last", Country: "ABC", Number: 7, Position: "GK", Line: 2}}, players)
+
+	os.WriteFile(filepath.Join(TempDir, "broken.json"), []byte("[\n{\"name\": }]"), os.ModePerm)
+	_, err = LoadTeams(filepath.Join(TempDir, "broken.json"))
+	assert.ErrorAs(t, err, &validation)
+	assert.Equal(t, 2, validation.Errors[0].Line)
+}
+
+func TestParseEvent(t *testing.T) {
+	event := Event{Kind: models.GOAL, Country: "ARG", Player: "Lionel Messi", Minute: 90, Offset: 3, Penalty: true}
+	assert.Equal(t, "goal|ARG|Lionel Messi|90+3|pen", event.String())
+
+	parsed, err := ParseEvent(event.String())
+	assert.NoError(t, err)
+	assert.Equal(t, event, parsed)
+
+	parsed, err = ParseEvent(" yellow | FRA | Theo Hernandez | 12 ")
+	assert.NoError(t, err)
+	assert.Equal(t, Event{Kind: models.YELLOW, Country: "FRA", Player: "Theo Hernandez", Minute: 12}, parsed)
+
+	_, err = ParseEvent("goal|ARG|Lionel Messi")
+	assert.EqualError(t, err, "could not parse the event: `goal|ARG|Lionel Messi`")
+
+	_, err = ParseEvent("goal|ARG|Lionel Messi|23|header")
+	assert.Error(t, err)
+}
+
+func TestParseMinute(t *testing.T) {
+	minute, offset, err := ParseMinute(" 90 + 3 ")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{90, 3}, []int{minute, offset})
+
+	minute, offset, _ = ParseMinute("57")
+	assert.Equal(t, []int{57, 0}, []int{minute, offset})
+
+	_, _, err = ParseMinute("90+")
+	assert.EqualError(t, err, "could not parse the minute: `90+`")
 }
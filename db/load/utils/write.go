@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// row is implemented by each of the file types, giving the csv header and values in the same order
+type row interface {
+	columns() []string
+	values() []string
+}
+
+// matchRecord is the layout of a match in the files, with the date and time written separately
+type matchRecord struct {
+	A         string  `yaml:"a" json:"a"`
+	B         string  `yaml:"b" json:"b"`
+	Date      string  `yaml:"date" json:"date"`
+	Stage     string  `yaml:"stage" json:"stage"`
+	Time      string  `yaml:"time" json:"time"`
+	Score     string  `yaml:"score,omitempty" json:"score,omitempty"`
+	Penalties string  `yaml:"penalties,omitempty" json:"penalties,omitempty"`
+	Events    []Event `yaml:"events,omitempty" json:"events,omitempty"`
+}
+
+func (m Match) record() matchRecord {
+	record := matchRecord{
+		A:      m.A,
+		B:      m.B,
+		Date:   m.Date.UTC().Format(dateLayouts[0]),
+		Stage:  m.Stage.String(),
+		Time:   m.Date.UTC().Format("15:04"),
+		Events: m.Events,
+	}
+
+	if m.Score != nil {
+		record.Score = m.Score.String()
+	}
+
+	if m.Penalties != nil {
+		record.Penalties = m.Penalties.String()
+	}
+
+	return record
+}
+
+func (m Match) MarshalYAML() (any, error) {
+	return m.record(), nil
+}
+
+func (m Match) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.record())
+}
+
+func (t Team) columns() []string {
+	return []string{"name", "code", "group"}
+}
+
+func (t Team) values() []string {
+	return []string{t.Name, t.Code, t.Group}
+}
+
+func (p Player) columns() []string {
+	return []string{"name", "country", "number", "position"}
+}
+
+func (p Player) values() []string {
+	return []string{p.Name, p.Country, strconv.Itoa(p.Number), p.Position}
+}
+
+func (r Rating) columns() []string {
+	return []string{"country", "rating"}
+}
+
+func (r Rating) values() []string {
+	return []string{r.Country, strconv.FormatFloat(r.Rating, 'f', -1, 64)}
+}
+
+func (m Match) columns() []string {
+	return []string{"a", "b", "date", "stage", "time", "score", "penalties", "events"}
+}
+
+func (m Match) values() []string {
+	record := m.record()
+	events := make([]string, len(record.Events))
+
+	for index, event := range record.Events {
+		events[index] = event.String()
+	}
+
+	return []string{
+		record.A, record.B, record.Date, record.Stage, record.Time, record.Score, record.Penalties,
+		strings.Join(events, "; "),
+	}
+}
+
+func write[T row](w io.Writer, format Format, items []T) error {
+	// An empty list still needs to be written as a list, so that it can be loaded again
+	if items == nil {
+		items = []T{}
+	}
+
+	switch format {
+	case YAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(items); err != nil {
+			return err
+		}
+		return encoder.Close()
+
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+
+	case CSV:
+		var zero T
+		writer := csv.NewWriter(w)
+
+		if err := writer.Write(zero.columns()); err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			if err := writer.Write(item.values()); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	}
+
+	return fmt.Errorf("unsupported file format: `%s`", format)
+}
+
+// WriteTeams writes the teams in the same layout that LoadTeams reads
+func WriteTeams(w io.Writer, format Format, teams []Team) error {
+	return write(w, format, teams)
+}
+
+// WriteMatches writes the matches, with any results and events, in the same layout that LoadMatches reads
+func WriteMatches(w io.Writer, format Format, matches []Match) error {
+	return write(w, format, matches)
+}
+
+// WritePlayers writes the players in the same layout that LoadPlayers reads
+func WritePlayers(w io.Writer, format Format, players []Player) error {
+	return write(w, format, players)
+}
+
+// WriteRatings writes the ratings of the countries in the same layout that LoadRatings reads
+func WriteRatings(w io.Writer, format Format, ratings []Rating) error {
+	return write(w, format, ratings)
+}
@@ -0,0 +1,431 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a single numbered change to the schema. Each migration works on its own copy of the tables as they
+// were at that version, rather than the models, so that later changes to the models don't change what an older
+// migration does.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaVersion records each migration that has been applied to the database
+type SchemaVersion struct {
+	Version   int `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// MigrationStatus is a migration along with when it was applied, if it has been
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations lists every migration in the order they are applied. The first steps only create tables or columns
+// that are missing, so that databases created before the migrations existed can be brought up to date in place.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create the countries, players and matches tables",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &country1{}, &player1{}, &match1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&match1{}, &player1{}, &country1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add the results to the matches",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &match2{}, "AScore", "BScore", "APenalties", "BPenalties")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &match2{}, "AScore", "BScore", "APenalties", "BPenalties")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create the events table",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &event3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&event3{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create the api_keys table",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &apiKey4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&apiKey4{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add the ratings to the countries, and create the ratings table",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &country5{}, "Rating", "BaseRating"); err != nil {
+				return err
+			}
+			return createTables(tx, &rating5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&rating5{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &country5{}, "Rating", "BaseRating")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create the pools, users and predictions tables",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &pool6{}, &user6{}, &prediction6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&prediction6{}, &user6{}, &pool6{})
+		},
+	},
+}
+
+// LatestVersion is the schema version once every migration has been applied
+func LatestVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// CurrentVersion is the last migration applied to the database, or 0 for an empty database
+func CurrentVersion(database *gorm.DB) (int, error) {
+	var version SchemaVersion
+
+	if !database.Migrator().HasTable(&SchemaVersion{}) {
+		return 0, nil
+	}
+
+	err := database.Order("version DESC").Limit(1).Find(&version).Error
+	return version.Version, err
+}
+
+// CheckVersion fails when there are migrations that haven't been applied to the database yet, for the commands that
+// only read from it and so leave its schema alone
+func CheckVersion(database *gorm.DB) error {
+	current, err := CurrentVersion(database)
+	if err != nil {
+		return err
+	}
+
+	if current < LatestVersion() {
+		return fmt.Errorf("the database schema is at version %d, but version %d is needed: run `wc db migrate up`", current, LatestVersion())
+	}
+	return nil
+}
+
+// MigrateUp applies each migration after the current version, up to and including the target. A target of 0 applies
+// all of them. Every migration runs in its own transaction, and the ones that were applied are returned.
+func MigrateUp(database *gorm.DB, target int) ([]Migration, error) {
+	var applied []Migration
+
+	if target == 0 {
+		target = LatestVersion()
+	}
+
+	if err := database.Migrator().AutoMigrate(&SchemaVersion{}); err != nil {
+		return nil, fmt.Errorf("could not create the schema_version table: %w", err)
+	}
+
+	current, err := CurrentVersion(database)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range Migrations {
+		if migration.Version <= current || migration.Version > target {
+			continue
+		}
+
+		err := database.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaVersion{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+
+		if err != nil {
+			return applied, fmt.Errorf("could not apply migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the given number of the most recent migrations, or all of them when steps is negative. The
+// migrations that were reverted are returned, newest first.
+func MigrateDown(database *gorm.DB, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	current, err := CurrentVersion(database)
+	if err != nil {
+		return nil, err
+	}
+
+	for index := len(Migrations) - 1; index >= 0 && steps != 0; index-- {
+		migration := Migrations[index]
+		if migration.Version > current {
+			continue
+		}
+
+		err := database.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaVersion{}, migration.Version).Error
+		})
+
+		if err != nil {
+			return reverted, fmt.Errorf("could not revert migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		reverted = append(reverted, migration)
+		steps--
+	}
+
+	return reverted, nil
+}
+
+// Status lists every migration, and whether it has been applied to the database
+func Status(database *gorm.DB) ([]MigrationStatus, error) {
+	var versions []SchemaVersion
+
+	if database.Migrator().HasTable(&SchemaVersion{}) {
+		if err := database.Find(&versions).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	applied := make(map[int]SchemaVersion)
+	for _, version := range versions {
+		applied[version.Version] = version
+	}
+
+	status := make([]MigrationStatus, len(Migrations))
+	for index, migration := range Migrations {
+		version, found := applied[migration.Version]
+		status[index] = MigrationStatus{Migration: migration, Applied: found, AppliedAt: version.AppliedAt}
+	}
+
+	return status, nil
+}
+
+func createTables(tx *gorm.DB, tables ...any) error {
+	for _, table := range tables {
+		if tx.Migrator().HasTable(table) {
+			continue
+		}
+
+		if err := tx.Migrator().CreateTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addColumns(tx *gorm.DB, table any, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(table, field) {
+			continue
+		}
+
+		if err := tx.Migrator().AddColumn(table, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropColumns(tx *gorm.DB, table any, fields ...string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasColumn(table, field) {
+			continue
+		}
+
+		if err := tx.Migrator().DropColumn(table, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The tables as they were first created
+type country1 struct {
+	gorm.Model
+
+	ID       int    `gorm:"primarykey"`
+	Name     string `gorm:"unique"`
+	Group    string
+	FifaCode string `gorm:"unique"`
+}
+
+func (country1) TableName() string { return "countries" }
+
+type player1 struct {
+	gorm.Model
+
+	ID        int `gorm:"primarykey"`
+	CountryID int
+	Country   country1
+
+	Name     string
+	Position string
+	Number   int `gorm:"default:-1"`
+
+	Goals  uint `gorm:"default:0"`
+	Yellow uint `gorm:"default:0"`
+	Red    uint `gorm:"default:0"`
+	Saves  int  `gorm:"default:-1"`
+}
+
+func (player1) TableName() string { return "players" }
+
+type match1 struct {
+	gorm.Model
+
+	ID     int  `gorm:"primarykey"`
+	Day    int  `gorm:"default:0"`
+	Played bool `gorm:"default:false"`
+
+	AID      int
+	BID      int
+	ACountry country1 `gorm:"foreignKey:AID"`
+	BCountry country1 `gorm:"foreignKey:BID"`
+
+	Stage uint
+
+	When     time.Time
+	Assigned bool `gorm:"default:false"`
+}
+
+func (match1) TableName() string { return "matches" }
+
+// The result columns added to the matches
+type match2 struct {
+	AScore     int `gorm:"default:0"`
+	BScore     int `gorm:"default:0"`
+	APenalties int `gorm:"default:0"`
+	BPenalties int `gorm:"default:0"`
+}
+
+func (match2) TableName() string { return "matches" }
+
+type event3 struct {
+	gorm.Model
+
+	ID        int `gorm:"primarykey"`
+	MatchID   int `gorm:"index"`
+	CountryID int
+	Country   country1
+	Kind      string
+
+	Player  string
+	Minute  int
+	Offset  int  `gorm:"default:0"`
+	Penalty bool `gorm:"default:false"`
+	OwnGoal bool `gorm:"default:false"`
+}
+
+func (event3) TableName() string { return "events" }
+
+type apiKey4 struct {
+	gorm.Model
+
+	ID     int `gorm:"primarykey"`
+	Name   string
+	Prefix string `gorm:"size:16;index"`
+	Hash   string `gorm:"size:64;uniqueIndex"`
+	Role   string `gorm:"size:16"`
+
+	LastUsed  *time.Time
+	RevokedAt *time.Time
+}
+
+func (apiKey4) TableName() string { return "api_keys" }
+
+// The rating columns added to the countries
+type country5 struct {
+	Rating     float64 `gorm:"default:1500"`
+	BaseRating float64 `gorm:"default:1500"`
+}
+
+func (country5) TableName() string { return "countries" }
+
+type rating5 struct {
+	gorm.Model
+
+	ID        int `gorm:"primarykey"`
+	CountryID int `gorm:"index"`
+	Country   country1
+	MatchID   int
+
+	When   time.Time
+	Rating float64
+	Change float64
+}
+
+func (rating5) TableName() string { return "ratings" }
+
+type pool6 struct {
+	gorm.Model
+
+	ID         int    `gorm:"primarykey"`
+	Name       string `gorm:"unique"`
+	Exact      int
+	Difference int
+	Result     int
+	Winner     int
+}
+
+func (pool6) TableName() string { return "pools" }
+
+type user6 struct {
+	gorm.Model
+
+	ID       int    `gorm:"primarykey"`
+	PoolID   int    `gorm:"uniqueIndex:idx_users_pool_name"`
+	Name     string `gorm:"uniqueIndex:idx_users_pool_name"`
+	ApiKeyID int    `gorm:"index"`
+}
+
+func (user6) TableName() string { return "users" }
+
+type prediction6 struct {
+	gorm.Model
+
+	ID       int `gorm:"primarykey"`
+	UserID   int `gorm:"uniqueIndex:idx_predictions_user_match"`
+	MatchID  int `gorm:"uniqueIndex:idx_predictions_user_match"`
+	AScore   int
+	BScore   int
+	WinnerID *int
+}
+
+func (prediction6) TableName() string { return "predictions" }
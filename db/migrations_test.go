@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/cazier/wc/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := OpenSqlite(&SqliteDBOptions{Memory: true, LogLevel: 1})
+	assert.NoError(err)
+	database := store.DB
+
+	version, err := CurrentVersion(database)
+	assert.NoError(err)
+	assert.Zero(version)
+
+	applied, err := MigrateUp(database, 2)
+	assert.NoError(err)
+	assert.Len(applied, 2)
+	assert.True(database.Migrator().HasColumn(&models.Match{}, "AScore"))
+	assert.False(database.Migrator().HasTable(&models.Event{}))
+	assert.ErrorContains(CheckVersion(database), "run `wc db migrate up`")
+
+	assert.NoError(store.LinkTables(false))
+	version, _ = CurrentVersion(database)
+	assert.Equal(LatestVersion(), version)
+	assert.NoError(CheckVersion(database))
+
+	database.Create(&models.Country{Name: "Country A", FifaCode: "C_A"})
+
+	reverted, err := MigrateDown(database, LatestVersion()-1)
+	assert.NoError(err)
+	assert.Len(reverted, LatestVersion()-1)
+	assert.Equal(2, reverted[len(reverted)-1].Version)
+	assert.False(database.Migrator().HasColumn(&models.Match{}, "AScore"))
+
+	status, err := Status(database)
+	assert.NoError(err)
+	assert.True(status[0].Applied)
+	assert.False(status[1].Applied)
+
+	// Reverting and applying the later migrations keeps the data in the earlier tables
+	var count int64
+	assert.NoError(store.LinkTables(false))
+	database.Model(&models.Country{}).Count(&count)
+	assert.EqualValues(1, count)
+
+	assert.NoError(store.LinkTables(true))
+	database.Model(&models.Country{}).Count(&count)
+	assert.Zero(count)
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -11,6 +14,11 @@ type Country struct {
 	Name     string `gorm:"unique" json:"name" uri:"name"`
 	Group    string `json:"group" uri:"group"`
 	FifaCode string `gorm:"unique" json:"fifa_code" uri:"code"`
+
+	// The Elo rating of the country, from its rating before the tournament (which can be imported) and every result
+	// since then
+	Rating     float64 `gorm:"default:1500" json:"rating"`
+	BaseRating float64 `gorm:"default:1500" json:"-"`
 }
 
 type Player struct {
@@ -29,3 +37,19 @@ type Player struct {
 	Red    uint `gorm:"default:0" json:"reds"`
 	Saves  int  `gorm:"default:-1" json:"saves"`
 }
+
+// Modified is the last time the country changed
+func (c Country) Modified() time.Time {
+	return c.UpdatedAt
+}
+
+// Is checks if the text is the name or FIFA code of the country, ignoring the case
+func (c Country) Is(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.EqualFold(c.Name, text) || strings.EqualFold(c.FifaCode, text)
+}
+
+// Modified is the last time the player, or their country, changed
+func (p Player) Modified() time.Time {
+	return Latest(p.UpdatedAt, p.Country.UpdatedAt)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApiKey gives a client access to the api with a role. Only a hash of the key is stored, along with the first few
+// characters of it so that the keys can be told apart when they are listed.
+type ApiKey struct {
+	gorm.Model `json:"-"`
+
+	ID     int    `gorm:"primarykey" json:"id"`
+	Name   string `json:"name"`
+	Prefix string `gorm:"size:16;index" json:"prefix"`
+	Hash   string `gorm:"size:64;uniqueIndex" json:"-"`
+	Role   Role   `gorm:"size:16" json:"role"`
+
+	LastUsed  *time.Time `json:"last_used"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type Role string
+
+const (
+	READER Role = "reader"
+	SCORER Role = "scorer"
+	ADMIN  Role = "admin"
+)
+
+var ranks = map[Role]int{READER: 1, SCORER: 2, ADMIN: 3}
+
+func ParseRole(s string) (Role, error) {
+	if _, found := ranks[Role(s)]; found {
+		return Role(s), nil
+	}
+	return "", fmt.Errorf("unknown role: `%s` (expected reader, scorer or admin)", s)
+}
+
+// Allows checks if the role has at least the access of the required role, so an admin can do everything a scorer can
+func (r Role) Allows(required Role) bool {
+	return ranks[r] > 0 && ranks[r] >= ranks[required]
+}
@@ -1,15 +1,19 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// LiveWindow is how long after the kickoff a match that hasn't been marked as finished is still treated as being played
+const LiveWindow = 3 * time.Hour
+
 type Match struct {
 	gorm.Model `json:"-"`
 
-	ID     int  `gorm:"primarykey" json:"id"`
+	ID     int  `gorm:"primarykey" json:"id" uri:"id"`
 	Day    int  `gorm:"default:0"  json:"match_day" uri:"day"`
 	Played bool `gorm:"default:false" json:"played"`
 
@@ -18,15 +22,26 @@ type Match struct {
 	ACountry Country `gorm:"foreignKey:AID" json:"country_a"`
 	BCountry Country `gorm:"foreignKey:BID" json:"country_b"`
 
-	Stage Stage `json:"-"`
+	Stage Stage `json:"stage"`
 
 	When     time.Time `json:"when"`
 	Assigned bool      `gorm:"default:false" json:"-"`
 
+	AScore     int     `gorm:"default:0" json:"score_a"`
+	BScore     int     `gorm:"default:0" json:"score_b"`
+	APenalties int     `gorm:"default:0" json:"penalties_a"`
+	BPenalties int     `gorm:"default:0" json:"penalties_b"`
+	Events     []Event `json:"events,omitempty"`
+
 	// AResult MatchResult `gorm:"foreignKey:ID"`
 	// BResult MatchResult `gorm:"foreignKey:ID"`
 }
 
+// Modified is the last time the match, or either of its countries, changed
+func (m Match) Modified() time.Time {
+	return Latest(m.UpdatedAt, m.ACountry.UpdatedAt, m.BCountry.UpdatedAt)
+}
+
 type MatchResult struct {
 	gorm.Model `json:"-"`
 
@@ -40,6 +55,32 @@ type MatchResult struct {
 	Points       uint
 }
 
+// Event is something notable that happened during a match. Goals are recorded against the country they count for,
+// so an own goal has the CountryID of the opposing team to the player that scored it.
+type Event struct {
+	gorm.Model `json:"-"`
+
+	ID        int       `gorm:"primarykey" json:"id"`
+	MatchID   int       `json:"match_id"`
+	CountryID int       `json:"-"`
+	Country   Country   `json:"country"`
+	Kind      EventKind `json:"kind"`
+
+	Player  string `json:"player"`
+	Minute  int    `json:"minute"`
+	Offset  int    `gorm:"default:0" json:"offset"`
+	Penalty bool   `gorm:"default:false" json:"penalty"`
+	OwnGoal bool   `gorm:"default:false" json:"own_goal"`
+}
+
+type EventKind string
+
+const (
+	GOAL   EventKind = "goal"
+	YELLOW EventKind = "yellow"
+	RED    EventKind = "red"
+)
+
 type Stage uint
 
 const (
@@ -50,3 +91,46 @@ const (
 	THIRD_PLACE
 	FINAL
 )
+
+func (s Stage) String() string {
+	switch s {
+	case GROUP:
+		return "GROUP"
+	case ROUND_OF_SIXTEEN:
+		return "ROUND_OF_SIXTEEN"
+	case QUARTERFINALS:
+		return "QUARTERFINALS"
+	case SEMIFINALS:
+		return "SEMIFINALS"
+	case THIRD_PLACE:
+		return "THIRD_PLACE"
+	case FINAL:
+		return "FINAL"
+	}
+	return "UNKNOWN"
+}
+
+// ParseStage reads a stage from its name, like "ROUND_OF_SIXTEEN"
+func ParseStage(s string) (Stage, error) {
+	for stage := GROUP; stage <= FINAL; stage++ {
+		if s == stage.String() {
+			return stage, nil
+		}
+	}
+	return 0, fmt.Errorf("could not parse stage value: `%s`", s)
+}
+
+// MarshalText writes the stage as its name, so the api sends the stage by name rather than its number
+func (s Stage) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Stage) UnmarshalText(text []byte) error {
+	stage, err := ParseStage(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = stage
+	return nil
+}
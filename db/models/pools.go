@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pool is a prediction game, where each of its users guess the scores of the matches (and who wins the knockout
+// matches) before they kick off, and are given points for them by the rules of the pool
+type Pool struct {
+	gorm.Model `json:"-"`
+
+	ID    int    `gorm:"primarykey" json:"id" uri:"id"`
+	Name  string `gorm:"unique" json:"name"`
+	Rules Rules  `gorm:"embedded" json:"rules"`
+	Users []User `json:"users"`
+}
+
+// Rules are how many points each kind of right guess is worth. Only the best of the exact score, the goal difference
+// and the result counts, and the winner of a knockout match is worth its points on top of that.
+type Rules struct {
+	Exact      int `json:"exact"`
+	Difference int `json:"difference"`
+	Result     int `json:"result"`
+	Winner     int `json:"winner"`
+}
+
+// The rules a pool has when it's created without any
+var DEFAULT_RULES = Rules{Exact: 5, Difference: 3, Result: 2, Winner: 2}
+
+// User is someone playing in a pool. Each user has their own reader api key, which their predictions are sent with.
+type User struct {
+	gorm.Model `json:"-"`
+
+	ID       int    `gorm:"primarykey" json:"id"`
+	PoolID   int    `gorm:"uniqueIndex:idx_users_pool_name" json:"-"`
+	Name     string `gorm:"uniqueIndex:idx_users_pool_name" json:"name"`
+	ApiKeyID int    `gorm:"index" json:"-"`
+}
+
+// Prediction is a user's guess at the score of a match. The score is for the countries of the match in the same
+// order, even when they aren't known yet. For a knockout match, the winner is the country the user thinks goes
+// through, which also covers a match that is level after extra time.
+type Prediction struct {
+	gorm.Model `json:"-"`
+
+	ID       int      `gorm:"primarykey" json:"-"`
+	UserID   int      `gorm:"uniqueIndex:idx_predictions_user_match" json:"-"`
+	User     User     `json:"user"`
+	MatchID  int      `gorm:"uniqueIndex:idx_predictions_user_match" json:"match_id"`
+	AScore   int      `json:"score_a"`
+	BScore   int      `json:"score_b"`
+	WinnerID *int     `json:"-"`
+	Winner   *Country `gorm:"foreignKey:WinnerID" json:"winner,omitempty"`
+}
+
+// Modified is the last time the pool, or any of its users, changed
+func (p Pool) Modified() time.Time {
+	last := p.UpdatedAt
+	for _, user := range p.Users {
+		last = Latest(last, user.UpdatedAt)
+	}
+	return last
+}
+
+// Modified is the last time the prediction changed
+func (p Prediction) Modified() time.Time {
+	return Latest(p.UpdatedAt, p.User.UpdatedAt)
+}
+
+// Entry is a user's place on the leaderboard of a pool, from the matches that have finished. The users with the same
+// points (and exact scores) share a rank.
+type Entry struct {
+	Rank int  `json:"rank"`
+	User User `json:"user"`
+
+	Points  int `json:"points"`
+	Exact   int `json:"exact"`
+	Winners int `json:"winners"`
+	// How many of the finished matches the user predicted
+	Predicted int `json:"predicted"`
+
+	UpdatedAt time.Time `json:"-"`
+}
+
+// Modified is the last time a prediction, or a result, in the entry changed
+func (e Entry) Modified() time.Time {
+	return e.UpdatedAt
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The rating each country starts with, unless another one is imported for it
+const INITIAL_RATING = 1500
+
+// Rating is the change to a country's rating from the result of a single match
+type Rating struct {
+	gorm.Model `json:"-"`
+
+	ID        int     `gorm:"primarykey" json:"-"`
+	CountryID int     `gorm:"index" json:"-"`
+	Country   Country `json:"country"`
+	MatchID   int     `json:"match_id"`
+
+	When   time.Time `json:"when"`
+	Rating float64   `json:"rating"`
+	Change float64   `json:"change"`
+}
+
+// Modified is the last time the rating, or its country, changed
+func (r Rating) Modified() time.Time {
+	return Latest(r.UpdatedAt, r.Country.UpdatedAt)
+}
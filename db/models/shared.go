@@ -2,8 +2,20 @@ package models
 
 import (
 	"reflect"
+	"time"
 )
 
+// Latest is the last of the times, or the zero time when there aren't any
+func Latest(times ...time.Time) time.Time {
+	var last time.Time
+	for _, t := range times {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
 // I'm not really sure this works reilably
 
 func IsNil(m any) bool {
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// The points a country gets for each result in the group stage
+const (
+	WIN_POINTS  = 3
+	DRAW_POINTS = 1
+)
+
+// Standing is the record of a country in its group, from the group matches that have finished
+type Standing struct {
+	Country Country `json:"country"`
+
+	Played int `json:"played"`
+	Won    int `json:"won"`
+	Drawn  int `json:"drawn"`
+	Lost   int `json:"lost"`
+
+	GoalsFor       int `json:"goals_for"`
+	GoalsAgainst   int `json:"goals_against"`
+	GoalDifference int `json:"goal_difference"`
+	Points         int `json:"points"`
+
+	UpdatedAt time.Time `json:"-"`
+}
+
+// GroupStandings is the table of a single group, with the countries in the order they are ranked
+type GroupStandings struct {
+	Group     string     `json:"group"`
+	Standings []Standing `json:"standings"`
+}
+
+// Modified is the last time any of the countries in the group, or the matches between them, changed
+func (g GroupStandings) Modified() time.Time {
+	var last time.Time
+	for _, standing := range g.Standings {
+		last = Latest(last, standing.UpdatedAt)
+	}
+	return last
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMariaDBDSN(t *testing.T) {
+	assert := assert.New(t)
+
+	options := MariaDBOptions{Username: "wc@host", Password: "p@ss:w/rd?1", Host: "db.local"}
+	assert.NoError(options.validate())
+
+	config, err := mysql.ParseDSN(options.dsn())
+	assert.NoError(err)
+	assert.Equal("wc@host", config.User)
+	assert.Equal("p@ss:w/rd?1", config.Passwd)
+	assert.Equal("db.local:3306", config.Addr)
+	assert.Equal("wc", config.DBName)
+	assert.True(config.ParseTime)
+	assert.Equal("utf8", config.Params["charset"])
+
+	options = MariaDBOptions{DSN: "user:pass@tcp(host:3306)/wc"}
+	assert.NoError(options.validate())
+	assert.Equal("user:pass@tcp(host:3306)/wc", options.dsn())
+
+	options = MariaDBOptions{Username: "wc", Other: "charset=%zz"}
+	assert.Error(options.validate())
+}
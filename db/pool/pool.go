@@ -0,0 +1,514 @@
+// Package pool runs the prediction pools, where the users of each pool guess the scores of the matches (and who goes
+// through the knockout matches) before they kick off, and are ranked by the points the pool's rules give them.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/auth"
+	"github.com/cazier/wc/cache"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pick is a user's guess at a single match
+type Pick struct {
+	MatchID int
+	Score   utils.Score
+	// The name or FIFA code of the country that goes through a knockout match. It can be left out when the score
+	// already says, once the countries in the match are known.
+	Winner string
+}
+
+// Create adds a new pool, with the rules its points are given by
+func Create(database *gorm.DB, name string, rules models.Rules) (models.Pool, error) {
+	pool := models.Pool{Name: strings.TrimSpace(name), Rules: rules}
+
+	if err := validateRules(pool); err != nil {
+		return models.Pool{}, err
+	}
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Pool{}).Scopes(named(pool.Name)).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return invalid("there is already a pool named `%s`", pool.Name)
+		}
+
+		return tx.Create(&pool).Error
+	})
+
+	if err != nil {
+		return models.Pool{}, err
+	}
+
+	committed(database)
+	return pool, nil
+}
+
+// SetRules changes the rules of a pool, which changes the points for every match that has already been played too
+func SetRules(database *gorm.DB, pool models.Pool, rules models.Rules) (models.Pool, error) {
+	pool.Rules = rules
+
+	if err := validateRules(pool); err != nil {
+		return models.Pool{}, err
+	}
+
+	update := map[string]any{
+		"exact":      rules.Exact,
+		"difference": rules.Difference,
+		"result":     rules.Result,
+		"winner":     rules.Winner,
+	}
+
+	if err := database.Model(&pool).Updates(update).Error; err != nil {
+		return models.Pool{}, err
+	}
+
+	committed(database)
+	return pool, nil
+}
+
+// Find looks up a pool, along with its users, by its id or its name (ignoring the case)
+func Find(ctx context.Context, database *gorm.DB, text string) (models.Pool, error) {
+	var pool models.Pool
+
+	tx := database.WithContext(ctx).Preload("Users", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") })
+	if id, err := strconv.Atoi(text); err == nil {
+		tx = tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}, Value: id})
+	} else {
+		tx = tx.Scopes(named(text))
+	}
+
+	result := tx.Limit(1).Find(&pool)
+	if result.Error != nil {
+		return pool, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return pool, &exceptions.NoResultsFoundError{}
+	}
+
+	return pool, nil
+}
+
+// List returns every pool, along with its users
+func List(ctx context.Context, database *gorm.DB) ([]models.Pool, error) {
+	var pools []models.Pool
+
+	err := database.WithContext(ctx).Preload("Users", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Order("id").Find(&pools).Error
+	return pools, err
+}
+
+// Join adds a user to the pool, along with a reader api key for them to send their predictions with. The key itself
+// is only returned here, and can't be found again later.
+func Join(database *gorm.DB, pool models.Pool, name string) (models.User, string, error) {
+	var user models.User
+	var key string
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return user, "", invalid("the user needs a name")
+	}
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if _, err := FindUser(tx, pool, name); err == nil {
+			return invalid("there is already a user named `%s` in the pool", name)
+		} else if !errors.Is(err, errMissingUser) {
+			return err
+		}
+
+		row, secret, err := auth.Create(tx, fmt.Sprintf("pool %s: %s", pool.Name, name), models.READER)
+		if err != nil {
+			return err
+		}
+
+		user = models.User{PoolID: pool.ID, Name: name, ApiKeyID: row.ID}
+		key = secret
+
+		return tx.Create(&user).Error
+	})
+
+	if err != nil {
+		return models.User{}, "", err
+	}
+
+	committed(database)
+	return user, key, nil
+}
+
+var errMissingUser = &exceptions.NoResultsFoundError{}
+
+// FindUser looks up a user of the pool by their name, ignoring the case
+func FindUser(database *gorm.DB, pool models.Pool, name string) (models.User, error) {
+	var user models.User
+
+	result := database.Where(&models.User{PoolID: pool.ID}).Scopes(named(name)).Limit(1).Find(&user)
+
+	if result.Error != nil {
+		return user, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return user, errMissingUser
+	}
+
+	return user, nil
+}
+
+// KeyUser finds the user of the pool that the api key belongs to
+func KeyUser(database *gorm.DB, pool models.Pool, key models.ApiKey) (models.User, error) {
+	var user models.User
+
+	result := database.Where(&models.User{PoolID: pool.ID, ApiKeyID: key.ID}).Limit(1).Find(&user)
+	if result.Error != nil {
+		return user, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return user, errMissingUser
+	}
+
+	return user, nil
+}
+
+// Predict saves the user's pick for a match, replacing any pick they made for it before. The predictions for a match
+// close when it kicks off.
+func Predict(database *gorm.DB, user models.User, pick Pick) (models.Prediction, error) {
+	var prediction models.Prediction
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		prediction, err = predict(tx, user, pick, time.Now(), false)
+		return err
+	})
+
+	if err != nil {
+		return models.Prediction{}, err
+	}
+
+	committed(database)
+	return find(database, prediction.ID)
+}
+
+// Import saves the predictions from a file, adding any users of the pool that don't exist yet (without an api key).
+// Either all of them are saved, or none. With late, the predictions for the matches that have already kicked off are
+// saved too, for moving a pool over from somewhere else.
+func Import(database *gorm.DB, pool models.Pool, predictions []utils.Prediction, late bool) (int, error) {
+	now := time.Now()
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		users := make(map[string]models.User)
+
+		for _, prediction := range predictions {
+			key := strings.ToLower(strings.TrimSpace(prediction.User))
+
+			user, found := users[key]
+			if !found {
+				var err error
+				if user, err = FindUser(tx, pool, prediction.User); errors.Is(err, errMissingUser) {
+					user = models.User{PoolID: pool.ID, Name: strings.TrimSpace(prediction.User)}
+					err = tx.Create(&user).Error
+				}
+
+				if err != nil {
+					return err
+				}
+				users[key] = user
+			}
+
+			pick := Pick{MatchID: prediction.Match, Score: prediction.Score, Winner: prediction.Winner}
+			if _, err := predict(tx, user, pick, now, late); err != nil {
+				return fmt.Errorf("line %d: %w", prediction.Line, err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	committed(database)
+	return len(predictions), nil
+}
+
+// Predictions lists the picks of every user in the pool for the matches that have kicked off, in the order the
+// matches kick off. The picks for the other matches are kept hidden until then.
+func Predictions(ctx context.Context, database *gorm.DB, pool models.Pool) ([]models.Prediction, error) {
+	var predictions []models.Prediction
+
+	kickoff := clause.Column{Table: "matches", Name: "when"}
+	users := database.Model(&models.User{}).Select("id").Where(&models.User{PoolID: pool.ID})
+
+	err := database.WithContext(ctx).Preload("User").Preload("Winner").
+		Joins("JOIN matches ON matches.id = predictions.match_id").
+		Where("predictions.user_id IN (?)", users).
+		Where("? <= ?", kickoff, time.Now()).
+		Order(clause.OrderByColumn{Column: kickoff}).Order("predictions.match_id").Order("predictions.user_id").
+		Find(&predictions).Error
+
+	return predictions, err
+}
+
+// Leaderboard ranks the users of the pool by their points from the matches that have finished
+func Leaderboard(ctx context.Context, database *gorm.DB, pool models.Pool) ([]models.Entry, error) {
+	var predictions []models.Prediction
+	var matches []models.Match
+
+	ids := make([]int, len(pool.Users))
+	for index, user := range pool.Users {
+		ids[index] = user.ID
+	}
+
+	if err := database.WithContext(ctx).Where("user_id IN ?", ids).Find(&predictions).Error; err != nil {
+		return nil, err
+	}
+
+	if err := database.WithContext(ctx).Where("played = ?", true).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	return Rank(pool, predictions, matches), nil
+}
+
+// Rank works out the leaderboard of the pool from the predictions of its users and the results of the matches. The
+// users are ranked by their points, then how many exact scores they got, and then their name.
+func Rank(pool models.Pool, predictions []models.Prediction, matches []models.Match) []models.Entry {
+	played := make(map[int]models.Match)
+	for _, match := range matches {
+		if match.Played {
+			played[match.ID] = match
+		}
+	}
+
+	rows := make(map[int]*models.Entry)
+	entries := make([]*models.Entry, len(pool.Users))
+	for index, user := range pool.Users {
+		entries[index] = &models.Entry{User: user, UpdatedAt: models.Latest(pool.UpdatedAt, user.UpdatedAt)}
+		rows[user.ID] = entries[index]
+	}
+
+	for _, prediction := range predictions {
+		row, match := rows[prediction.UserID], played[prediction.MatchID]
+		if row == nil || match.ID == 0 {
+			continue
+		}
+
+		points, exact, winner := Points(pool.Rules, prediction, match)
+
+		row.Points += points
+		row.Predicted++
+		if exact {
+			row.Exact++
+		}
+		if winner {
+			row.Winners++
+		}
+
+		row.UpdatedAt = models.Latest(row.UpdatedAt, prediction.UpdatedAt, match.UpdatedAt)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Exact != b.Exact {
+			return a.Exact > b.Exact
+		}
+		return strings.ToLower(a.User.Name) < strings.ToLower(b.User.Name)
+	})
+
+	output := make([]models.Entry, len(entries))
+	for index, entry := range entries {
+		entry.Rank = index + 1
+		if index > 0 && entry.Points == entries[index-1].Points && entry.Exact == entries[index-1].Exact {
+			entry.Rank = output[index-1].Rank
+		}
+		output[index] = *entry
+	}
+
+	return output
+}
+
+// Points scores a prediction for a match that has been played, also saying if the score was exact and if the winner
+// of a knockout match was right
+func Points(rules models.Rules, prediction models.Prediction, match models.Match) (points int, exact bool, winner bool) {
+	predicted, actual := prediction.AScore-prediction.BScore, match.AScore-match.BScore
+
+	switch {
+	case prediction.AScore == match.AScore && prediction.BScore == match.BScore:
+		points, exact = rules.Exact, true
+	case predicted == actual:
+		points = rules.Difference
+	case sign(predicted) == sign(actual):
+		points = rules.Result
+	}
+
+	if match.Stage != models.GROUP && prediction.WinnerID != nil && *prediction.WinnerID == through(match) {
+		points, winner = points+rules.Winner, true
+	}
+
+	return points, exact, winner
+}
+
+// predict checks the pick against the match, and saves it
+func predict(tx *gorm.DB, user models.User, pick Pick, now time.Time, late bool) (models.Prediction, error) {
+	var match models.Match
+
+	result := tx.Joins("ACountry").Joins("BCountry").Limit(1).Find(&match, pick.MatchID)
+	if result.Error != nil {
+		return models.Prediction{}, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return models.Prediction{}, invalid("there is no match with the id %d", pick.MatchID)
+	}
+
+	if !late && (match.Played || !now.Before(match.When)) {
+		return models.Prediction{}, invalid("the predictions for match %d closed when it kicked off, at %s", match.ID, match.When.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+
+	if pick.Score.A < 0 || pick.Score.B < 0 {
+		return models.Prediction{}, invalid("the score can't be negative: `%s`", pick.Score)
+	}
+
+	winner, err := pickWinner(tx, match, pick)
+	if err != nil {
+		return models.Prediction{}, err
+	}
+
+	var prediction models.Prediction
+	if err = tx.Where(&models.Prediction{UserID: user.ID, MatchID: match.ID}).Limit(1).Find(&prediction).Error; err != nil {
+		return models.Prediction{}, err
+	}
+
+	if prediction.ID == 0 {
+		prediction = models.Prediction{UserID: user.ID, MatchID: match.ID, AScore: pick.Score.A, BScore: pick.Score.B, WinnerID: winner}
+		return prediction, tx.Create(&prediction).Error
+	}
+
+	update := map[string]any{"a_score": pick.Score.A, "b_score": pick.Score.B, "winner_id": winner}
+	return prediction, tx.Model(&prediction).Updates(update).Error
+}
+
+// pickWinner finds the country the user picked to go through a knockout match. Until the countries in the match are
+// known, any country with a group can be picked, for filling in the bracket ahead of time.
+func pickWinner(tx *gorm.DB, match models.Match, pick Pick) (*int, error) {
+	text := strings.TrimSpace(pick.Winner)
+	known := match.ACountry.Group != "" && match.BCountry.Group != ""
+
+	switch {
+	case match.Stage == models.GROUP && text != "":
+		return nil, invalid("only a knockout match can have a winner")
+	case match.Stage == models.GROUP:
+		return nil, nil
+	case !known && text == "":
+		return nil, nil
+	}
+
+	if !known {
+		var country models.Country
+
+		result := tx.Where("? <> ''", clause.Column{Name: "group"}).
+			Where(tx.Where("LOWER(?) = LOWER(?)", clause.Column{Name: "name"}, text).
+				Or("LOWER(?) = LOWER(?)", clause.Column{Name: "fifa_code"}, text)).
+			Limit(1).Find(&country)
+
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil, invalid("the country `%s` could not be found", text)
+		}
+
+		return &country.ID, nil
+	}
+
+	var winner int
+	switch {
+	case text == "" && pick.Score.A == pick.Score.B:
+		return nil, invalid("a knockout match that is level needs a winner")
+	case text == "" && pick.Score.A > pick.Score.B, match.ACountry.Is(text):
+		winner = match.AID
+	case text == "", match.BCountry.Is(text):
+		winner = match.BID
+	default:
+		return nil, invalid("`%s` is not one of the teams in the match", text)
+	}
+
+	if pick.Score.A != pick.Score.B && (winner == match.AID) != (pick.Score.A > pick.Score.B) {
+		return nil, invalid("the winner has to be the country ahead in the score")
+	}
+
+	return &winner, nil
+}
+
+// find reads a prediction back, along with its user and winner
+func find(database *gorm.DB, id int) (models.Prediction, error) {
+	var prediction models.Prediction
+	err := database.Preload("User").Preload("Winner").First(&prediction, id).Error
+	return prediction, err
+}
+
+// through is the country that went through a knockout match, from the score or the penalties
+func through(match models.Match) int {
+	if match.AScore > match.BScore || (match.AScore == match.BScore && match.APenalties > match.BPenalties) {
+		return match.AID
+	}
+	return match.BID
+}
+
+func validateRules(pool models.Pool) error {
+	rules := pool.Rules
+
+	switch {
+	case pool.Name == "":
+		return invalid("the pool needs a name")
+	case rules.Exact < 0 || rules.Difference < 0 || rules.Result < 0 || rules.Winner < 0:
+		return invalid("the points for the rules can't be negative")
+	}
+	return nil
+}
+
+// named matches the rows with the name, ignoring the case
+func named(name string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("LOWER(?) = LOWER(?)", clause.Column{Name: "name"}, strings.TrimSpace(name))
+	}
+}
+
+// committed invalidates the pool tables in the read caches once a change has been committed
+func committed(database *gorm.DB) {
+	cache.Invalidate(database, "pools", "users", "predictions")
+}
+
+func invalid(format string, args ...any) error {
+	return &exceptions.RequestError{Message: fmt.Sprintf(format, args...)}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
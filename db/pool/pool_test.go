@@ -0,0 +1,197 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/dbtest"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/seed"
+	"github.com/stretchr/testify/assert"
+)
+
+// newStore makes a database with a tournament from the seed package that starts tomorrow, so nothing has kicked off
+func newStore(t *testing.T) *db.Store {
+	return dbtest.Seeded(t, seed.Options{Seed: 2, Start: time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)})
+}
+
+// play moves the match into the past and records its result
+func play(store *db.Store, match models.Match, a, b int) {
+	store.DB.Model(&match).Updates(map[string]any{
+		"when":    time.Now().Add(-2 * time.Hour),
+		"a_score": a,
+		"b_score": b,
+		"played":  true,
+	})
+}
+
+func TestPoints(t *testing.T) {
+	assert := assert.New(t)
+	rules := models.DEFAULT_RULES
+
+	group := models.Match{AID: 1, BID: 2, AScore: 2, BScore: 1, Played: true}
+	for _, test := range []struct {
+		a, b   int
+		points int
+	}{
+		{2, 1, rules.Exact},
+		{1, 0, rules.Difference},
+		{4, 1, rules.Result},
+		{1, 1, 0},
+		{0, 2, 0},
+	} {
+		points, exact, _ := Points(rules, models.Prediction{AScore: test.a, BScore: test.b}, group)
+		assert.Equal(test.points, points, "%d-%d", test.a, test.b)
+		assert.Equal(test.points == rules.Exact, exact)
+	}
+
+	// The winner of a knockout match is worth its points on top of the score, even when it went to penalties
+	knockout := models.Match{AID: 1, BID: 2, AScore: 1, BScore: 1, APenalties: 2, BPenalties: 4, Stage: models.FINAL, Played: true}
+	b, a := 2, 1
+
+	points, exact, winner := Points(rules, models.Prediction{AScore: 1, BScore: 1, WinnerID: &b}, knockout)
+	assert.Equal(rules.Exact+rules.Winner, points)
+	assert.True(exact)
+	assert.True(winner)
+
+	points, _, winner = Points(rules, models.Prediction{AScore: 0, BScore: 0, WinnerID: &a}, knockout)
+	assert.Equal(rules.Difference, points)
+	assert.False(winner)
+}
+
+func TestPredict(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newStore(t)
+
+	pool, err := Create(store.DB, "Office", models.DEFAULT_RULES)
+	assert.NoError(err)
+
+	_, err = Create(store.DB, "Office", models.Rules{})
+	assert.EqualError(err, "there is already a pool named `Office`")
+	_, err = Create(store.DB, "office", models.Rules{})
+	assert.EqualError(err, "there is already a pool named `office`")
+
+	alex, key, err := Join(store.DB, pool, "Alex")
+	assert.NoError(err)
+	assert.NotEmpty(key)
+
+	_, _, err = Join(store.DB, pool, "alex")
+	assert.EqualError(err, "there is already a user named `alex` in the pool")
+
+	sam, _, err := Join(store.DB, pool, "Sam")
+	assert.NoError(err)
+
+	var matches []models.Match
+	store.DB.Preload("ACountry").Preload("BCountry").Order("id").Find(&matches)
+	first, final := matches[0], matches[len(matches)-1]
+
+	prediction, err := Predict(store.DB, alex, Pick{MatchID: first.ID, Score: utils.Score{A: 2, B: 1}})
+	assert.NoError(err)
+	assert.Equal("Alex", prediction.User.Name)
+	assert.Nil(prediction.Winner)
+
+	// A second pick for the same match replaces the first
+	_, err = Predict(store.DB, alex, Pick{MatchID: first.ID, Score: utils.Score{A: 1, B: 0}})
+	assert.NoError(err)
+	_, err = Predict(store.DB, sam, Pick{MatchID: first.ID, Score: utils.Score{A: 0, B: 0}})
+	assert.NoError(err)
+
+	var count int64
+	store.DB.Model(&models.Prediction{}).Count(&count)
+	assert.EqualValues(2, count)
+
+	_, err = Predict(store.DB, alex, Pick{MatchID: first.ID, Score: utils.Score{A: 1, B: 0}, Winner: first.ACountry.Name})
+	assert.EqualError(err, "only a knockout match can have a winner")
+
+	// The final can be picked before its countries are known, but only with a country that is in the tournament
+	_, err = Predict(store.DB, alex, Pick{MatchID: final.ID, Score: utils.Score{A: 1, B: 1}, Winner: "Nowhere"})
+	assert.EqualError(err, "the country `Nowhere` could not be found")
+
+	prediction, err = Predict(store.DB, alex, Pick{MatchID: final.ID, Score: utils.Score{A: 1, B: 1}, Winner: first.ACountry.FifaCode})
+	assert.NoError(err)
+	assert.Equal(first.ACountry.Name, prediction.Winner.Name)
+
+	_, err = Predict(store.DB, alex, Pick{MatchID: 999, Score: utils.Score{A: 1, B: 0}})
+	assert.EqualError(err, "there is no match with the id 999")
+
+	// Nobody else's picks are shown until the match kicks off
+	predictions, err := Predictions(ctx, store.DB, pool)
+	assert.NoError(err)
+	assert.Empty(predictions)
+
+	play(store, first, 1, 0)
+
+	_, err = Predict(store.DB, sam, Pick{MatchID: first.ID, Score: utils.Score{A: 1, B: 0}})
+	var invalid *exceptions.RequestError
+	assert.True(errors.As(err, &invalid), err)
+
+	predictions, err = Predictions(ctx, store.DB, pool)
+	assert.NoError(err)
+	assert.Len(predictions, 2)
+
+	pool, err = Find(ctx, store.DB, "office")
+	assert.NoError(err)
+	assert.Len(pool.Users, 2)
+
+	leaderboard, err := Leaderboard(ctx, store.DB, pool)
+	assert.NoError(err)
+	assert.Equal([]string{"Alex", "Sam"}, []string{leaderboard[0].User.Name, leaderboard[1].User.Name})
+	assert.Equal(models.DEFAULT_RULES.Exact, leaderboard[0].Points)
+	assert.Equal(1, leaderboard[0].Exact)
+	assert.Equal(0, leaderboard[1].Points)
+	assert.Equal(2, leaderboard[1].Rank)
+
+	// Changing the rules changes the points that were already given
+	pool, err = SetRules(store.DB, pool, models.Rules{Exact: 1})
+	assert.NoError(err)
+	pool, _ = Find(ctx, store.DB, "Office")
+
+	leaderboard, _ = Leaderboard(ctx, store.DB, pool)
+	assert.Equal(1, leaderboard[0].Points)
+}
+
+func TestImport(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newStore(t)
+
+	pool, _ := Create(store.DB, "Office", models.DEFAULT_RULES)
+	_, _, err := Join(store.DB, pool, "Alex")
+	assert.NoError(err)
+
+	var match models.Match
+	store.DB.Order("id").First(&match)
+	play(store, match, 2, 2)
+
+	predictions := []utils.Prediction{
+		{User: "alex", Match: match.ID, Score: utils.Score{A: 2, B: 2}, Line: 2},
+		{User: "Sam", Match: match.ID, Score: utils.Score{A: 1, B: 1}, Line: 3},
+	}
+
+	// The match has already kicked off, so nothing is saved unless the late picks are allowed
+	_, err = Import(store.DB, pool, predictions, false)
+	assert.ErrorContains(err, "line 2: the predictions for match")
+
+	var count int64
+	store.DB.Model(&models.User{}).Count(&count)
+	assert.EqualValues(1, count)
+
+	saved, err := Import(store.DB, pool, predictions, true)
+	assert.NoError(err)
+	assert.Equal(2, saved)
+
+	pool, _ = Find(ctx, store.DB, "Office")
+	assert.Len(pool.Users, 2)
+
+	leaderboard, err := Leaderboard(ctx, store.DB, pool)
+	assert.NoError(err)
+	assert.Equal("Alex", leaderboard[0].User.Name)
+	assert.Equal(models.DEFAULT_RULES.Exact, leaderboard[0].Points)
+	assert.Equal(models.DEFAULT_RULES.Difference, leaderboard[1].Points)
+}
@@ -0,0 +1,241 @@
+// Package query finds the countries, players and matches in the database. The filters say what to look for, apart from
+// where the request came from, so the api, the command line and any other program all find things the same way.
+package query
+
+import (
+	"context"
+
+	"github.com/cazier/wc/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The FIFA codes of the `Team A` and `Team B` placeholder teams, used for the knockout matches before the teams in
+// them are known
+var placeholders = []string{"<A>", "<B>"}
+
+// byKickoff orders the matches by their kickoff time. The column is quoted for each database, since "when" is a
+// reserved word.
+var byKickoff = clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "when"}}
+
+var byId = clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}}
+
+// like matches a column against a pattern, ignoring the case, which LIKE only does by default on sqlite and mysql
+func like(column clause.Column, pattern string) clause.Expr {
+	return gorm.Expr("LOWER(?) LIKE LOWER(?)", column, pattern)
+}
+
+// equal matches a column against a value, ignoring the case
+func equal(column clause.Column, value string) clause.Expr {
+	return gorm.Expr("LOWER(?) = LOWER(?)", column, value)
+}
+
+// CountryFilter narrows down the countries. Each field that isn't empty has to match.
+type CountryFilter struct {
+	ID int
+	// The name can use the % and _ wildcards of LIKE, and the name, code and group all ignore the case
+	Name  string
+	Code  string
+	Group string
+
+	// Include the placeholder teams for the knockout matches
+	Placeholders bool
+
+	// The most countries to return, or 0 for all of them
+	Limit int
+}
+
+// FindCountries lists the countries that match the filter, in the order they were added
+func FindCountries(ctx context.Context, database *gorm.DB, filter CountryFilter) ([]models.Country, error) {
+	var countries []models.Country
+
+	tx := database.WithContext(ctx).Order(byId)
+	column := func(name string) clause.Column { return clause.Column{Table: clause.CurrentTable, Name: name} }
+
+	if !filter.Placeholders {
+		tx = tx.Where("? NOT IN ?", column("fifa_code"), placeholders)
+	}
+	if filter.ID != 0 {
+		tx = tx.Where(clause.Eq{Column: column("id"), Value: filter.ID})
+	}
+	if filter.Name != "" {
+		tx = tx.Where(like(column("name"), filter.Name))
+	}
+	if filter.Code != "" {
+		tx = tx.Where(equal(column("fifa_code"), filter.Code))
+	}
+	if filter.Group != "" {
+		tx = tx.Where(equal(column("group"), filter.Group))
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	return countries, tx.Find(&countries).Error
+}
+
+// PlayerFilter narrows down the players. Each field that isn't empty has to match.
+type PlayerFilter struct {
+	ID int
+	// The name can use the % and _ wildcards of LIKE, and ignores the case
+	Name string
+
+	CountryID int
+	// The country is either its name (which can use the wildcards too) or its FIFA code
+	Country string
+
+	// The most players to return, or 0 for all of them
+	Limit int
+}
+
+// FindPlayers lists the players that match the filter, along with their countries, in the order they were added
+func FindPlayers(ctx context.Context, database *gorm.DB, filter PlayerFilter) ([]models.Player, error) {
+	var players []models.Player
+
+	tx := database.WithContext(ctx).Joins("Country").Order(byId)
+	column := func(name string) clause.Column { return clause.Column{Table: clause.CurrentTable, Name: name} }
+
+	if filter.ID != 0 {
+		tx = tx.Where(clause.Eq{Column: column("id"), Value: filter.ID})
+	}
+	if filter.Name != "" {
+		tx = tx.Where(like(column("name"), filter.Name))
+	}
+	if filter.CountryID != 0 {
+		tx = tx.Where(clause.Eq{Column: column("country_id"), Value: filter.CountryID})
+	}
+	if filter.Country != "" {
+		tx = tx.Where(country(database, "Country", filter.Country))
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	return players, tx.Find(&players).Error
+}
+
+// MatchFilter narrows down the matches. Each field that isn't empty has to match, where a match is in a group, or
+// involves a country or player, when either of its countries are (or do).
+type MatchFilter struct {
+	ID    int
+	Day   int
+	Stage *models.Stage
+	// The group ignores the case
+	Group string
+
+	CountryID int
+	// The country is either its name (which can use the % and _ wildcards of LIKE) or its FIFA code
+	Country string
+
+	PlayerID int
+	// The name of the player can use the wildcards too, and ignores the case
+	Player string
+
+	Played *bool
+
+	// Also read the events of each match, in the order they happened
+	Events bool
+
+	// The most matches to return, or 0 for all of them
+	Limit int
+}
+
+// FindMatches lists the matches that match the filter, along with their countries, in the order they kick off
+func FindMatches(ctx context.Context, database *gorm.DB, filter MatchFilter) ([]models.Match, error) {
+	var matches []models.Match
+
+	tx := database.WithContext(ctx).Joins("ACountry").Joins("BCountry").Order(byKickoff).Order(byId)
+	column := func(name string) clause.Column { return clause.Column{Table: clause.CurrentTable, Name: name} }
+
+	// Either of the countries of the match has to match the condition
+	either := func(condition func(table string) any) *gorm.DB {
+		return database.Where(condition("ACountry")).Or(condition("BCountry"))
+	}
+
+	if filter.ID != 0 {
+		tx = tx.Where(clause.Eq{Column: column("id"), Value: filter.ID})
+	}
+	if filter.Day != 0 {
+		tx = tx.Where(clause.Eq{Column: column("day"), Value: filter.Day})
+	}
+	if filter.Stage != nil {
+		tx = tx.Where(clause.Eq{Column: column("stage"), Value: *filter.Stage})
+	}
+	if filter.Played != nil {
+		tx = tx.Where(clause.Eq{Column: column("played"), Value: *filter.Played})
+	}
+	if filter.Group != "" {
+		tx = tx.Where(either(func(table string) any {
+			return equal(clause.Column{Table: table, Name: "group"}, filter.Group)
+		}))
+	}
+	if filter.CountryID != 0 {
+		tx = tx.Where(either(func(table string) any {
+			return clause.Eq{Column: clause.Column{Table: table, Name: "id"}, Value: filter.CountryID}
+		}))
+	}
+	if filter.Country != "" {
+		tx = tx.Where(either(func(table string) any {
+			return country(database, table, filter.Country)
+		}))
+	}
+
+	if filter.PlayerID != 0 || filter.Player != "" {
+		squads := database.Model(&models.Player{}).Select("country_id")
+		if filter.PlayerID != 0 {
+			squads = squads.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}, Value: filter.PlayerID})
+		}
+		if filter.Player != "" {
+			squads = squads.Where(like(clause.Column{Table: clause.CurrentTable, Name: "name"}, filter.Player))
+		}
+
+		tx = tx.Where(database.Where("? IN (?)", column("a_id"), squads).Or("? IN (?)", column("b_id"), squads))
+	}
+
+	if filter.Events {
+		// The columns are quoted, since "offset" is a reserved word
+		tx = tx.Preload("Events", func(tx *gorm.DB) *gorm.DB {
+			for _, name := range []string{"minute", "offset", "id"} {
+				tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: name}})
+			}
+			return tx
+		}).Preload("Events.Country")
+	}
+
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	return matches, tx.Find(&matches).Error
+}
+
+// RatingFilter narrows down the history of the ratings
+type RatingFilter struct {
+	CountryID int
+	// The country can be given by its name (which can use wildcards) or its FIFA code
+	Country string
+}
+
+// FindRatings lists the changes to the ratings of the countries that match the filter, along with the countries, in
+// the order the matches were played
+func FindRatings(ctx context.Context, database *gorm.DB, filter RatingFilter) ([]models.Rating, error) {
+	var ratings []models.Rating
+
+	tx := database.WithContext(ctx).Joins("Country").Order(byKickoff).Order(byId)
+
+	if filter.CountryID != 0 {
+		tx = tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "country_id"}, Value: filter.CountryID})
+	}
+	if filter.Country != "" {
+		tx = tx.Where(country(database, "Country", filter.Country))
+	}
+
+	return ratings, tx.Find(&ratings).Error
+}
+
+// country matches the country in the table by its name or FIFA code
+func country(database *gorm.DB, table, text string) *gorm.DB {
+	return database.
+		Where(like(clause.Column{Table: table, Name: "name"}, text)).
+		Or(equal(clause.Column{Table: table, Name: "fifa_code"}, text))
+}
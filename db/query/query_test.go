@@ -0,0 +1,157 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/scoring"
+	"github.com/stretchr/testify/assert"
+)
+
+var store *db.Store
+
+func init() {
+	var err error
+	if store, err = db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 1}); err != nil {
+		panic(err)
+	}
+
+	if err = store.LinkTables(false); err != nil {
+		panic(err)
+	}
+
+	files := load.Files{Teams: "../../test/teams.yaml", Matches: "../../test/matches.yaml", Players: "../../test/players.yaml"}
+	if _, err = load.Import(store, files, load.Options{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestFindCountries(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	countries, err := FindCountries(ctx, store.DB, CountryFilter{})
+	assert.NoError(err)
+	assert.Len(countries, 32)
+
+	countries, err = FindCountries(ctx, store.DB, CountryFilter{Placeholders: true})
+	assert.NoError(err)
+	assert.Len(countries, 34)
+
+	countries, _ = FindCountries(ctx, store.DB, CountryFilter{Group: "a"})
+	assert.Len(countries, 4)
+
+	countries, _ = FindCountries(ctx, store.DB, CountryFilter{Code: "nzl"})
+	assert.Len(countries, 1)
+	assert.Equal("New Zealand", countries[0].Name)
+
+	countries, _ = FindCountries(ctx, store.DB, CountryFilter{Name: "%land%", Group: "A"})
+	assert.Len(countries, 2)
+
+	countries, _ = FindCountries(ctx, store.DB, CountryFilter{Name: "new zealand", Group: "B"})
+	assert.Empty(countries)
+
+	countries, _ = FindCountries(ctx, store.DB, CountryFilter{Limit: 3})
+	assert.Len(countries, 3)
+}
+
+func TestFindPlayers(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	players, err := FindPlayers(ctx, store.DB, PlayerFilter{Country: "NZL"})
+	assert.NoError(err)
+	assert.NotEmpty(players)
+
+	for _, player := range players {
+		assert.Equal("New Zealand", player.Country.Name)
+	}
+
+	byName, _ := FindPlayers(ctx, store.DB, PlayerFilter{Country: "new zealand"})
+	assert.Equal(players, byName)
+
+	byId, _ := FindPlayers(ctx, store.DB, PlayerFilter{CountryID: players[0].Country.ID})
+	assert.Equal(players, byId)
+
+	found, _ := FindPlayers(ctx, store.DB, PlayerFilter{Name: players[0].Name, Country: "NZL"})
+	assert.Equal(players[0].ID, found[0].ID)
+
+	found, _ = FindPlayers(ctx, store.DB, PlayerFilter{ID: players[1].ID})
+	assert.Len(found, 1)
+	assert.Equal(players[1], found[0])
+
+	found, _ = FindPlayers(ctx, store.DB, PlayerFilter{ID: players[1].ID, Country: "NOR"})
+	assert.Empty(found)
+}
+
+func TestFindMatches(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	matches, err := FindMatches(ctx, store.DB, MatchFilter{})
+	assert.NoError(err)
+	assert.Len(matches, 64)
+
+	for index := 1; index < len(matches); index++ {
+		assert.False(matches[index].When.Before(matches[index-1].When), "the matches should be in kickoff order")
+	}
+
+	stage := models.QUARTERFINALS
+	matches, _ = FindMatches(ctx, store.DB, MatchFilter{Stage: &stage})
+	assert.Len(matches, 4)
+
+	matches, _ = FindMatches(ctx, store.DB, MatchFilter{Group: "b"})
+	assert.Len(matches, 6)
+
+	matches, _ = FindMatches(ctx, store.DB, MatchFilter{Country: "NZL"})
+	assert.Len(matches, 3)
+
+	byName, _ := FindMatches(ctx, store.DB, MatchFilter{Country: "New Zealand"})
+	assert.Equal(matches, byName)
+
+	byId, _ := FindMatches(ctx, store.DB, MatchFilter{CountryID: matches[0].ACountry.ID})
+	assert.Len(byId, 3)
+
+	played := true
+	matches, _ = FindMatches(ctx, store.DB, MatchFilter{Country: "NZL", Played: &played})
+	assert.Empty(matches)
+
+	players, _ := FindPlayers(ctx, store.DB, PlayerFilter{Country: "NZL", Limit: 1})
+	matches, _ = FindMatches(ctx, store.DB, MatchFilter{PlayerID: players[0].ID})
+	assert.Equal(byName, matches)
+
+	matches, _ = FindMatches(ctx, store.DB, MatchFilter{Player: players[0].Name, Day: 1})
+	assert.NotEmpty(matches)
+	for _, match := range matches {
+		assert.Equal(1, match.Day)
+	}
+}
+
+func TestFindMatchEvents(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	match := must(FindMatches(ctx, store.DB, MatchFilter{Limit: 1}))[0]
+	assert.Empty(match.Events)
+
+	for _, minute := range []int{70, 20} {
+		_, err := scoring.AddEvent(store.DB, match.ID, utils.Event{Kind: models.YELLOW, Country: match.ACountry.Name, Player: "Someone", Minute: minute})
+		assert.NoError(err)
+	}
+
+	match = must(FindMatches(ctx, store.DB, MatchFilter{ID: match.ID, Events: true}))[0]
+	assert.GreaterOrEqual(len(match.Events), 2)
+	assert.Equal(20, match.Events[0].Minute)
+	assert.Equal(match.ACountry.Name, match.Events[0].Country.Name)
+}
+
+func must[T any](items []T, err error) []T {
+	if err != nil {
+		panic(err)
+	}
+	return items
+}
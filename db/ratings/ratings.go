@@ -0,0 +1,138 @@
+// Package ratings rates the strength of each country with the Elo system, from the rating it had before the
+// tournament and every result since then.
+package ratings
+
+import (
+	"math"
+	"sort"
+
+	"github.com/cazier/wc/db/models"
+	"gorm.io/gorm"
+)
+
+// How many points a result is worth at each stage, before it's weighted by the goal margin. The later rounds count
+// for more, like in the World Football Elo ratings.
+var weights = map[models.Stage]float64{
+	models.GROUP:            50,
+	models.ROUND_OF_SIXTEEN: 55,
+	models.QUARTERFINALS:    60,
+	models.SEMIFINALS:       60,
+	models.THIRD_PLACE:      50,
+	models.FINAL:            65,
+}
+
+// How many points ahead a country needs to be rated to be ten times more likely to win
+const scale = 400
+
+// Compute replays the matches that have been played, in kickoff order, starting each country from its base rating.
+// It returns the rating of each country by its id, along with the change each match made to the ratings of the two
+// countries in it. A match decided on penalties counts as a draw.
+func Compute(countries []models.Country, matches []models.Match) (map[int]float64, []models.Rating) {
+	ratings := make(map[int]float64)
+	for _, country := range countries {
+		ratings[country.ID] = country.BaseRating
+	}
+
+	played := make([]models.Match, 0, len(matches))
+	for _, match := range matches {
+		if match.Played {
+			played = append(played, match)
+		}
+	}
+
+	sort.SliceStable(played, func(i, j int) bool {
+		if !played[i].When.Equal(played[j].When) {
+			return played[i].When.Before(played[j].When)
+		}
+		return played[i].ID < played[j].ID
+	})
+
+	var history []models.Rating
+	for _, match := range played {
+		a, b := ratings[match.AID], ratings[match.BID]
+		change := weights[match.Stage] * margin(match.AScore-match.BScore) * (result(match) - Expected(a, b))
+
+		ratings[match.AID], ratings[match.BID] = a+change, b-change
+
+		history = append(history,
+			models.Rating{CountryID: match.AID, MatchID: match.ID, When: match.When, Rating: round(a + change), Change: round(change)},
+			models.Rating{CountryID: match.BID, MatchID: match.ID, When: match.When, Rating: round(b - change), Change: round(-change)},
+		)
+	}
+
+	return ratings, history
+}
+
+// Expected is the result (from 0 for a loss to 1 for a win) a country rated a is expected to get against one rated b
+func Expected(a, b float64) float64 {
+	return 1 / (math.Pow(10, (b-a)/scale) + 1)
+}
+
+// Update computes the ratings from every result in the database, saving the rating of each country that changed and
+// replacing the history of the ratings
+func Update(tx *gorm.DB) error {
+	var countries []models.Country
+	var matches []models.Match
+
+	if err := tx.Find(&countries).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("played = ?", true).Find(&matches).Error; err != nil {
+		return err
+	}
+
+	ratings, history := Compute(countries, matches)
+
+	for _, country := range countries {
+		rating := round(ratings[country.ID])
+		if rating == country.Rating {
+			continue
+		}
+
+		if err := tx.Model(&country).Update("rating", rating).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&models.Rating{}).Error; err != nil {
+		return err
+	}
+
+	if len(history) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&history, 100).Error
+}
+
+// result is the result of the match for the first country: 1 for a win, 0.5 for a draw and 0 for a loss
+func result(match models.Match) float64 {
+	switch {
+	case match.AScore > match.BScore:
+		return 1
+	case match.AScore < match.BScore:
+		return 0
+	default:
+		return 0.5
+	}
+}
+
+// margin weights a result by how many goals it was won by
+func margin(goals int) float64 {
+	if goals < 0 {
+		goals = -goals
+	}
+
+	switch {
+	case goals <= 1:
+		return 1
+	case goals == 2:
+		return 1.5
+	default:
+		return (11 + float64(goals)) / 8
+	}
+}
+
+func round(rating float64) float64 {
+	return math.Round(rating*10) / 10
+}
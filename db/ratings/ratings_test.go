@@ -0,0 +1,67 @@
+package ratings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cazier/wc/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var kickoff = time.Date(2022, 11, 20, 16, 0, 0, 0, time.UTC)
+
+func country(id int, rating float64) models.Country {
+	return models.Country{ID: id, Rating: rating, BaseRating: rating}
+}
+
+func match(id, a, b, aScore, bScore int, stage models.Stage, day int) models.Match {
+	return models.Match{
+		ID: id, AID: a, BID: b, AScore: aScore, BScore: bScore, Stage: stage, Played: true,
+		When: kickoff.AddDate(0, 0, day),
+	}
+}
+
+func TestExpected(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0.5, Expected(1500, 1500))
+	assert.InDelta(10.0/11, Expected(1900, 1500), 1e-9)
+	assert.InDelta(1, Expected(1500, 1900)+Expected(1900, 1500), 1e-9)
+}
+
+func TestCompute(t *testing.T) {
+	assert := assert.New(t)
+	countries := []models.Country{country(1, 1500), country(2, 1500), country(3, 1700)}
+
+	// An even match won by a single goal is worth half of the weight
+	ratings, history := Compute(countries, []models.Match{match(1, 1, 2, 1, 0, models.GROUP, 0)})
+	assert.Equal(1525.0, ratings[1])
+	assert.Equal(1475.0, ratings[2])
+	assert.Equal(1700.0, ratings[3])
+	assert.Equal([]models.Rating{
+		{CountryID: 1, MatchID: 1, When: kickoff, Rating: 1525, Change: 25},
+		{CountryID: 2, MatchID: 1, When: kickoff, Rating: 1475, Change: -25},
+	}, history)
+
+	// A bigger margin, and a later round, count for more
+	ratings, _ = Compute(countries, []models.Match{match(1, 1, 2, 3, 0, models.GROUP, 0)})
+	assert.Equal(1500+25*14.0/8, ratings[1])
+	ratings, _ = Compute(countries, []models.Match{match(1, 1, 2, 1, 0, models.FINAL, 0)})
+	assert.Equal(1532.5, ratings[1])
+
+	// A match won on penalties is a draw, which costs the higher rated country
+	shootout := match(1, 1, 3, 1, 1, models.ROUND_OF_SIXTEEN, 0)
+	shootout.APenalties, shootout.BPenalties = 3, 4
+	ratings, _ = Compute(countries, []models.Match{shootout})
+	assert.Greater(ratings[1], 1500.0)
+	assert.Less(ratings[3], 1700.0)
+
+	// The matches are replayed in the order they kicked off, and the unplayed ones are skipped
+	later := match(1, 1, 2, 0, 2, models.GROUP, 1)
+	unplayed := match(3, 2, 3, 0, 0, models.GROUP, 2)
+	unplayed.Played = false
+	_, history = Compute(countries, []models.Match{later, match(2, 1, 3, 2, 2, models.GROUP, 0), unplayed})
+	assert.Len(history, 4)
+	assert.Equal(2, history[0].MatchID)
+	assert.Equal(1, history[2].MatchID)
+}
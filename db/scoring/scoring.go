@@ -0,0 +1,230 @@
+// Package scoring records the results of matches as they are played: the score, the events (goals and cards) and
+// finally the end of the match. The api and the command line both use it, so the same rules apply to either.
+package scoring
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cazier/wc/api/exceptions"
+	"github.com/cazier/wc/cache"
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/ratings"
+	"gorm.io/gorm"
+)
+
+// The latest minute an event can happen in, before any stoppage time, which is the end of extra time
+const lastMinute = 120
+
+// SetScore changes the score of a match that hasn't finished yet. Penalties can only be given for a knockout match
+// that is level.
+func SetScore(database *gorm.DB, id int, score utils.Score, penalties *utils.Score) (models.Match, error) {
+	var match models.Match
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var err error
+
+		if match, err = find(tx, id); err != nil {
+			return err
+		}
+
+		if err = validateScore(match, score, penalties); err != nil {
+			return err
+		}
+
+		if penalties == nil {
+			penalties = &utils.Score{}
+		}
+
+		update := map[string]any{
+			"a_score":     score.A,
+			"b_score":     score.B,
+			"a_penalties": penalties.A,
+			"b_penalties": penalties.B,
+		}
+
+		return tx.Model(&match).Updates(update).Error
+	})
+
+	if err != nil {
+		return models.Match{}, err
+	}
+
+	committed(database)
+	return match, nil
+}
+
+// AddEvent records a goal or card in a match that hasn't finished yet, and updates the player totals. The country
+// of the event can be given by either its name or FIFA code, and must be one of the two teams in the match.
+func AddEvent(database *gorm.DB, id int, event utils.Event) (models.Event, error) {
+	var row models.Event
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		match, err := find(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = validateEvent(match, event); err != nil {
+			return err
+		}
+
+		country := match.ACountry
+		if !country.Is(event.Country) {
+			country = match.BCountry
+		}
+
+		row = models.Event{
+			MatchID:   match.ID,
+			CountryID: country.ID,
+			Country:   country,
+			Kind:      event.Kind,
+			Player:    strings.TrimSpace(event.Player),
+			Minute:    event.Minute,
+			Offset:    event.Offset,
+			Penalty:   event.Penalty,
+			OwnGoal:   event.OwnGoal,
+		}
+
+		if err = tx.Omit("Country").Create(&row).Error; err != nil {
+			return err
+		}
+
+		return db.UpdatePlayerStats(tx)
+	})
+
+	if err != nil {
+		return models.Event{}, err
+	}
+
+	committed(database)
+	return row, nil
+}
+
+// Finish marks the match as played, once the score is complete, and updates the ratings of the countries. A knockout
+// match needs a winner, either from the score or the penalties, and any goals that were recorded must add up to the
+// score.
+func Finish(database *gorm.DB, id int) (models.Match, error) {
+	var match models.Match
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var err error
+
+		if match, err = find(tx, id); err != nil {
+			return err
+		}
+
+		if err = validateFinish(match); err != nil {
+			return err
+		}
+
+		if err = tx.Model(&match).Update("played", true).Error; err != nil {
+			return err
+		}
+
+		if err = db.UpdatePlayerStats(tx); err != nil {
+			return err
+		}
+
+		return ratings.Update(tx)
+	})
+
+	if err != nil {
+		return models.Match{}, err
+	}
+
+	committed(database)
+	return match, nil
+}
+
+// committed invalidates the read caches again once a change is committed, since an api request could have read the
+// tables between the writes and the commit
+func committed(database *gorm.DB) {
+	cache.Invalidate(database, "matches", "events", "players", "countries", "ratings")
+}
+
+func find(tx *gorm.DB, id int) (models.Match, error) {
+	var match models.Match
+
+	result := tx.Preload("Events").Joins("ACountry").Joins("BCountry").Limit(1).Find(&match, id)
+	if result.Error != nil {
+		return match, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return match, &exceptions.NoResultsFoundError{}
+	}
+
+	return match, nil
+}
+
+func invalid(format string, args ...any) error {
+	return &exceptions.RequestError{Message: fmt.Sprintf(format, args...)}
+}
+
+func validateScore(match models.Match, score utils.Score, penalties *utils.Score) error {
+	switch {
+	case match.Played:
+		return invalid("the match has already finished")
+	case score.A < 0 || score.B < 0:
+		return invalid("the score can't be negative: `%s`", score)
+	case penalties == nil:
+		return nil
+	case match.Stage == models.GROUP:
+		return invalid("penalties can only be given for a knockout match")
+	case score.A != score.B:
+		return invalid("penalties can only be given when the score is level")
+	case penalties.A < 0 || penalties.B < 0:
+		return invalid("the penalties can't be negative: `%s`", penalties)
+	}
+	return nil
+}
+
+func validateEvent(match models.Match, event utils.Event) error {
+	switch {
+	case match.Played:
+		return invalid("the match has already finished")
+	case event.Kind != models.GOAL && event.Kind != models.YELLOW && event.Kind != models.RED:
+		return invalid("unknown event kind: `%s`", event.Kind)
+	case strings.TrimSpace(event.Player) == "":
+		return invalid("the event needs the name of the player")
+	case !match.ACountry.Is(event.Country) && !match.BCountry.Is(event.Country):
+		return invalid("`%s` is not one of the teams in the match", event.Country)
+	case event.Minute < 1 || event.Minute > lastMinute || event.Offset < 0:
+		return invalid("the minute of the event is out of range: %d+%d", event.Minute, event.Offset)
+	case event.Kind != models.GOAL && (event.Penalty || event.OwnGoal):
+		return invalid("only a goal can be a penalty or an own goal")
+	}
+	return nil
+}
+
+func validateFinish(match models.Match) error {
+	if match.Played {
+		return invalid("the match has already finished")
+	}
+
+	goals := map[int]int{}
+	recorded := false
+
+	for _, event := range match.Events {
+		if event.Kind == models.GOAL {
+			goals[event.CountryID]++
+			recorded = true
+		}
+	}
+
+	if recorded && (goals[match.AID] != match.AScore || goals[match.BID] != match.BScore) {
+		return invalid(
+			"the goals recorded (%d-%d) don't match the score (%d-%d)",
+			goals[match.AID], goals[match.BID], match.AScore, match.BScore,
+		)
+	}
+
+	if match.Stage > models.GROUP && match.AScore == match.BScore && match.APenalties == match.BPenalties {
+		return invalid("a knockout match needs a winner, from either the score or the penalties")
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package seed
+
+// The countries the tournament is drawn from, with their FIFA codes
+var countries = []struct{ name, code string }{
+	{"Argentina", "ARG"}, {"Australia", "AUS"}, {"Austria", "AUT"}, {"Belgium", "BEL"},
+	{"Brazil", "BRA"}, {"Cameroon", "CMR"}, {"Canada", "CAN"}, {"Chile", "CHI"},
+	{"China PR", "CHN"}, {"Colombia", "COL"}, {"Costa Rica", "CRC"}, {"Croatia", "CRO"},
+	{"Czechia", "CZE"}, {"Denmark", "DEN"}, {"Ecuador", "ECU"}, {"Egypt", "EGY"},
+	{"England", "ENG"}, {"France", "FRA"}, {"Germany", "GER"}, {"Ghana", "GHA"},
+	{"Iceland", "ISL"}, {"IR Iran", "IRN"}, {"Italy", "ITA"}, {"Ivory Coast", "CIV"},
+	{"Jamaica", "JAM"}, {"Japan", "JPN"}, {"Korea Republic", "KOR"}, {"Mexico", "MEX"},
+	{"Morocco", "MAR"}, {"Netherlands", "NED"}, {"New Zealand", "NZL"}, {"Nigeria", "NGA"},
+	{"Norway", "NOR"}, {"Panama", "PAN"}, {"Paraguay", "PAR"}, {"Peru", "PER"},
+	{"Poland", "POL"}, {"Portugal", "POR"}, {"Qatar", "QAT"}, {"Saudi Arabia", "KSA"},
+	{"Scotland", "SCO"}, {"Senegal", "SEN"}, {"Serbia", "SRB"}, {"Spain", "ESP"},
+	{"Sweden", "SWE"}, {"Switzerland", "SUI"}, {"Tunisia", "TUN"}, {"Ukraine", "UKR"},
+	{"United States", "USA"}, {"Uruguay", "URU"}, {"Wales", "WAL"},
+}
+
+// The names the players are made up from. They aren't matched to the countries, which is fine for a demo.
+var firstNames = []string{
+	"Aaron", "Adam", "Adrien", "Ahmed", "Alex", "Alexis", "Ali", "Andrés", "Antoine", "Ben",
+	"Bruno", "Callum", "Carlos", "Christian", "Daniel", "David", "Diego", "Dominik", "Edson", "Emil",
+	"Enzo", "Erik", "Felix", "Gabriel", "Giorgio", "Hakim", "Harry", "Hugo", "Ivan", "Jakub",
+	"James", "Jan", "Jesús", "Joao", "Jonas", "Jordan", "Jorge", "José", "Joshua", "Julian",
+	"Kai", "Kenji", "Kevin", "Kim", "Leon", "Lucas", "Luis", "Luka", "Marco", "Mario",
+	"Mateo", "Mateus", "Matías", "Mohamed", "Moussa", "Nathan", "Nicolás", "Noah", "Oliver", "Omar",
+	"Pablo", "Pedro", "Rafael", "Raúl", "Riccardo", "Rodrigo", "Ruben", "Samuel", "Santiago", "Sergio",
+	"Stefan", "Takumi", "Thiago", "Thomas", "Tomás", "Victor", "Wataru", "Yassine", "Youssef", "Zoran",
+}
+
+var lastNames = []string{
+	"Abdullah", "Alonso", "Álvarez", "Andersen", "Bakker", "Becker", "Bernard", "Berg", "Bianchi", "Campbell",
+	"Castro", "Costa", "Cruz", "Dahl", "Diallo", "Díaz", "Dubois", "Eriksen", "Fernandes", "Fischer",
+	"Flores", "Fonseca", "García", "Gomes", "González", "Hansen", "Hartmann", "Hernández", "Horvat", "Hughes",
+	"Ito", "Jansen", "Jensen", "Johansson", "Jones", "Kamara", "Keita", "Kelly", "Klein", "Kovač",
+	"Kowalski", "Lambert", "Larsen", "Lee", "Lopes", "López", "Mansour", "Martin", "Martínez", "Mendes",
+	"Meyer", "Moreau", "Morales", "Müller", "Murray", "Nakamura", "Navarro", "Nielsen", "Novák", "Nowak",
+	"Okafor", "Olsen", "Ortiz", "Park", "Pereira", "Petrović", "Popescu", "Ramírez", "Ramos", "Ricci",
+	"Rodrigues", "Rossi", "Ruiz", "Santos", "Sato", "Schmidt", "Silva", "Smith", "Suárez", "Suzuki",
+	"Tanaka", "Torres", "Traoré", "Van Dijk", "Vargas", "Vidal", "Wagner", "Walker", "Weber", "Wright",
+}
@@ -0,0 +1,469 @@
+// Package seed makes up a whole tournament for demos and load tests: the countries and their groups, a squad for each
+// of them, the fixtures, and, for as many of the matches as asked for, their results and events. The same seed always
+// makes the same tournament.
+package seed
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/load/utils"
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/standings"
+)
+
+// The size of the tournament, which follows the 32 team format
+const (
+	groups     = 8
+	groupSize  = 4
+	squadSize  = 23
+	goalkeeper = "GK"
+)
+
+// The average number of goals each country scores against an equal one, and the events in a match apart from them
+const (
+	averageGoals   = 1.3
+	averageYellows = 3.5
+	averageReds    = 0.12
+)
+
+// The squad of each country, as how many players there are in each position
+var positions = []struct {
+	position string
+	players  int
+	// How likely a player in the position is to score, compared to the others
+	goals int
+}{
+	{goalkeeper, 3, 0},
+	{"DF", 8, 1},
+	{"MF", 8, 3},
+	{"FW", 4, 6},
+}
+
+// The kickoff times (in UTC) of the matches on a day, by how many matches are played that day
+var kickoffs = map[int][]int{
+	1: {16},
+	2: {14, 18},
+	4: {10, 13, 16, 19},
+}
+
+// The schedule of the rounds after the group stage, as the days after the start of the tournament the round is played
+// on, and the number of matches each day
+var rounds = []struct {
+	stage   models.Stage
+	days    []int
+	perDay  int
+	matches int
+}{
+	{models.ROUND_OF_SIXTEEN, []int{13, 14, 15, 16}, 2, 8},
+	{models.QUARTERFINALS, []int{18, 19}, 2, 4},
+	{models.SEMIFINALS, []int{21, 22}, 1, 2},
+	{models.THIRD_PLACE, []int{25}, 1, 1},
+	{models.FINAL, []int{26}, 1, 1},
+}
+
+// The names of the placeholder countries used for the knockout matches before the countries in them are known
+const (
+	placeholderA = "Team A"
+	placeholderB = "Team B"
+)
+
+type Options struct {
+	// Making the tournament again with the same seed (and start) gives exactly the same one
+	Seed int64
+	// The day of the first match
+	Start time.Time
+	// How many of the matches, in the order they kick off, have results made up for them. Use -1 for all of them.
+	Played int
+}
+
+// country is one of the countries in the tournament, along with how good it is
+type country struct {
+	utils.Team
+	// How many times more goals it scores (and fewer it concedes) than an average country, on a log scale
+	strength float64
+	squad    []utils.Player
+}
+
+type tournament struct {
+	random    *rand.Rand
+	options   Options
+	countries []*country
+	byName    map[string]*country
+	matches   []utils.Match
+}
+
+// How many rating points a country gains for each step up in its strength. Ten times more goals is worth 800 points,
+// in the same way as the simulate package.
+const ratingScale = 800 / math.Ln10
+
+// Generate makes up the tournament, in the same layout as the import files. Each country is rated from how good it is,
+// as if that was its rating before the tournament.
+func Generate(options Options) *load.Tables {
+	t := &tournament{random: rand.New(rand.NewSource(options.Seed)), options: options, byName: make(map[string]*country)}
+
+	t.draw()
+	t.schedule()
+
+	tables := &load.Tables{Matches: t.matches}
+	for _, c := range t.countries {
+		tables.Teams = append(tables.Teams, c.Team)
+		tables.Players = append(tables.Players, c.squad...)
+		tables.Ratings = append(tables.Ratings, utils.Rating{
+			Country: c.Code,
+			Rating:  math.Round(models.INITIAL_RATING + ratingScale*c.strength),
+		})
+	}
+
+	return tables
+}
+
+// draw picks the countries, puts them into groups and names their squads
+func (t *tournament) draw() {
+	for index, pick := range t.random.Perm(len(countries))[:groups*groupSize] {
+		c := &country{
+			Team:     utils.Team{Name: countries[pick].name, Code: countries[pick].code, Group: string(rune('A' + index/groupSize))},
+			strength: t.random.NormFloat64() * 0.35,
+		}
+		c.squad = t.squad(c.Code)
+
+		t.countries = append(t.countries, c)
+		t.byName[c.Name] = c
+	}
+}
+
+// squad names the players of a country, giving the goalkeepers the usual shirt numbers
+func (t *tournament) squad(code string) []utils.Player {
+	var players []utils.Player
+
+	used := make(map[string]bool)
+	goalkeepers := []int{1, 12, 23}
+
+	var numbers []int
+	for _, n := range t.random.Perm(squadSize) {
+		if n+1 != 1 && n+1 != 12 && n+1 != 23 {
+			numbers = append(numbers, n+1)
+		}
+	}
+
+	for _, position := range positions {
+		for index := 0; index < position.players; index++ {
+			name := t.name()
+			for used[name] {
+				name = t.name()
+			}
+			used[name] = true
+
+			var number int
+			if position.position == goalkeeper {
+				number, goalkeepers = goalkeepers[0], goalkeepers[1:]
+			} else {
+				number, numbers = numbers[0], numbers[1:]
+			}
+
+			players = append(players, utils.Player{Name: name, Country: code, Number: number, Position: position.position})
+		}
+	}
+
+	return players
+}
+
+func (t *tournament) name() string {
+	return firstNames[t.random.Intn(len(firstNames))] + " " + lastNames[t.random.Intn(len(lastNames))]
+}
+
+// schedule adds the matches in the order they kick off, playing each of them in turn until the results run out
+func (t *tournament) schedule() {
+	start := time.Date(t.options.Start.Year(), t.options.Start.Month(), t.options.Start.Day(), 0, 0, 0, 0, time.UTC)
+	kickoff := func(day, slot, perDay int) time.Time {
+		return start.AddDate(0, 0, day).Add(time.Duration(kickoffs[perDay][slot]) * time.Hour)
+	}
+
+	// Each country plays the other three in its group, one in each round of the group stage, with four matches a day
+	pairs := [][2]int{{0, 1}, {2, 3}, {0, 2}, {3, 1}, {3, 0}, {1, 2}}
+	for round := 0; round < 3; round++ {
+		for index := 0; index < groups*2; index++ {
+			group, pair := index/2, pairs[round*2+index%2]
+			a, b := t.countries[group*groupSize+pair[0]], t.countries[group*groupSize+pair[1]]
+
+			t.add(utils.Match{A: a.Name, B: b.Name, Stage: models.GROUP, Date: kickoff(round*4+index/4, index%4, 4)})
+		}
+	}
+
+	for _, round := range rounds {
+		for index := 0; index < round.matches; index++ {
+			a, b := t.knockout(round.stage, index)
+			date := kickoff(round.days[index/round.perDay], index%round.perDay, round.perDay)
+
+			t.add(utils.Match{A: a, B: b, Stage: round.stage, Date: date})
+		}
+	}
+}
+
+// knockout finds the countries in a knockout match, once the matches deciding them have been played. Until then, the
+// placeholder countries are used.
+func (t *tournament) knockout(stage models.Stage, index int) (string, string) {
+	switch stage {
+	case models.ROUND_OF_SIXTEEN:
+		// The winners of each group play the runners up of the group next to it: 1A v 2B, 1C v 2D, ... then 1B v 2A
+		pair := index % 4 * 2
+		first, second := pair, pair+1
+		if index >= 4 {
+			first, second = pair+1, pair
+		}
+
+		tables := t.groups()
+		if tables[first] == nil || tables[second] == nil {
+			return placeholderA, placeholderB
+		}
+		return tables[first][0], tables[second][1]
+
+	case models.THIRD_PLACE, models.FINAL:
+		semifinals := t.stage(models.SEMIFINALS)
+		winner := stage == models.FINAL
+
+		a, aFound := result(semifinals[0], winner)
+		b, bFound := result(semifinals[1], winner)
+		if !aFound || !bFound {
+			return placeholderA, placeholderB
+		}
+		return a, b
+
+	default:
+		// The winners of each pair of matches in the round before play each other
+		previous := t.stage(stage - 1)
+
+		a, aFound := result(previous[index*2], true)
+		b, bFound := result(previous[index*2+1], true)
+		if !aFound || !bFound {
+			return placeholderA, placeholderB
+		}
+		return a, b
+	}
+}
+
+// groups ranks the countries in each group that has finished, by the same rules as the api. The groups that are still
+// being played are left empty.
+func (t *tournament) groups() [][]string {
+	var rows []models.Country
+	var matches []models.Match
+
+	ids := make(map[string]int)
+	for index, c := range t.countries {
+		ids[c.Name] = index + 1
+		rows = append(rows, models.Country{ID: index + 1, Name: c.Name, FifaCode: c.Code, Group: c.Group})
+	}
+
+	finished := make(map[string]int)
+	for _, match := range t.stage(models.GROUP) {
+		if match.Score == nil {
+			continue
+		}
+
+		finished[t.byName[match.A].Group]++
+		matches = append(matches, models.Match{
+			AID: ids[match.A], BID: ids[match.B], Played: true, Stage: models.GROUP,
+			AScore: match.Score.A, BScore: match.Score.B,
+		})
+	}
+
+	tables := make([][]string, groups)
+	for index, group := range standings.Compute(rows, matches) {
+		if finished[group.Group] < groupSize*(groupSize-1)/2 {
+			continue
+		}
+
+		for _, standing := range group.Standings {
+			tables[index] = append(tables[index], standing.Country.Name)
+		}
+	}
+
+	return tables
+}
+
+// stage lists the matches added so far in a stage, in the order they kick off
+func (t *tournament) stage(stage models.Stage) []utils.Match {
+	var matches []utils.Match
+	for _, match := range t.matches {
+		if match.Stage == stage {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// result finds the winner (or loser) of a knockout match that has been played
+func result(match utils.Match, winner bool) (string, bool) {
+	if match.Score == nil {
+		return "", false
+	}
+
+	a, b := match.Score.A, match.Score.B
+	if a == b && match.Penalties != nil {
+		a, b = match.Penalties.A, match.Penalties.B
+	}
+
+	if (a > b) == winner {
+		return match.A, true
+	}
+	return match.B, true
+}
+
+// add schedules a match, and plays it when there are still results to make up
+func (t *tournament) add(match utils.Match) {
+	if match.A != placeholderA && (t.options.Played < 0 || len(t.matches) < t.options.Played) {
+		t.play(&match)
+	}
+	t.matches = append(t.matches, match)
+}
+
+// play makes up the result of a match, and the goals and cards in it. A knockout match that is level goes to extra
+// time, and then penalties.
+func (t *tournament) play(match *utils.Match) {
+	a, b := t.byName[match.A], t.byName[match.B]
+
+	// The goals in normal time, and then in extra time when it's needed
+	var goals [2][]int
+	score := func(minutes int) {
+		for side, mean := range []float64{t.expected(a, b), t.expected(b, a)} {
+			for count := t.poisson(mean * float64(minutes) / 90); count > 0; count-- {
+				goals[side] = append(goals[side], 90-minutes+1+t.random.Intn(minutes))
+			}
+		}
+	}
+
+	score(90)
+	if match.Stage != models.GROUP && len(goals[0]) == len(goals[1]) {
+		score(30)
+		if len(goals[0]) == len(goals[1]) {
+			match.Penalties = t.shootout()
+		}
+	}
+
+	match.Score = &utils.Score{A: len(goals[0]), B: len(goals[1])}
+	match.Events = []utils.Event{}
+
+	for side, minutes := range goals {
+		scorer, opponent := a, b
+		if side == 1 {
+			scorer, opponent = b, a
+		}
+
+		for _, minute := range minutes {
+			event := utils.Event{Kind: models.GOAL, Country: scorer.Code}
+			event.Minute, event.Offset = t.stoppage(minute)
+
+			switch roll := t.random.Float64(); {
+			case roll < 0.03:
+				// An own goal counts for the other country to the player who scored it
+				event.OwnGoal = true
+				event.Player = t.player(opponent, false)
+			case roll < 0.13:
+				event.Penalty = true
+				event.Player = t.player(scorer, true)
+			default:
+				event.Player = t.player(scorer, true)
+			}
+
+			match.Events = append(match.Events, event)
+		}
+	}
+
+	cards := []struct {
+		kind models.EventKind
+		mean float64
+	}{{models.YELLOW, averageYellows}, {models.RED, averageReds}}
+
+	for _, card := range cards {
+		for count := t.poisson(card.mean); count > 0; count-- {
+			c := a
+			if t.random.Intn(2) == 1 {
+				c = b
+			}
+
+			event := utils.Event{Kind: card.kind, Country: c.Code, Player: t.player(c, false)}
+			event.Minute, event.Offset = t.stoppage(1 + t.random.Intn(90))
+
+			match.Events = append(match.Events, event)
+		}
+	}
+
+	sort.SliceStable(match.Events, func(i, j int) bool {
+		x, y := match.Events[i], match.Events[j]
+		return x.Minute < y.Minute || (x.Minute == y.Minute && x.Offset < y.Offset)
+	})
+}
+
+// expected is the average number of goals a country scores against another
+func (t *tournament) expected(c, against *country) float64 {
+	return averageGoals * math.Exp(c.strength-against.strength)
+}
+
+// stoppage moves some of the events at the end of each half into the stoppage time
+func (t *tournament) stoppage(minute int) (int, int) {
+	if (minute == 45 || minute == 90 || minute == 120) && t.random.Intn(2) == 0 {
+		return minute, 1 + t.random.Intn(5)
+	}
+	return minute, 0
+}
+
+// player picks a player from the squad, where the scorers are more often the forwards. Anyone but the goalkeepers can
+// be picked otherwise.
+func (t *tournament) player(c *country, scoring bool) string {
+	var names []string
+
+	index := 0
+	for _, position := range positions {
+		weight := 1
+		if scoring {
+			weight = position.goals
+		} else if position.position == goalkeeper {
+			weight = 0
+		}
+
+		for n := 0; n < position.players; n++ {
+			for w := 0; w < weight; w++ {
+				names = append(names, c.squad[index].Name)
+			}
+			index++
+		}
+	}
+
+	return names[t.random.Intn(len(names))]
+}
+
+// shootout takes the five penalties each, and then one each until one country misses
+func (t *tournament) shootout() *utils.Score {
+	score := &utils.Score{}
+	take := func() int {
+		if t.random.Float64() < 0.75 {
+			return 1
+		}
+		return 0
+	}
+
+	for kick := 0; kick < 5; kick++ {
+		score.A += take()
+		score.B += take()
+	}
+
+	for score.A == score.B {
+		score.A += take()
+		score.B += take()
+	}
+
+	return score
+}
+
+// poisson draws the number of times something happens, when it happens the mean number of times on average
+func (t *tournament) poisson(mean float64) int {
+	limit, product, count := math.Exp(-mean), t.random.Float64(), 0
+	for product > limit {
+		product *= t.random.Float64()
+		count++
+	}
+	return count
+}
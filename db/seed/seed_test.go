@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cazier/wc/db"
+	"github.com/cazier/wc/db/load"
+	"github.com/cazier/wc/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2026, 6, 11, 0, 0, 0, 0, time.UTC)
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	tables := Generate(Options{Seed: 1, Start: start})
+	assert.Len(tables.Teams, 32)
+	assert.Len(tables.Players, 32*23)
+	assert.Len(tables.Matches, 64)
+	assert.Len(tables.Ratings, 32)
+
+	assert.Equal(tables, Generate(Options{Seed: 1, Start: start}), "the same seed makes the same tournament")
+	assert.NotEqual(tables.Teams, Generate(Options{Seed: 2, Start: start}).Teams)
+
+	for index, match := range tables.Matches {
+		assert.Nil(match.Score)
+		if index > 0 {
+			assert.False(match.Date.Before(tables.Matches[index-1].Date), "the matches should be in kickoff order")
+		}
+	}
+
+	assert.Equal(start.Add(10*time.Hour), tables.Matches[0].Date)
+	assert.Equal(models.FINAL, tables.Matches[63].Stage)
+	assert.Equal(placeholderA, tables.Matches[63].A)
+}
+
+func TestResults(t *testing.T) {
+	assert := assert.New(t)
+
+	tables := Generate(Options{Seed: 1, Start: start, Played: 20})
+	for index, match := range tables.Matches {
+		assert.Equal(index < 20, match.Score != nil)
+	}
+
+	tables = Generate(Options{Seed: 1, Start: start, Played: -1})
+	for _, match := range tables.Matches {
+		assert.NotNil(match.Score)
+		assert.NotEqual(placeholderA, match.A)
+
+		goals := map[string]int{}
+		for _, event := range match.Events {
+			if event.Kind == models.GOAL {
+				goals[event.Country]++
+			}
+		}
+
+		var a, b string
+		for _, team := range tables.Teams {
+			if team.Name == match.A {
+				a = team.Code
+			}
+			if team.Name == match.B {
+				b = team.Code
+			}
+		}
+		assert.Equal(match.Score.A, goals[a])
+		assert.Equal(match.Score.B, goals[b])
+
+		if match.Stage != models.GROUP {
+			_, found := result(match, true)
+			assert.True(found)
+			assert.Equal(match.Penalties != nil, match.Score.A == match.Score.B)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := db.OpenSqlite(&db.SqliteDBOptions{Memory: true, LogLevel: 1})
+	assert.NoError(err)
+	defer store.Close()
+	assert.NoError(store.LinkTables(false))
+
+	tables := Generate(Options{Seed: 3, Start: start, Played: 56})
+
+	changes, err := load.ImportTables(store, "seed", tables, load.Options{})
+	assert.NoError(err)
+	assert.Contains(changes.Summary(), "matches: 64 inserted, 0 updated, 0 deleted")
+
+	var played int64
+	store.DB.Model(&models.Match{}).Where("played = ?", true).Count(&played)
+	assert.EqualValues(56, played)
+
+	// Seeding the same tournament again changes nothing
+	changes, err = load.ImportTables(store, "seed", tables, load.Options{})
+	assert.NoError(err)
+	assert.Empty(changes)
+}
@@ -0,0 +1,340 @@
+// Package simulate plays out the rest of the tournament many times over, from the results so far, to find how likely
+// each country is to get out of its group, reach each round of the knockouts and win the final.
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
+	"github.com/cazier/wc/db/standings"
+	"gorm.io/gorm"
+)
+
+// The average number of goals a country scores against another one rated the same
+const averageGoals = 1.3
+
+// How many times more goals a country scores than its opponent for every 800 points it's rated above them
+const goalScale = 800
+
+// How many of each group go through to the knockouts
+const advancing = 2
+
+// Seat is a place in the table of a group, like the winners of group A
+type Seat struct {
+	Place int
+	Group string
+}
+
+// RoundOfSixteen is who plays who in each round of sixteen match, in the order they kick off, when the countries
+// in them haven't been set yet. The winners of each pair of matches in a round play each other in the next one, as
+// in the tournaments made by the seed package.
+var RoundOfSixteen = [][2]Seat{
+	{{1, "A"}, {2, "B"}}, {{1, "C"}, {2, "D"}}, {{1, "E"}, {2, "F"}}, {{1, "G"}, {2, "H"}},
+	{{1, "B"}, {2, "A"}}, {{1, "D"}, {2, "C"}}, {{1, "F"}, {2, "E"}}, {{1, "H"}, {2, "G"}},
+}
+
+// The knockout rounds, in the order they are played, apart from the third place match
+var knockouts = []models.Stage{models.ROUND_OF_SIXTEEN, models.QUARTERFINALS, models.SEMIFINALS, models.FINAL}
+
+// champion stands for winning the final, as the stage after it
+const champion = models.FINAL + 1
+
+type Options struct {
+	// How many times to play out the tournament, which is 10000 when it isn't set
+	Runs int
+	// The same seed always gives the same odds, for the same results
+	Seed int64
+
+	// The rating of each country, by its id, in place of the Elo rating the country already has
+	Ratings map[int]float64
+}
+
+// Odds are the chances (from 0 to 1) of a country reaching each stage of the tournament
+type Odds struct {
+	Country models.Country `json:"country"`
+	Rating  float64        `json:"rating"`
+
+	Advance       float64 `json:"advance"`
+	Quarterfinals float64 `json:"quarterfinals"`
+	Semifinals    float64 `json:"semifinals"`
+	Final         float64 `json:"final"`
+	Champion      float64 `json:"champion"`
+}
+
+// Load reads the countries and all of the matches from the database, and simulates the rest of the tournament
+func Load(ctx context.Context, database *gorm.DB, options Options) ([]Odds, error) {
+	countries, err := query.FindCountries(ctx, database, query.CountryFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := query.FindMatches(ctx, database, query.MatchFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	return Run(countries, matches, options)
+}
+
+// Run plays out the matches that haven't been played, keeping the results of those that have. The knockout matches
+// whose countries aren't known yet are filled in from the group tables and the rounds before them. The odds are
+// sorted with the likeliest winners first.
+func Run(countries []models.Country, matches []models.Match, options Options) ([]Odds, error) {
+	if options.Runs <= 0 {
+		options.Runs = 10000
+	}
+
+	s := &simulation{random: rand.New(rand.NewSource(options.Seed)), countries: countries, ratings: make(map[int]float64)}
+	for _, country := range countries {
+		s.ratings[country.ID] = country.Rating
+		if rating, found := options.Ratings[country.ID]; found {
+			s.ratings[country.ID] = rating
+		}
+	}
+
+	for _, match := range matches {
+		if match.Stage == models.GROUP {
+			s.group = append(s.group, match)
+		} else {
+			s.rounds[match.Stage] = append(s.rounds[match.Stage], match)
+		}
+	}
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	odds := make(map[int]*Odds)
+	for _, country := range countries {
+		if country.Group != "" {
+			odds[country.ID] = &Odds{Country: country, Rating: math.Round(s.ratings[country.ID])}
+		}
+	}
+
+	for run := 0; run < options.Runs; run++ {
+		reached := s.play()
+
+		for id, stage := range reached {
+			row := odds[id]
+			if row == nil {
+				continue
+			}
+
+			for _, tally := range []struct {
+				stage models.Stage
+				count *float64
+			}{
+				{models.ROUND_OF_SIXTEEN, &row.Advance},
+				{models.QUARTERFINALS, &row.Quarterfinals},
+				{models.SEMIFINALS, &row.Semifinals},
+				{models.FINAL, &row.Final},
+				{champion, &row.Champion},
+			} {
+				if stage >= tally.stage {
+					*tally.count++
+				}
+			}
+		}
+	}
+
+	output := make([]Odds, 0, len(odds))
+	for _, row := range odds {
+		for _, count := range []*float64{&row.Advance, &row.Quarterfinals, &row.Semifinals, &row.Final, &row.Champion} {
+			*count /= float64(options.Runs)
+		}
+		output = append(output, *row)
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		a, b := output[i], output[j]
+		for _, pair := range [][2]float64{{a.Champion, b.Champion}, {a.Final, b.Final}, {a.Advance, b.Advance}} {
+			if pair[0] != pair[1] {
+				return pair[0] > pair[1]
+			}
+		}
+		return a.Country.Name < b.Country.Name
+	})
+
+	return output, nil
+}
+
+type simulation struct {
+	random    *rand.Rand
+	countries []models.Country
+	ratings   map[int]float64
+
+	group  []models.Match
+	rounds [champion][]models.Match
+}
+
+// check makes sure that the knockout rounds can be filled in, when any of their countries still need to be
+func (s *simulation) check() error {
+	groups := make(map[string]bool)
+	for _, country := range s.countries {
+		groups[country.Group] = true
+	}
+
+	for _, match := range s.rounds[models.ROUND_OF_SIXTEEN] {
+		if !placeholder(match) {
+			continue
+		}
+
+		if len(s.rounds[models.ROUND_OF_SIXTEEN]) != len(RoundOfSixteen) {
+			return fmt.Errorf("the round of sixteen needs %d matches to fill it in, not %d", len(RoundOfSixteen), len(s.rounds[models.ROUND_OF_SIXTEEN]))
+		}
+
+		for _, seats := range RoundOfSixteen {
+			for _, seat := range seats {
+				if !groups[seat.Group] {
+					return fmt.Errorf("the round of sixteen needs a group %s", seat.Group)
+				}
+			}
+		}
+		break
+	}
+
+	for index, stage := range knockouts[1:] {
+		previous := len(s.rounds[knockouts[index]])
+		for _, match := range s.rounds[stage] {
+			if placeholder(match) && previous != 2*len(s.rounds[stage]) {
+				return fmt.Errorf("the %d %s matches can't be filled in from the %d matches before them", len(s.rounds[stage]), stage, previous)
+			}
+		}
+	}
+
+	return nil
+}
+
+// play runs the tournament once, returning the furthest stage each country reached
+func (s *simulation) play() map[int]models.Stage {
+	reached := make(map[int]models.Stage)
+	reach := func(id int, stage models.Stage) {
+		if stage > reached[id] {
+			reached[id] = stage
+		}
+	}
+
+	results := make([]models.Match, len(s.group))
+	for index, match := range s.group {
+		if !match.Played {
+			match.AScore, match.BScore = s.score(match.AID, match.BID, 90)
+			match.Played = true
+		}
+		results[index] = match
+	}
+
+	tables := make(map[string][]int)
+	for _, group := range standings.Compute(s.countries, results) {
+		for _, standing := range group.Standings {
+			tables[group.Group] = append(tables[group.Group], standing.Country.ID)
+		}
+		for _, standing := range group.Standings[:min(advancing, len(group.Standings))] {
+			reach(standing.Country.ID, models.ROUND_OF_SIXTEEN)
+		}
+	}
+
+	// The winners of each round, in the order its matches kick off
+	var winners []int
+
+	for _, stage := range knockouts {
+		var next []int
+
+		for slot, match := range s.rounds[stage] {
+			a, b := match.AID, match.BID
+
+			if placeholder(match) {
+				if stage == models.ROUND_OF_SIXTEEN {
+					seats := RoundOfSixteen[slot]
+					a, b = seat(tables, seats[0]), seat(tables, seats[1])
+				} else {
+					a, b = winners[slot*2], winners[slot*2+1]
+				}
+			}
+
+			reach(a, stage)
+			reach(b, stage)
+			next = append(next, s.knockout(match, a, b))
+		}
+
+		winners = next
+	}
+
+	// The third place match is left out, since it doesn't take anyone any further
+	if len(s.rounds[models.FINAL]) == 1 {
+		reach(winners[0], champion)
+	}
+
+	return reached
+}
+
+// knockout finds the winner of a knockout match, playing it when it hasn't been played yet. A match that is
+// level goes to extra time, and then penalties, which are a coin toss.
+func (s *simulation) knockout(match models.Match, a, b int) int {
+	aScore, bScore := match.AScore, match.BScore
+	aPenalties, bPenalties := match.APenalties, match.BPenalties
+
+	if !match.Played {
+		aScore, bScore = s.score(a, b, 90)
+		if aScore == bScore {
+			aExtra, bExtra := s.score(a, b, 30)
+			aScore, bScore = aScore+aExtra, bScore+bExtra
+		}
+
+		aPenalties, bPenalties = 0, 0
+		if aScore == bScore {
+			aPenalties = s.random.Intn(2)
+			bPenalties = 1 - aPenalties
+		}
+	}
+
+	if aScore > bScore || (aScore == bScore && aPenalties > bPenalties) {
+		return a
+	}
+	return b
+}
+
+// score makes up the goals each country scores in the given number of minutes
+func (s *simulation) score(a, b int, minutes int) (int, int) {
+	return s.goals(a, b, minutes), s.goals(b, a, minutes)
+}
+
+func (s *simulation) goals(id, against int, minutes int) int {
+	mean := averageGoals * math.Pow(10, (s.ratings[id]-s.ratings[against])/goalScale) * float64(minutes) / 90
+	return poisson(s.random, mean)
+}
+
+// poisson draws the number of times something happens, when it happens the mean number of times on average
+func poisson(random *rand.Rand, mean float64) int {
+	limit, product, count := math.Exp(-mean), random.Float64(), 0
+	for product > limit {
+		product *= random.Float64()
+		count++
+	}
+	return count
+}
+
+// placeholder checks if the countries in a knockout match still need to be filled in
+func placeholder(match models.Match) bool {
+	return match.ACountry.Group == "" || match.BCountry.Group == ""
+}
+
+// seat finds the country in a place of a group table
+func seat(tables map[string][]int, seat Seat) int {
+	table := tables[seat.Group]
+	if seat.Place > len(table) {
+		return 0
+	}
+	return table[seat.Place-1]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,82 @@
+package simulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cazier/wc/db/dbtest"
+	"github.com/cazier/wc/db/seed"
+	"github.com/stretchr/testify/assert"
+)
+
+// The first day of the tournaments the tests make up with the seed package
+var start = time.Date(2026, 6, 11, 0, 0, 0, 0, time.UTC)
+
+func total(odds []Odds, field func(Odds) float64) float64 {
+	sum := 0.0
+	for _, row := range odds {
+		sum += field(row)
+	}
+	return sum
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	store := dbtest.Seeded(t, seed.Options{Seed: 5, Start: start, Played: 30})
+
+	odds, err := Load(ctx, store.DB, Options{Runs: 500, Seed: 1})
+	assert.NoError(err)
+	assert.Len(odds, 32)
+
+	assert.InDelta(16, total(odds, func(o Odds) float64 { return o.Advance }), 1e-9)
+	assert.InDelta(8, total(odds, func(o Odds) float64 { return o.Quarterfinals }), 1e-9)
+	assert.InDelta(4, total(odds, func(o Odds) float64 { return o.Semifinals }), 1e-9)
+	assert.InDelta(2, total(odds, func(o Odds) float64 { return o.Final }), 1e-9)
+	assert.InDelta(1, total(odds, func(o Odds) float64 { return o.Champion }), 1e-9)
+
+	for index, row := range odds {
+		assert.GreaterOrEqual(row.Advance, row.Quarterfinals)
+		assert.GreaterOrEqual(row.Final, row.Champion)
+		if index > 0 {
+			assert.GreaterOrEqual(odds[index-1].Champion, row.Champion, "the likeliest winners should be first")
+		}
+	}
+
+	again, _ := Load(ctx, store.DB, Options{Runs: 500, Seed: 1})
+	assert.Equal(odds, again, "the same seed should give the same odds")
+}
+
+func TestFinished(t *testing.T) {
+	assert := assert.New(t)
+
+	store := dbtest.Seeded(t, seed.Options{Seed: 5, Start: start, Played: -1})
+
+	odds, err := Load(context.Background(), store.DB, Options{Runs: 10})
+	assert.NoError(err)
+
+	// Once everything has been played, there is nothing left to chance
+	assert.Equal(1.0, odds[0].Champion)
+	for _, row := range odds {
+		for _, chance := range []float64{row.Advance, row.Quarterfinals, row.Semifinals, row.Final, row.Champion} {
+			assert.Contains([]float64{0, 1}, chance)
+		}
+	}
+}
+
+func TestRatings(t *testing.T) {
+	assert := assert.New(t)
+
+	store := dbtest.Seeded(t, seed.Options{Seed: 5, Start: start, Played: 0})
+	weakest, _ := Load(context.Background(), store.DB, Options{Runs: 200})
+
+	// A country rated far above the others should nearly always win
+	id := weakest[len(weakest)-1].Country.ID
+	odds, err := Load(context.Background(), store.DB, Options{Runs: 200, Ratings: map[int]float64{id: 3000}})
+	assert.NoError(err)
+	assert.Equal(id, odds[0].Country.ID)
+	assert.Greater(odds[0].Champion, 0.9)
+	assert.Equal(3000.0, odds[0].Rating)
+}
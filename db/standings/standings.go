@@ -0,0 +1,110 @@
+// Package standings ranks the countries in each group by the results of the group matches that have finished.
+package standings
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/cazier/wc/db/models"
+	"github.com/cazier/wc/db/query"
+	"gorm.io/gorm"
+)
+
+// Load reads the countries and the group matches that have been played from the database, and ranks them
+func Load(ctx context.Context, database *gorm.DB) ([]models.GroupStandings, error) {
+	countries, err := query.FindCountries(ctx, database, query.CountryFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	stage, played := models.GROUP, true
+	matches, err := query.FindMatches(ctx, database, query.MatchFilter{Stage: &stage, Played: &played})
+	if err != nil {
+		return nil, err
+	}
+
+	return Compute(countries, matches), nil
+}
+
+// Compute builds the table of each group. A win is worth three points and a draw one, and the countries that are level
+// on points are ranked by their goal difference, then the goals they scored, and then their name. Only the matches
+// that have been played, between two countries of the same group, are counted.
+func Compute(countries []models.Country, matches []models.Match) []models.GroupStandings {
+	rows := make(map[int]*models.Standing)
+	groups := make(map[string][]*models.Standing)
+
+	for _, country := range countries {
+		if country.Group == "" {
+			continue
+		}
+
+		row := &models.Standing{Country: country, UpdatedAt: country.UpdatedAt}
+		rows[country.ID] = row
+		groups[country.Group] = append(groups[country.Group], row)
+	}
+
+	for _, match := range matches {
+		a, b := rows[match.AID], rows[match.BID]
+		if !match.Played || match.Stage != models.GROUP || a == nil || b == nil || a.Country.Group != b.Country.Group {
+			continue
+		}
+
+		record(a, match.AScore, match.BScore)
+		record(b, match.BScore, match.AScore)
+
+		for _, row := range []*models.Standing{a, b} {
+			if match.UpdatedAt.After(row.UpdatedAt) {
+				row.UpdatedAt = match.UpdatedAt
+			}
+		}
+	}
+
+	output := make([]models.GroupStandings, 0, len(groups))
+	for group, rows := range groups {
+		sort.Slice(rows, func(i, j int) bool { return ahead(*rows[i], *rows[j]) })
+
+		standings := make([]models.Standing, len(rows))
+		for index, row := range rows {
+			standings[index] = *row
+		}
+
+		output = append(output, models.GroupStandings{Group: group, Standings: standings})
+	}
+
+	sort.Slice(output, func(i, j int) bool { return output[i].Group < output[j].Group })
+	return output
+}
+
+// record adds the result of a single match to a country's standing
+func record(row *models.Standing, scored, conceded int) {
+	row.Played++
+	row.GoalsFor += scored
+	row.GoalsAgainst += conceded
+	row.GoalDifference = row.GoalsFor - row.GoalsAgainst
+
+	switch {
+	case scored > conceded:
+		row.Won++
+		row.Points += models.WIN_POINTS
+	case scored == conceded:
+		row.Drawn++
+		row.Points += models.DRAW_POINTS
+	default:
+		row.Lost++
+	}
+}
+
+// ahead checks if the first country is ranked above the second
+func ahead(a, b models.Standing) bool {
+	if a.Points != b.Points {
+		return a.Points > b.Points
+	}
+	if a.GoalDifference != b.GoalDifference {
+		return a.GoalDifference > b.GoalDifference
+	}
+	if a.GoalsFor != b.GoalsFor {
+		return a.GoalsFor > b.GoalsFor
+	}
+	return strings.ToLower(a.Country.Name) < strings.ToLower(b.Country.Name)
+}
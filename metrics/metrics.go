@@ -0,0 +1,205 @@
+// Package metrics collects the Prometheus metrics for the api, the database queries and the imports, which are
+// served by the api at /metrics. The metrics are split between the api server and the database store, and each one
+// has its own set, so that several can run in the same process without sharing their counts.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// Collection is a set of metrics that can be added to a registry
+type Collection interface {
+	Collectors() []prometheus.Collector
+}
+
+// NewRegistry makes a registry with each of the collections, along with the standard go runtime and process metrics
+func NewRegistry(collections ...Collection) (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+
+	all := []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+
+	for _, collection := range collections {
+		all = append(all, collection.Collectors()...)
+	}
+
+	for _, collector := range all {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// HTTP holds the metrics of an api server
+type HTTP struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	limited  *prometheus.CounterVec
+	lookups  *prometheus.CounterVec
+}
+
+func NewHTTP() *HTTP {
+	return &HTTP{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wc_http_requests_total",
+			Help: "The number of api requests, by route template and response status.",
+		}, []string{"method", "route", "status"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "wc_http_request_duration_seconds",
+			Help:    "How long the api requests took, by route template.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wc_http_rate_limited_total",
+			Help: "The number of api requests refused by the rate limits, by route group.",
+		}, []string{"group"}),
+
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wc_cache_lookups_total",
+			Help: "The number of lookups in the read caches, by cache and result (hit or miss).",
+		}, []string{"cache", "result"}),
+	}
+}
+
+func (m *HTTP) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.latency, m.limited, m.lookups}
+}
+
+// Middleware records the count and duration of each request. Requests are grouped by their route template (like
+// "/player/id/:id") rather than the path, so that the number of series stays fixed.
+func (m *HTTP) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.latency.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RateLimited counts a request refused by the rate limit of its route group
+func (m *HTTP) RateLimited(group string) {
+	m.limited.WithLabelValues(group).Inc()
+}
+
+// CacheLookup counts a lookup in one of the read caches, and whether the entry was found
+func (m *HTTP) CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.lookups.WithLabelValues(cache, result).Inc()
+}
+
+// Database holds the metrics of a database connection, and the imports into it
+type Database struct {
+	queries *prometheus.HistogramVec
+	imports *prometheus.CounterVec
+	rows    *prometheus.CounterVec
+}
+
+func NewDatabase() *Database {
+	return &Database{
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "wc_db_query_duration_seconds",
+			Help:    "How long the database queries took, by operation and table.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+
+		imports: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wc_imports_total",
+			Help: "The number of imports, by result (saved, dry_run or failed).",
+		}, []string{"result"}),
+
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wc_import_rows_total",
+			Help: "The number of rows changed by the saved imports, by table and action.",
+		}, []string{"table", "action"}),
+	}
+}
+
+func (m *Database) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.queries, m.imports, m.rows}
+}
+
+// Imported counts a finished import by its result, which is "saved", "dry_run" or "failed"
+func (m *Database) Imported(result string) {
+	m.imports.WithLabelValues(result).Inc()
+}
+
+// ImportedRow counts a row changed by a saved import, where the action is "insert", "update" or "delete"
+func (m *Database) ImportedRow(table, action string) {
+	m.rows.WithLabelValues(table, action).Inc()
+}
+
+const startKey = "metrics:start"
+
+// Instrument adds callbacks to the database that time each query
+func (m *Database) Instrument(database *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(startKey, time.Now())
+	}
+
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			value, found := tx.InstanceGet(startKey)
+			if !found {
+				return
+			}
+
+			table := tx.Statement.Table
+			if table == "" {
+				table = "unknown"
+			}
+
+			m.queries.WithLabelValues(operation, table).Observe(time.Since(value.(time.Time)).Seconds())
+		}
+	}
+
+	callbacks := database.Callback()
+
+	type registerer interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}
+
+	steps := []struct {
+		operation     string
+		before, after registerer
+	}{
+		{"create", callbacks.Create().Before("gorm:create"), callbacks.Create().After("gorm:create")},
+		{"query", callbacks.Query().Before("gorm:query"), callbacks.Query().After("gorm:query")},
+		{"update", callbacks.Update().Before("gorm:update"), callbacks.Update().After("gorm:update")},
+		{"delete", callbacks.Delete().Before("gorm:delete"), callbacks.Delete().After("gorm:delete")},
+		{"row", callbacks.Row().Before("gorm:row"), callbacks.Row().After("gorm:row")},
+		{"raw", callbacks.Raw().Before("gorm:raw"), callbacks.Raw().After("gorm:raw")},
+	}
+
+	for _, step := range steps {
+		if err := step.before.Register("metrics:before_"+step.operation, before); err != nil {
+			return err
+		}
+
+		if err := step.after.Register("metrics:after_"+step.operation, after(step.operation)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}